@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/exp/slog"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utiljson "k8s.io/apimachinery/pkg/util/json"
+	"k8s.io/apimachinery/pkg/util/yaml"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+	"github.com/fl64/k8s-resource-watcher/pkg/sink"
+	"github.com/fl64/k8s-resource-watcher/pkg/watcher"
+)
+
+// dryRun runs the fixtures through the configured filters and prints the
+// events that would fire, with the sinks they would be sent to, one JSON
+// object per line. Nothing is sent.
+func dryRun(cfg *config.Config, paths []string, logger *slog.Logger) error {
+	if len(paths) == 0 {
+		return fmt.Errorf("-dry-run requires -fixtures")
+	}
+	fixtures, err := loadAllFixtures(paths)
+	if err != nil {
+		return err
+	}
+	events, err := watcher.DryRun(cfg, fixtures, logger)
+	if printErr := printPreview(cfg, events); printErr != nil {
+		return printErr
+	}
+	return err
+}
+
+// printPreview prints events with the names of the sinks they would be sent
+// to, one JSON object per line.
+func printPreview(cfg *config.Config, events []event.Event) error {
+	encoder := json.NewEncoder(os.Stdout)
+	for _, ev := range events {
+		preview := struct {
+			Sinks []string    `json:"sinks"`
+			Event interface{} `json:"event"`
+		}{sink.Targets(cfg.Sinks, ev), ev}
+		if err := encoder.Encode(preview); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadAllFixtures(paths []string) ([]watcher.Fixture, error) {
+	var fixtures []watcher.Fixture
+	for _, path := range paths {
+		loaded, err := loadFixtures(path)
+		if err != nil {
+			return nil, err
+		}
+		fixtures = append(fixtures, loaded...)
+	}
+	return fixtures, nil
+}
+
+// loadFixtures reads the YAML or JSON documents of a file, or of the .yaml,
+// .yml, .json and .jsonl files of a directory in name order. Documents are
+// objects, lists of objects or recorded events with eventType and object.
+func loadFixtures(path string) ([]watcher.Fixture, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{path}
+	if info.IsDir() {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		files = nil
+		for _, entry := range entries {
+			switch filepath.Ext(entry.Name()) {
+			case ".yaml", ".yml", ".json", ".jsonl":
+				if !entry.IsDir() {
+					files = append(files, filepath.Join(path, entry.Name()))
+				}
+			}
+		}
+		sort.Strings(files)
+	}
+	var fixtures []watcher.Fixture
+	for _, file := range files {
+		loaded, err := readFixtures(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		fixtures = append(fixtures, loaded...)
+	}
+	return fixtures, nil
+}
+
+func readFixtures(file string) ([]watcher.Fixture, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var fixtures []watcher.Fixture
+	decoder := yaml.NewYAMLOrJSONDecoder(f, 4096)
+	for {
+		var raw json.RawMessage
+		if err := decoder.Decode(&raw); errors.Is(err, io.EOF) {
+			return fixtures, nil
+		} else if err != nil {
+			return nil, err
+		}
+		// Numbers are decoded as int64 where possible, like informers do.
+		var doc map[string]interface{}
+		if err := utiljson.Unmarshal(raw, &doc); err != nil {
+			return nil, err
+		}
+		if doc == nil {
+			continue
+		}
+		if object, ok := doc["object"].(map[string]interface{}); ok {
+			// Recorded events, only changes of objects are replayed.
+			switch eventType, _ := doc["eventType"].(string); eventType {
+			case "Add", "Update", "Delete":
+				fixtures = append(fixtures, watcher.Fixture{Type: eventType, GVR: recordedGVR(doc["gvr"]), Object: object})
+			}
+			continue
+		}
+		if kind, _ := doc["kind"].(string); strings.HasSuffix(kind, "List") {
+			items, _ := doc["items"].([]interface{})
+			for _, item := range items {
+				if object, ok := item.(map[string]interface{}); ok {
+					fixtures = append(fixtures, watcher.Fixture{Object: object})
+				}
+			}
+			continue
+		}
+		fixtures = append(fixtures, watcher.Fixture{Object: doc})
+	}
+}
+
+// recordedGVR reads the gvr of a recorded event, metadata only objects have
+// no kind to guess it from.
+func recordedGVR(value interface{}) schema.GroupVersionResource {
+	gvr, _ := value.(map[string]interface{})
+	group, _ := gvr["group"].(string)
+	version, _ := gvr["version"].(string)
+	resource, _ := gvr["resource"].(string)
+	return schema.GroupVersionResource{Group: group, Version: version, Resource: resource}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/watcher"
+)
+
+// genRBAC prints the ClusterRole, Roles and bindings a service account needs
+// to run with a config.
+func genRBAC(args []string) {
+	flags := flag.NewFlagSet("gen-rbac", flag.ExitOnError)
+	configFilePath := flags.String("config", "config.yaml", "path to the configuration file")
+	configDir := flags.String("config-dir", "", "merge all *.yaml files of this directory instead of -config")
+	profile := flags.String("profile", "", "profile of the config to apply, overrides profile")
+	name := flags.String("name", "k8s-resource-watcher", "name of the roles and bindings")
+	namespace := flags.String("namespace", "default", "namespace of the service account")
+	serviceAccount := flags.String("service-account", "k8s-resource-watcher", "service account to bind the roles to")
+	offline := flags.Bool("offline", false, "do not resolve kinds and wildcards with the cluster, entries must name group, version and resource")
+	flags.Parse(args)
+
+	path := configPath(*configFilePath, *configDir)
+	cfg, err := loadConfig(*configFilePath, *configDir, profileOverride(*profile))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+		os.Exit(1)
+	}
+	var restConfig *rest.Config
+	if !*offline {
+		if restConfig, err = watcher.LoadRestConfig(cfg.Client.AuthMode); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v, use -offline to skip discovery\n", path, err)
+			os.Exit(1)
+		}
+	}
+	rbac, err := watcher.RBACRules(cfg, restConfig, *namespace)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+		os.Exit(1)
+	}
+	if err := writeRBAC(os.Stdout, rbac, *name, *namespace, *serviceAccount); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// writeRBAC writes rbac as YAML documents, the notes go first as comments.
+func writeRBAC(w io.Writer, rbac *watcher.RBAC, name, namespace, serviceAccount string) error {
+	for _, note := range rbac.Notes {
+		fmt.Fprintf(w, "# %s\n", note)
+	}
+	subjects := []rbacv1.Subject{{Kind: rbacv1.ServiceAccountKind, Name: serviceAccount, Namespace: namespace}}
+	objects := []runtime.Object{
+		&rbacv1.ClusterRole{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRole"},
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Rules:      rbac.ClusterRules,
+		},
+		&rbacv1.ClusterRoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "ClusterRoleBinding"},
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: name},
+			Subjects:   subjects,
+		},
+	}
+	namespaces := make([]string, 0, len(rbac.NamespaceRules))
+	for ns := range rbac.NamespaceRules {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	for _, ns := range namespaces {
+		objects = append(objects,
+			&rbacv1.Role{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "Role"},
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+				Rules:      rbac.NamespaceRules[ns],
+			},
+			&rbacv1.RoleBinding{
+				TypeMeta:   metav1.TypeMeta{APIVersion: "rbac.authorization.k8s.io/v1", Kind: "RoleBinding"},
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: ns},
+				RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "Role", Name: name},
+				Subjects:   subjects,
+			},
+		)
+	}
+	for i, obj := range objects {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+		if err != nil {
+			return err
+		}
+		unstructured.RemoveNestedField(content, "metadata", "creationTimestamp")
+		if i > 0 {
+			fmt.Fprintln(w, "---")
+		}
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(content); err != nil {
+			return err
+		}
+		if err := encoder.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/alert"
+	"github.com/fl64/k8s-resource-watcher/pkg/audit"
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/history"
+	"github.com/fl64/k8s-resource-watcher/pkg/logging"
+	"github.com/fl64/k8s-resource-watcher/pkg/sink"
+	"github.com/fl64/k8s-resource-watcher/pkg/snapshot"
+	"github.com/fl64/k8s-resource-watcher/pkg/store"
+	"github.com/fl64/k8s-resource-watcher/pkg/watcher"
+)
+
+func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "replay":
+			replay(os.Args[2:])
+			return
+		case "record":
+			record(os.Args[2:])
+			return
+		case "play":
+			play(os.Args[2:])
+			return
+		case "verify":
+			verify(os.Args[2:])
+			return
+		case "validate":
+			validate(os.Args[2:])
+			return
+		case "gen-rbac":
+			genRBAC(os.Args[2:])
+			return
+		}
+	}
+
+	// Define a flag for the config file path
+	configFilePath := flag.String("config", "config.yaml", "path to the configuration file")
+	configDir := flag.String("config-dir", "", "merge all *.yaml files of this directory instead of -config")
+	profile := flag.String("profile", "", "profile of the config to apply, overrides profile")
+	listenAddress := flag.String("listen-address", ":8080", "address to serve metrics on, empty to disable")
+	once := flag.Bool("once", false, "emit the current state of all configured resources and exit")
+	snapshotPath := flag.String("snapshot", "", "write the current state of all configured resources to a file and exit")
+	diffAgainst := flag.String("diff-against", "", "report objects added, removed or changed since the given snapshot and exit")
+	authMode := flag.String("auth-mode", "", "credentials to use: auto, kubeconfig or in-cluster, overrides client.authMode")
+	as := flag.String("as", "", "user to impersonate, overrides client.impersonate.user")
+	var asGroups stringList
+	flag.Var(&asGroups, "as-group", "group to impersonate, can be repeated, overrides client.impersonate.groups")
+	namespaces := flag.String("namespaces", "", "comma separated namespaces to watch, overrides common.namespaces")
+	logLevel := flag.String("log-level", "", "debug, info, warn or error, overrides logLevel")
+	logFormat := flag.String("log-format", "", "json, logfmt or text, overrides logging.format")
+	logOutput := flag.String("log-output", "", "stdout, stderr or a file path, overrides logging.output")
+	var resync optionalDuration
+	flag.Var(&resync, "resync", "period of Resync events for unchanged objects, 0 disables them, overrides common.resync")
+	dryRunMode := flag.Bool("dry-run", false, "run the -fixtures through the configured filters, print the events that would fire and exit")
+	var fixtures stringList
+	flag.Var(&fixtures, "fixtures", "YAML or JSON fixture file or directory for -dry-run, can be repeated")
+	var sets stringList
+	flag.Var(&sets, "set", "override a config value as path=value, e.g. sinks.oncall.pagerduty.url=https://..., can be repeated")
+	printVersion := flag.Bool("version", false, "print the version and exit")
+	flag.Parse()
+	if *printVersion {
+		fmt.Println(currentBuild())
+		return
+	}
+	// Snapshots and drift reports are taken from the initial list only.
+	collecting := *snapshotPath != "" || *diffAgainst != ""
+	*once = *once || collecting
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	// Flags win over the environment, which wins over the config file.
+	if err := applyEnv(flag.CommandLine); err != nil {
+		logger.Error("Invalid environment override", "error", err)
+		os.Exit(1)
+	}
+	var overrides []config.Override
+	for _, set := range sets {
+		override, err := config.ParseOverride(set)
+		if err != nil {
+			logger.Error("Invalid -set flag", "error", err)
+			os.Exit(1)
+		}
+		overrides = append(overrides, override)
+	}
+	if *profile != "" {
+		overrides = append(overrides, config.Override{Path: "profile", Value: *profile})
+	}
+	if *namespaces != "" {
+		overrides = append(overrides, config.Override{Path: "common.namespaces", Value: "[" + *namespaces + "]"})
+	}
+	if *logLevel != "" {
+		overrides = append(overrides, config.Override{Path: "logLevel", Value: *logLevel})
+	}
+	if *logFormat != "" {
+		overrides = append(overrides, config.Override{Path: "logging.format", Value: *logFormat})
+	}
+	if *logOutput != "" {
+		overrides = append(overrides, config.Override{Path: "logging.output", Value: *logOutput})
+	}
+	if resync.set {
+		overrides = append(overrides, config.Override{Path: "common.resync", Value: resync.value.String()})
+	}
+
+	// Load and parse configuration
+	cfg, err := loadConfig(*configFilePath, *configDir, overrides)
+	if err != nil {
+		logger.Error("Failed to load config", "path", configPath(*configFilePath, *configDir), "error", err)
+		os.Exit(1)
+	}
+	logToggle := logging.NewToggle()
+	configured, logCloser, err := newLogger(cfg, logToggle)
+	if err != nil {
+		logger.Error("Failed to setup logging", "error", err)
+		os.Exit(1)
+	}
+	defer logCloser.Close()
+	logger = configured
+
+	if *dryRunMode {
+		if err := dryRun(cfg, fixtures, logger); err != nil {
+			logger.Error("Dry-run failed", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *authMode != "" {
+		cfg.Client.AuthMode = *authMode
+	}
+	if *as != "" {
+		cfg.Client.Impersonate.User = *as
+	}
+	if len(asGroups) > 0 {
+		cfg.Client.Impersonate.Groups = asGroups
+	}
+	// The effective config lets operators verify what is watched, with the
+	// common settings applied to every resource.
+	if effective, err := config.Effective(cfg); err != nil {
+		logger.Warn("Failed to resolve the effective config", "error", err)
+	} else {
+		logger.Info("Starting k8s-resource-watcher", "version", currentBuild().Version, "config", effective)
+	}
+
+	var baseline *snapshot.Snapshot
+	if *diffAgainst != "" {
+		if baseline, err = snapshot.Load(*diffAgainst); err != nil {
+			logger.Error("Failed to load baseline snapshot", "path", *diffAgainst, "error", err)
+			os.Exit(1)
+		}
+	}
+
+	// Setup Sinks
+	dispatcher, err := sink.NewDispatcher(cfg.Sinks, logger)
+	if err != nil {
+		logger.Error("Failed to setup sinks", "error", err)
+		os.Exit(1)
+	}
+
+	alerts, err := alert.New(cfg.Alerting, logger)
+	if err != nil {
+		logger.Error("Failed to setup alerting", "error", err)
+		os.Exit(1)
+	}
+	eventStore, err := store.New(cfg.Store)
+	if err != nil {
+		logger.Error("Failed to open event store", "error", err)
+		os.Exit(1)
+	}
+	objects := history.New(cfg.History)
+	signer, err := audit.NewSigner(cfg.Signing)
+	if err != nil {
+		logger.Error("Failed to setup signing", "error", err)
+		os.Exit(1)
+	}
+
+	w, err := watcher.New(watcher.Options{Config: cfg, Logger: logger})
+	if err != nil {
+		logger.Error("Failed to setup watcher", "error", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	go toggleOnSignal(ctx, logger, logToggle, cfg.Logging.Toggle)
+	go pauseOnSignal(ctx, w)
+
+	// Sinks get their own context so that in-flight events survive the shutdown signal.
+	sendCtx, cancelSend := context.WithCancel(context.Background())
+	defer cancelSend()
+	collector := snapshot.NewCollector()
+	eventsDone := make(chan struct{})
+	go func() {
+		defer close(eventsDone)
+		for ev := range w.Events() {
+			if collecting {
+				collector.Add(ev)
+				continue
+			}
+			ev = alerts.Process(sendCtx, ev)
+			if err := signer.Sign(&ev); err != nil {
+				logger.Error("Failed to sign event", "error", err)
+			}
+			if eventStore != nil {
+				if err := eventStore.Append(ev); err != nil {
+					logger.Error("Failed to store event", "error", err)
+				}
+			}
+			objects.Add(ev)
+			dispatcher.Dispatch(sendCtx, ev)
+		}
+	}()
+	if *listenAddress != "" {
+		server := newHTTPServer(*listenAddress, logger, logToggle, cfg, w, objects)
+		go func() {
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Error("Failed to serve metrics", "error", err)
+			}
+		}()
+		defer server.Close()
+	}
+
+	if err := w.Start(ctx); err != nil {
+		logger.Error("Failed to start watcher", "error", err)
+		os.Exit(1)
+	}
+	if *once {
+		logger.Info("Snapshot taken, shutting down...")
+	} else {
+		<-w.Done()
+		logger.Info("Shutting down gracefully...")
+	}
+
+	drainTimeout := cfg.DrainTimeout
+	if drainTimeout <= 0 {
+		drainTimeout = config.DefaultDrainTimeout
+	}
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), drainTimeout)
+	defer cancelDrain()
+
+	// Stop informers, then push out debounced updates and drain the queue.
+	// Stop waits for handlers blocked on a full queue, so the drain timeout
+	// covers it as well.
+	go w.Stop()
+	select {
+	case <-eventsDone:
+	case <-drainCtx.Done():
+		logger.Warn("Drain timeout exceeded, dropping pending events", "pending", w.Pending())
+		cancelSend()
+	}
+	if collecting {
+		reportSnapshot(sendCtx, logger, dispatcher, collector, baseline, *snapshotPath)
+	}
+	dispatcher.Close(drainCtx)
+	if eventStore != nil {
+		if err := eventStore.Close(); err != nil {
+			logger.Error("Failed to close event store", "error", err)
+		}
+	}
+	logger.Info("Shutdown complete")
+	if w.Err() != nil {
+		os.Exit(1)
+	}
+}
+
+// toggleOnSignal switches debug logging on and off on SIGUSR1.
+func toggleOnSignal(ctx context.Context, logger *slog.Logger, toggle *logging.Toggle, cfg config.LogToggleConfig) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR1)
+	defer signal.Stop(signals)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			if _, _, raised := toggle.Status(); raised {
+				toggle.Reset()
+				logger.Info("Debug logging disabled")
+				continue
+			}
+			duration := logToggleDuration(cfg)
+			toggle.Raise(slog.LevelDebug, duration)
+			logger.Info("Debug logging enabled", "duration", duration.String())
+		}
+	}
+}
+
+// pauseOnSignal pauses and resumes the emission of all events on SIGUSR2.
+func pauseOnSignal(ctx context.Context, w *watcher.Watcher) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGUSR2)
+	defer signal.Stop(signals)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-signals:
+			if w.PauseStatus().All {
+				w.Resume("")
+			} else {
+				w.Pause("")
+			}
+		}
+	}
+}
+
+func logToggleDuration(cfg config.LogToggleConfig) time.Duration {
+	if cfg.Duration > 0 {
+		return cfg.Duration
+	}
+	return config.DefaultLogToggleDuration
+}
+
+// reportSnapshot dispatches the drift against baseline, if any, and saves the
+// collected state to path, if set.
+func reportSnapshot(
+	ctx context.Context,
+	logger *slog.Logger,
+	dispatcher *sink.Dispatcher,
+	collector *snapshot.Collector,
+	baseline *snapshot.Snapshot,
+	path string,
+) {
+	name := snapshot.NameFromPath(path)
+	if path == "" {
+		name = "current"
+	}
+	current := collector.Snapshot(name)
+	if baseline != nil {
+		drift := snapshot.Diff(baseline, current)
+		counts := map[string]int{}
+		for _, ev := range drift {
+			counts[ev.Type]++
+			dispatcher.Dispatch(ctx, ev)
+		}
+		logger.Info("Drift report", "baseline", baseline.Name, "baselineTakenAt", baseline.TakenAt,
+			"added", counts[snapshot.TypeDriftAdded], "removed", counts[snapshot.TypeDriftRemoved], "changed", counts[snapshot.TypeDriftChanged])
+	}
+	if path != "" {
+		if err := current.Save(path); err != nil {
+			logger.Error("Failed to save snapshot", "path", path, "error", err)
+			return
+		}
+		logger.Info("Snapshot saved", "path", path, "objects", len(current.Objects))
+	}
+}
+
+// loadConfig loads the config directory when set and the config file otherwise.
+func loadConfig(path, dir string, overrides []config.Override) (*config.Config, error) {
+	if dir != "" {
+		return config.LoadDir(dir, overrides...)
+	}
+	return config.Load(path, overrides...)
+}
+
+// profileOverride selects profile, if set, for the subcommands without -set.
+func profileOverride(profile string) []config.Override {
+	if profile == "" {
+		return nil
+	}
+	return []config.Override{{Path: "profile", Value: profile}}
+}
+
+func configPath(path, dir string) string {
+	if dir != "" {
+		return dir
+	}
+	return path
+}
+
+// envPrefix prefixes the environment variables overriding flags, e.g.
+// K8S_RESOURCE_WATCHER_LOG_LEVEL sets -log-level.
+const envPrefix = "K8S_RESOURCE_WATCHER_"
+
+// applyEnv sets the flags not given on the command line from the environment.
+// Repeatable flags take a semicolon separated list.
+func applyEnv(flags *flag.FlagSet) error {
+	set := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	var err error
+	flags.VisitAll(func(f *flag.Flag) {
+		if set[f.Name] || err != nil {
+			return
+		}
+		name := envPrefix + strings.ToUpper(strings.ReplaceAll(f.Name, "-", "_"))
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		values := []string{value}
+		if _, repeatable := f.Value.(*stringList); repeatable {
+			values = strings.Split(value, ";")
+		}
+		for _, value := range values {
+			if err = flags.Set(f.Name, value); err != nil {
+				err = fmt.Errorf("%s: %w", name, err)
+				return
+			}
+		}
+	})
+	return err
+}
+
+// newLogger returns the configured logger, at info level when none is set.
+// toggle may be nil.
+func newLogger(cfg *config.Config, toggle *logging.Toggle) (*slog.Logger, io.Closer, error) {
+	level := slog.LevelInfo
+	if cfg.LogLevel != "" {
+		var err error
+		if level, err = logging.ParseLevel(cfg.LogLevel); err != nil {
+			return nil, nil, fmt.Errorf("invalid log level: %w", err)
+		}
+	}
+	return logging.New(level, cfg.Logging, toggle)
+}
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// optionalDuration is a duration flag that tells zero apart from not set.
+type optionalDuration struct {
+	value time.Duration
+	set   bool
+}
+
+func (d *optionalDuration) String() string {
+	if !d.set {
+		return ""
+	}
+	return d.value.String()
+}
+
+func (d *optionalDuration) Set(value string) error {
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	d.value, d.set = parsed, true
+	return nil
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/sink"
+	"github.com/fl64/k8s-resource-watcher/pkg/watcher"
+)
+
+// play feeds a recording through the configured filters and sinks, so that
+// config changes can be tried against real traffic without a cluster.
+func play(args []string) {
+	flags := flag.NewFlagSet("play", flag.ExitOnError)
+	configFilePath := flags.String("config", "config.yaml", "path to the configuration file")
+	configDir := flags.String("config-dir", "", "merge all *.yaml files of this directory instead of -config")
+	var inputs stringList
+	flags.Var(&inputs, "input", "recording or fixture file or directory, can be repeated")
+	preview := flags.Bool("dry-run", false, "print the events with their sinks instead of sending them")
+	flags.Parse(args)
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	if len(inputs) == 0 {
+		logger.Error("Play requires -input")
+		os.Exit(1)
+	}
+	cfg, err := loadConfig(*configFilePath, *configDir, nil)
+	if err != nil {
+		logger.Error("Failed to load config", "path", configPath(*configFilePath, *configDir), "error", err)
+		os.Exit(1)
+	}
+	configured, logCloser, err := newLogger(cfg, nil)
+	if err != nil {
+		logger.Error("Failed to setup logging", "error", err)
+		os.Exit(1)
+	}
+	defer logCloser.Close()
+	logger = configured
+
+	fixtures, err := loadAllFixtures(inputs)
+	if err != nil {
+		logger.Error("Failed to load recording", "error", err)
+		os.Exit(1)
+	}
+	events, err := watcher.DryRun(cfg, fixtures, logger)
+	if err != nil {
+		logger.Error("Invalid recording", "error", err)
+		os.Exit(1)
+	}
+	if *preview {
+		if err := printPreview(cfg, events); err != nil {
+			logger.Error("Failed to print events", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	dispatcher, err := sink.NewDispatcher(cfg.Sinks, logger)
+	if err != nil {
+		logger.Error("Failed to setup sinks", "error", err)
+		os.Exit(1)
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	played := 0
+	for _, ev := range events {
+		if ctx.Err() != nil {
+			break
+		}
+		dispatcher.Dispatch(ctx, ev)
+		played++
+	}
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), config.DefaultDrainTimeout)
+	defer cancelDrain()
+	dispatcher.Close(drainCtx)
+	logger.Info("Play complete", "recorded", len(fixtures), "played", played)
+}
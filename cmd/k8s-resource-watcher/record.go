@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/exp/slog"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+	"github.com/fl64/k8s-resource-watcher/pkg/watcher"
+)
+
+// recordedEvent is a line of a recording, readable as a dry-run fixture.
+type recordedEvent struct {
+	GVR       event.GVR              `json:"gvr"`
+	Type      string                 `json:"eventType"`
+	Timestamp time.Time              `json:"timestamp"`
+	Object    map[string]interface{} `json:"object"`
+}
+
+// record writes the unfiltered objects of the configured resources to a file
+// until interrupted, for play.
+func record(args []string) {
+	flags := flag.NewFlagSet("record", flag.ExitOnError)
+	configFilePath := flags.String("config", "config.yaml", "path to the configuration file")
+	configDir := flags.String("config-dir", "", "merge all *.yaml files of this directory instead of -config")
+	output := flags.String("output", "", "file to write the recording to as JSON lines")
+	duration := flags.Duration("duration", 0, "stop recording after this duration, 0 records until interrupted")
+	flags.Parse(args)
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	if *output == "" {
+		logger.Error("Record requires -output")
+		os.Exit(1)
+	}
+	cfg, err := loadConfig(*configFilePath, *configDir, nil)
+	if err != nil {
+		logger.Error("Failed to load config", "path", configPath(*configFilePath, *configDir), "error", err)
+		os.Exit(1)
+	}
+	configured, logCloser, err := newLogger(cfg, nil)
+	if err != nil {
+		logger.Error("Failed to setup logging", "error", err)
+		os.Exit(1)
+	}
+	defer logCloser.Close()
+	logger = configured
+
+	f, err := os.Create(*output)
+	if err != nil {
+		logger.Error("Failed to create recording", "path", *output, "error", err)
+		os.Exit(1)
+	}
+	writer := bufio.NewWriter(f)
+	encoder := json.NewEncoder(writer)
+	var mu sync.Mutex
+	recorded := 0
+	recorder := func(gvr schema.GroupVersionResource, eventType string, obj map[string]interface{}) {
+		mu.Lock()
+		defer mu.Unlock()
+		line := recordedEvent{
+			GVR:       event.GVR{Group: gvr.Group, Version: gvr.Version, Resource: gvr.Resource},
+			Type:      eventType,
+			Timestamp: time.Now(),
+			Object:    obj,
+		}
+		if err := encoder.Encode(line); err != nil {
+			logger.Error("Failed to write recording", "error", err)
+			return
+		}
+		recorded++
+	}
+
+	w, err := watcher.New(watcher.Options{Config: cfg, Logger: logger, Recorder: recorder})
+	if err != nil {
+		logger.Error("Failed to setup watcher", "error", err)
+		os.Exit(1)
+	}
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	if *duration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+	eventsDone := make(chan struct{})
+	go func() {
+		defer close(eventsDone)
+		for range w.Events() {
+		}
+	}()
+	if err := w.Start(ctx); err != nil {
+		logger.Error("Failed to start watcher", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("Recording", "path", *output)
+	<-w.Done()
+	w.Stop()
+	<-eventsDone
+
+	mu.Lock()
+	defer mu.Unlock()
+	err = writer.Flush()
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		logger.Error("Failed to write recording", "path", *output, "error", err)
+		os.Exit(1)
+	}
+	logger.Info("Recording complete", "recorded", recorded)
+}
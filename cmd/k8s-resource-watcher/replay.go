@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+	"github.com/fl64/k8s-resource-watcher/pkg/sink"
+	"github.com/fl64/k8s-resource-watcher/pkg/store"
+)
+
+// replay re-emits stored events through the configured sinks.
+func replay(args []string) {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	configFilePath := flags.String("config", "config.yaml", "path to the configuration file")
+	configDir := flags.String("config-dir", "", "merge all *.yaml files of this directory instead of -config")
+	from := flags.String("from", "", "replay events at or after this RFC3339 time")
+	to := flags.String("to", "", "replay events before this RFC3339 time")
+	pace := flags.Bool("pace", false, "keep the original time between events")
+	flags.Parse(args)
+
+	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	fromTime, err := parseTime(*from)
+	if err != nil {
+		logger.Error("Invalid -from time", "error", err)
+		os.Exit(1)
+	}
+	toTime, err := parseTime(*to)
+	if err != nil {
+		logger.Error("Invalid -to time", "error", err)
+		os.Exit(1)
+	}
+	cfg, err := loadConfig(*configFilePath, *configDir, nil)
+	if err != nil {
+		logger.Error("Failed to load config", "path", configPath(*configFilePath, *configDir), "error", err)
+		os.Exit(1)
+	}
+	configured, logCloser, err := newLogger(cfg, nil)
+	if err != nil {
+		logger.Error("Failed to setup logging", "error", err)
+		os.Exit(1)
+	}
+	defer logCloser.Close()
+	logger = configured
+	eventStore, err := store.New(cfg.Store)
+	if err != nil {
+		logger.Error("Failed to open event store", "error", err)
+		os.Exit(1)
+	}
+	if eventStore == nil {
+		logger.Error("Replay requires a configured event store")
+		os.Exit(1)
+	}
+	defer eventStore.Close()
+	dispatcher, err := sink.NewDispatcher(cfg.Sinks, logger)
+	if err != nil {
+		logger.Error("Failed to setup sinks", "error", err)
+		os.Exit(1)
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+
+	var previous time.Time
+	replayed := 0
+	err = eventStore.Read(ctx, fromTime, toTime, func(ev event.Event) error {
+		if *pace && !previous.IsZero() && ev.Timestamp.After(previous) {
+			select {
+			case <-time.After(ev.Timestamp.Sub(previous)):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		previous = ev.Timestamp
+		dispatcher.Dispatch(ctx, ev)
+		replayed++
+		return nil
+	})
+	// Sinks still get the chance to flush when replay was interrupted.
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), config.DefaultDrainTimeout)
+	defer cancelDrain()
+	dispatcher.Close(drainCtx)
+	if err != nil && !errors.Is(err, context.Canceled) {
+		logger.Error("Replay failed", "replayed", replayed, "error", err)
+		os.Exit(1)
+	}
+	logger.Info("Replay complete", "replayed", replayed)
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"golang.org/x/exp/slog"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/history"
+	"github.com/fl64/k8s-resource-watcher/pkg/logging"
+	"github.com/fl64/k8s-resource-watcher/pkg/watcher"
+)
+
+func newHTTPServer(address string, logger *slog.Logger, toggle *logging.Toggle, cfg *config.Config, w *watcher.Watcher, objects *history.History) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	mux.Handle("GET /version", &versionHandler{cfg: cfg, watcher: w})
+	mux.Handle("GET /config", &configHandler{cfg: cfg})
+	if cfg.Logging.Toggle.Token != "" {
+		mux.Handle("/debug/loglevel", &logLevelHandler{logger: logger, toggle: toggle, cfg: cfg.Logging.Toggle})
+	}
+	if cfg.Pause.Token != "" {
+		mux.Handle("/pause", &pauseHandler{watcher: w, token: cfg.Pause.Token})
+	}
+	if objects != nil {
+		handler := &historyHandler{history: objects, token: cfg.History.Token}
+		mux.Handle("GET /history/{resource}/{name}", handler)
+		mux.Handle("GET /history/{resource}/{namespace}/{name}", handler)
+	}
+	return &http.Server{Addr: address, Handler: mux}
+}
+
+// authorized checks the bearer token of r in constant time.
+func authorized(r *http.Request, token string) bool {
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+token)) == 1
+}
+
+// logLevelHandler shows (GET), raises (POST, optional level and duration form
+// values) and resets (DELETE) the log level toggle.
+type logLevelHandler struct {
+	logger *slog.Logger
+	toggle *logging.Toggle
+	cfg    config.LogToggleConfig
+}
+
+type logLevelStatus struct {
+	Raised bool       `json:"raised"`
+	Level  string     `json:"level,omitempty"`
+	Until  *time.Time `json:"until,omitempty"`
+}
+
+func (h *logLevelHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !authorized(r, h.cfg.Token) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost, http.MethodPut:
+		level := slog.LevelDebug
+		if value := r.FormValue("level"); value != "" {
+			var err error
+			if level, err = logging.ParseLevel(value); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		duration := logToggleDuration(h.cfg)
+		if value := r.FormValue("duration"); value != "" {
+			var err error
+			if duration, err = time.ParseDuration(value); err != nil || duration <= 0 {
+				http.Error(w, "invalid duration", http.StatusBadRequest)
+				return
+			}
+		}
+		h.toggle.Raise(level, duration)
+		h.logger.Info("Log level raised", "level", level.String(), "duration", duration.String(), "remote", r.RemoteAddr)
+	case http.MethodDelete:
+		h.toggle.Reset()
+		h.logger.Info("Log level reset", "remote", r.RemoteAddr)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var status logLevelStatus
+	if level, until, ok := h.toggle.Status(); ok {
+		status = logLevelStatus{Raised: true, Level: level.String(), Until: &until}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// pauseHandler shows (GET), pauses (POST) and resumes (DELETE) event emission.
+// The optional resource form value, e.g. "pods" or "deployments.apps", limits
+// the request to one resource.
+type pauseHandler struct {
+	watcher *watcher.Watcher
+	token   string
+}
+
+func (h *pauseHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !authorized(r, h.token) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost, http.MethodPut:
+		h.watcher.Pause(r.FormValue("resource"))
+	case http.MethodDelete:
+		h.watcher.Resume(r.FormValue("resource"))
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(h.watcher.PauseStatus())
+}
+
+// historyHandler returns the recent versions of an object, e.g.
+// /history/deployments.apps/default/nginx or /history/nodes/node-1 for
+// cluster-scoped objects.
+type historyHandler struct {
+	history *history.History
+	token   string
+}
+
+type historyResponse struct {
+	Resource  string            `json:"resource"`
+	Namespace string            `json:"namespace,omitempty"`
+	Name      string            `json:"name"`
+	Versions  []history.Version `json:"versions"`
+}
+
+func (h *historyHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !authorized(r, h.token) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	response := historyResponse{Resource: r.PathValue("resource"), Namespace: r.PathValue("namespace"), Name: r.PathValue("name")}
+	versions, ok := h.history.Get(response.Resource, response.Namespace, response.Name)
+	if !ok {
+		http.Error(w, "no history of this object", http.StatusNotFound)
+		return
+	}
+	response.Versions = versions
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// versionHandler reports the build, the cluster and the watched resources,
+// e.g. to take stock of a fleet of watchers.
+type versionHandler struct {
+	cfg     *config.Config
+	watcher *watcher.Watcher
+}
+
+type versionResponse struct {
+	buildInfo
+	Cluster     string            `json:"cluster,omitempty"`
+	Profile     string            `json:"profile,omitempty"`
+	KubeVersion string            `json:"kubeVersion,omitempty"`
+	Resources   []versionResource `json:"resources"`
+}
+
+type versionResource struct {
+	Group    string `json:"group,omitempty"`
+	Version  string `json:"version"`
+	Resource string `json:"resource"`
+}
+
+func (h *versionHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	response := versionResponse{
+		buildInfo:   currentBuild(),
+		Cluster:     h.cfg.Cluster,
+		Profile:     h.cfg.Profile,
+		KubeVersion: h.watcher.ServerVersion(),
+		Resources:   []versionResource{},
+	}
+	for _, gvr := range h.watcher.Resources() {
+		response.Resources = append(response.Resources, versionResource{Group: gvr.Group, Version: gvr.Version, Resource: gvr.Resource})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// configHandler returns the effective config with secrets redacted.
+type configHandler struct {
+	cfg *config.Config
+}
+
+func (h *configHandler) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	effective, err := config.Effective(h.cfg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(effective)
+}
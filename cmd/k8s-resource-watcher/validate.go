@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/alert"
+	"github.com/fl64/k8s-resource-watcher/pkg/audit"
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/sink"
+	"github.com/fl64/k8s-resource-watcher/pkg/watcher"
+)
+
+// validate checks a config file and prints one line per problem.
+func validate(args []string) {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	configFilePath := flags.String("config", "config.yaml", "path to the configuration file")
+	configDir := flags.String("config-dir", "", "merge all *.yaml files of this directory instead of -config")
+	profile := flags.String("profile", "", "profile of the config to apply, overrides profile")
+	offline := flags.Bool("offline", false, "skip the checks against the cluster")
+	flags.Parse(args)
+
+	path := configPath(*configFilePath, *configDir)
+	cfg, err := loadConfig(*configFilePath, *configDir, profileOverride(*profile))
+	if err != nil {
+		for _, err := range unwrapJoined(err) {
+			// Errors of a config directory name their file already.
+			if *configDir != "" {
+				fmt.Println(err)
+			} else {
+				fmt.Printf("%s: %v\n", path, err)
+			}
+		}
+		os.Exit(1)
+	}
+	errs := watcher.ValidateConfig(cfg)
+	for i, sinkConfig := range cfg.Sinks {
+		if err := sink.Validate(sinkConfig); err != nil {
+			errs = append(errs, fmt.Errorf("sinks[%d] (%s): %w", i, sinkConfig.Name, err))
+		}
+	}
+	if _, err := alert.New(cfg.Alerting, slog.Default()); err != nil {
+		errs = append(errs, fmt.Errorf("alerting: %w", err))
+	}
+	if _, err := audit.NewSigner(cfg.Signing); err != nil {
+		errs = append(errs, fmt.Errorf("signing: %w", err))
+	}
+	if !*offline {
+		clusterErrs, err := validateCluster(cfg)
+		if err != nil {
+			fmt.Printf("%s: cluster checks failed, use -offline to skip them: %v\n", path, err)
+			os.Exit(1)
+		}
+		errs = append(errs, clusterErrs...)
+	}
+	for _, err := range errs {
+		fmt.Printf("%s: %v\n", path, err)
+	}
+	if len(errs) > 0 {
+		os.Exit(1)
+	}
+	fmt.Printf("%s: ok\n", path)
+}
+
+func validateCluster(cfg *config.Config) ([]error, error) {
+	restConfig, err := watcher.LoadRestConfig(cfg.Client.AuthMode)
+	if err != nil {
+		return nil, err
+	}
+	return watcher.ValidateCluster(cfg, restConfig)
+}
+
+// unwrapJoined splits errors joined with errors.Join, so that every problem
+// gets its own line.
+func unwrapJoined(err error) []error {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		return joined.Unwrap()
+	}
+	return []error{err}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/audit"
+)
+
+// verify checks the signatures and chains of archived events and prints one
+// line per problem.
+func verify(args []string) {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	configFilePath := flags.String("config", "config.yaml", "path to the configuration file")
+	configDir := flags.String("config-dir", "", "merge all *.yaml files of this directory instead of -config")
+	input := flags.String("input", "-", "JSON lines of events, e.g. the event store file, - reads stdin")
+	allowRestarts := flags.Bool("allow-restarts", false, "accept chains that start anew, e.g. of a watcher without signing.stateFile")
+	flags.Parse(args)
+
+	cfg, err := loadConfig(*configFilePath, *configDir, nil)
+	if err != nil {
+		fmt.Printf("%s: %v\n", configPath(*configFilePath, *configDir), err)
+		os.Exit(1)
+	}
+	verifier, err := audit.NewVerifier(cfg.Signing)
+	if err != nil {
+		fmt.Printf("signing: %v\n", err)
+		os.Exit(1)
+	}
+	verifier.AllowRestarts = *allowRestarts
+	var r io.Reader = os.Stdin
+	if *input != "-" {
+		f, err := os.Open(*input)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		r = f
+	}
+	count, errs := verifier.VerifyStream(r)
+	for _, err := range errs {
+		fmt.Printf("%s: %v\n", *input, err)
+	}
+	if len(errs) > 0 {
+		os.Exit(1)
+	}
+	fmt.Printf("%s: %d events ok\n", *input, count)
+}
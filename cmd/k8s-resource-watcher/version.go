@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// version and commit are set at build time, e.g.
+// go build -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse HEAD)".
+// Without them the commit comes from the VCS info embedded by go build.
+var (
+	version = "dev"
+	commit  = ""
+)
+
+type buildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	GoVersion string `json:"goVersion"`
+}
+
+func currentBuild() buildInfo {
+	info := buildInfo{Version: version, Commit: commit, GoVersion: runtime.Version()}
+	if build, ok := debug.ReadBuildInfo(); ok && info.Commit == "" {
+		for _, setting := range build.Settings {
+			if setting.Key == "vcs.revision" {
+				info.Commit = setting.Value
+			}
+		}
+	}
+	return info
+}
+
+func (b buildInfo) String() string {
+	s := "k8s-resource-watcher " + b.Version
+	if b.Commit != "" {
+		s += " (" + b.Commit + ")"
+	}
+	return fmt.Sprintf("%s %s %s/%s", s, b.GoVersion, runtime.GOOS, runtime.GOARCH)
+}
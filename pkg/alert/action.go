@@ -0,0 +1,176 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+const (
+	defaultActionTimeout = 5 * time.Second
+	pagerDutyEventsURL   = "https://events.pagerduty.com/v2/enqueue"
+)
+
+// Action delivers alerts to a notification target.
+type Action interface {
+	Name() string
+	Notify(ctx context.Context, alert event.Alert, ev event.Event) error
+	// Deduplicates reports whether the target deduplicates alerts by their key itself.
+	Deduplicates() bool
+}
+
+// NewAction creates the alert action described by cfg.
+func NewAction(cfg config.AlertActionConfig) (Action, error) {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultActionTimeout
+	}
+	base := httpAction{cfg: cfg, client: &http.Client{Timeout: timeout}}
+	switch cfg.Type {
+	case config.AlertActionWebhook:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("webhook action requires url")
+		}
+		return &webhookAction{base}, nil
+	case config.AlertActionSlack:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("slack action requires url")
+		}
+		return &slackAction{base}, nil
+	case config.AlertActionPagerDuty:
+		if cfg.RoutingKey == "" {
+			return nil, fmt.Errorf("pagerduty action requires routingKey")
+		}
+		if base.cfg.URL == "" {
+			base.cfg.URL = pagerDutyEventsURL
+		}
+		return &pagerDutyAction{base}, nil
+	}
+	return nil, fmt.Errorf("unknown alert action type %q", cfg.Type)
+}
+
+type httpAction struct {
+	cfg    config.AlertActionConfig
+	client *http.Client
+}
+
+func (a *httpAction) Name() string {
+	return a.cfg.Name
+}
+
+func (a *httpAction) Deduplicates() bool {
+	return false
+}
+
+func (a *httpAction) post(ctx context.Context, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range a.cfg.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s", a.cfg.Type, resp.Status)
+	}
+	return nil
+}
+
+// webhookAction posts the alert together with its event.
+type webhookAction struct {
+	httpAction
+}
+
+func (a *webhookAction) Notify(ctx context.Context, alert event.Alert, ev event.Event) error {
+	return a.post(ctx, struct {
+		Alert event.Alert `json:"alert"`
+		Event event.Event `json:"event"`
+	}{alert, ev})
+}
+
+// slackAction posts to a Slack incoming webhook.
+type slackAction struct {
+	httpAction
+}
+
+func (a *slackAction) Notify(ctx context.Context, alert event.Alert, ev event.Event) error {
+	text := fmt.Sprintf("[%s] %s (%s %s)", alert.Severity, alert.Summary, ev.Type, ev.GVR.Resource)
+	if alert.Status == event.AlertResolved {
+		text = "[RESOLVED] " + alert.Summary
+	}
+	return a.post(ctx, map[string]string{"text": text})
+}
+
+// pagerDutyAction triggers incidents with the Events API v2.
+type pagerDutyAction struct {
+	httpAction
+}
+
+func (a *pagerDutyAction) Deduplicates() bool {
+	return true
+}
+
+func (a *pagerDutyAction) Notify(ctx context.Context, alert event.Alert, ev event.Event) error {
+	return a.post(ctx, PagerDutyEvent(a.cfg.RoutingKey, PagerDutySeverity(alert.Severity), alert, ev))
+}
+
+// PagerDutyEvent builds an Events API v2 request that triggers or resolves alert.
+func PagerDutyEvent(routingKey, severity string, alert event.Alert, ev event.Event) map[string]interface{} {
+	if alert.Status == event.AlertResolved {
+		return map[string]interface{}{
+			"routing_key":  routingKey,
+			"event_action": "resolve",
+			"dedup_key":    alert.DedupKey,
+		}
+	}
+	return map[string]interface{}{
+		"routing_key":  routingKey,
+		"event_action": "trigger",
+		"dedup_key":    alert.DedupKey,
+		"payload": map[string]interface{}{
+			"summary":  alert.Summary,
+			"source":   Source(ev),
+			"severity": severity,
+			"custom_details": map[string]interface{}{
+				"rule":      alert.Rule,
+				"eventType": ev.Type,
+				"object":    ev.Object,
+			},
+		},
+	}
+}
+
+// PagerDutySeverity maps unknown severities to error, PagerDuty rejects them otherwise.
+func PagerDutySeverity(severity string) string {
+	switch severity {
+	case "critical", "error", "warning", "info":
+		return severity
+	}
+	return "error"
+}
+
+// Source identifies the object of ev for incident management tools.
+func Source(ev event.Event) string {
+	if ev.Cluster != "" {
+		return ev.Cluster + "/" + ev.GVR.Resource + "/" + objectName(ev)
+	}
+	return ev.GVR.Resource + "/" + objectName(ev)
+}
@@ -0,0 +1,307 @@
+// Package alert turns events into alerts with CEL rules and notifies alert actions.
+package alert
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/cel-go/cel"
+	"golang.org/x/exp/slog"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+const defaultDedupWindow = 10 * time.Minute
+
+type rule struct {
+	cfg       config.AlertRuleConfig
+	condition cel.Program
+	summary   cel.Program
+	dedupKey  cel.Program
+	actions   []Action
+}
+
+// Engine evaluates the alerting rules against events.
+type Engine struct {
+	rules       []*rule
+	dedupWindow time.Duration
+	logger      *slog.Logger
+
+	mu   sync.Mutex
+	sent map[string]time.Time
+	// active holds the triggered alerts by object and dedup key.
+	active map[string]map[string]activeAlert
+}
+
+type activeAlert struct {
+	alert event.Alert
+	rule  *rule
+}
+
+// New compiles the rules of cfg, it returns nil when no rules are configured.
+func New(cfg config.AlertingConfig, logger *slog.Logger) (*Engine, error) {
+	if len(cfg.Rules) == 0 {
+		return nil, nil
+	}
+	actions := make(map[string]Action, len(cfg.Actions))
+	for _, actionCfg := range cfg.Actions {
+		action, err := NewAction(actionCfg)
+		if err != nil {
+			return nil, fmt.Errorf("alert action %q: %w", actionCfg.Name, err)
+		}
+		actions[actionCfg.Name] = action
+	}
+	env, err := cel.NewEnv(
+		cel.Variable("eventType", cel.StringType),
+		cel.Variable("group", cel.StringType),
+		cel.Variable("version", cel.StringType),
+		cel.Variable("resource", cel.StringType),
+		// namespace is a reserved word in CEL.
+		cel.Variable("ns", cel.StringType),
+		cel.Variable("name", cel.StringType),
+		cel.Variable("object", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("oldObject", cel.MapType(cel.StringType, cel.DynType)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	e := &Engine{dedupWindow: cfg.DedupWindow, logger: logger.With("component", "alerting"),
+		sent: make(map[string]time.Time), active: make(map[string]map[string]activeAlert)}
+	if e.dedupWindow <= 0 {
+		e.dedupWindow = defaultDedupWindow
+	}
+	for _, ruleCfg := range cfg.Rules {
+		r := &rule{cfg: ruleCfg}
+		if r.condition, err = compile(env, ruleCfg.Condition, cel.BoolType); err != nil {
+			return nil, fmt.Errorf("alert rule %q: condition: %w", ruleCfg.Name, err)
+		}
+		if ruleCfg.Summary != "" {
+			if r.summary, err = compile(env, ruleCfg.Summary, cel.StringType); err != nil {
+				return nil, fmt.Errorf("alert rule %q: summary: %w", ruleCfg.Name, err)
+			}
+		}
+		if ruleCfg.DedupKey != "" {
+			if r.dedupKey, err = compile(env, ruleCfg.DedupKey, cel.StringType); err != nil {
+				return nil, fmt.Errorf("alert rule %q: dedupKey: %w", ruleCfg.Name, err)
+			}
+		}
+		for _, name := range ruleCfg.Actions {
+			action, ok := actions[name]
+			if !ok {
+				return nil, fmt.Errorf("alert rule %q: unknown action %q", ruleCfg.Name, name)
+			}
+			r.actions = append(r.actions, action)
+		}
+		e.rules = append(e.rules, r)
+	}
+	return e, nil
+}
+
+func compile(env *cel.Env, expression string, resultType *cel.Type) (cel.Program, error) {
+	ast, issues := env.Compile(expression)
+	if issues.Err() != nil {
+		return nil, issues.Err()
+	}
+	if !ast.OutputType().IsExactType(resultType) && !ast.OutputType().IsExactType(cel.DynType) {
+		return nil, fmt.Errorf("expression must return %s, got %s", resultType, ast.OutputType())
+	}
+	return env.Program(ast)
+}
+
+// Process attaches the alerts of all matching rules to ev and notifies their
+// actions. Alerts of earlier events are resolved when their rule evaluates to
+// false for the same object or the object is deleted. A nil engine returns ev unchanged.
+func (e *Engine) Process(ctx context.Context, ev event.Event) event.Event {
+	if e == nil {
+		return ev
+	}
+	// Tenants watching the same object keep their own alerts.
+	key := ev.Tenant + ":" + ev.GVR.Resource + "." + ev.GVR.Group + "/" + objectName(ev)
+	triggered := make(map[string]bool)
+	evaluated := make(map[*rule]bool)
+	var vars map[string]interface{}
+	for _, r := range e.rules {
+		if !r.matches(ev) {
+			continue
+		}
+		if vars == nil {
+			vars = activation(ev)
+		}
+		alert, ok, err := r.evaluate(vars, ev)
+		if err != nil {
+			e.logger.Error("Failed to evaluate alert rule", "rule", r.cfg.Name, "name", ev.Name, "error", err)
+			continue
+		}
+		evaluated[r] = true
+		if !ok {
+			continue
+		}
+		triggered[alert.DedupKey] = true
+		ev.Alerts = append(ev.Alerts, alert)
+		if ev.Type != "Delete" {
+			e.activate(key, alert, r)
+		}
+		e.notify(ctx, r, alert, ev)
+	}
+	for _, resolved := range e.resolve(key, ev.Type == "Delete", evaluated, triggered) {
+		ev.Alerts = append(ev.Alerts, resolved.alert)
+		e.notify(ctx, resolved.rule, resolved.alert, ev)
+	}
+	return ev
+}
+
+func (e *Engine) activate(key string, alert event.Alert, r *rule) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.active[key] == nil {
+		e.active[key] = make(map[string]activeAlert)
+	}
+	e.active[key][alert.DedupKey] = activeAlert{alert: alert, rule: r}
+}
+
+// resolve removes and returns the active alerts of the object whose rule was
+// evaluated without triggering them again, or all of them when the object is gone.
+func (e *Engine) resolve(key string, deleted bool, evaluated map[*rule]bool, triggered map[string]bool) []activeAlert {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var resolved []activeAlert
+	for dedupKey, active := range e.active[key] {
+		if !deleted && (!evaluated[active.rule] || triggered[dedupKey]) {
+			continue
+		}
+		if deleted && triggered[dedupKey] {
+			// The Delete event raised the same alert again, it is sent as triggered.
+			delete(e.active[key], dedupKey)
+			continue
+		}
+		delete(e.active[key], dedupKey)
+		active.alert.Status = event.AlertResolved
+		resolved = append(resolved, active)
+	}
+	if len(e.active[key]) == 0 {
+		delete(e.active, key)
+	}
+	return resolved
+}
+
+func (e *Engine) notify(ctx context.Context, r *rule, alert event.Alert, ev event.Event) {
+	for _, action := range r.actions {
+		dedupKey := ev.Tenant + ":" + action.Name() + "/" + alert.DedupKey
+		if alert.Status == event.AlertResolved {
+			e.forget(dedupKey)
+		} else if !action.Deduplicates() && e.suppressed(dedupKey) {
+			continue
+		}
+		if err := action.Notify(ctx, alert, ev); err != nil {
+			e.logger.Error("Failed to notify alert action", "rule", r.cfg.Name, "action", action.Name(), "error", err)
+		}
+	}
+}
+
+func (e *Engine) forget(key string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.sent, key)
+}
+
+// suppressed reports whether key was notified within the dedup window and records it otherwise.
+func (e *Engine) suppressed(key string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := time.Now()
+	for k, sent := range e.sent {
+		if now.Sub(sent) >= e.dedupWindow {
+			delete(e.sent, k)
+		}
+	}
+	if _, ok := e.sent[key]; ok {
+		return true
+	}
+	e.sent[key] = now
+	return false
+}
+
+func (r *rule) matches(ev event.Event) bool {
+	if r.cfg.Resource != "" && (r.cfg.Resource != ev.GVR.Resource || r.cfg.Group != ev.GVR.Group) {
+		return false
+	}
+	if len(r.cfg.EventTypes) == 0 {
+		return true
+	}
+	for _, eventType := range r.cfg.EventTypes {
+		if eventType == ev.Type {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *rule) evaluate(vars map[string]interface{}, ev event.Event) (event.Alert, bool, error) {
+	out, _, err := r.condition.Eval(vars)
+	if err != nil {
+		return event.Alert{}, false, err
+	}
+	if matched, ok := out.Value().(bool); !ok || !matched {
+		return event.Alert{}, false, nil
+	}
+	alert := event.Alert{
+		Rule:     r.cfg.Name,
+		Status:   event.AlertTriggered,
+		Severity: r.cfg.Severity,
+		Summary:  fmt.Sprintf("%s: %s %s", r.cfg.Name, ev.GVR.Resource, objectName(ev)),
+		DedupKey: r.cfg.Name + "/" + ev.GVR.Resource + "/" + objectName(ev),
+	}
+	if r.summary != nil {
+		if alert.Summary, err = evalString(r.summary, vars); err != nil {
+			return event.Alert{}, false, err
+		}
+	}
+	if r.dedupKey != nil {
+		if alert.DedupKey, err = evalString(r.dedupKey, vars); err != nil {
+			return event.Alert{}, false, err
+		}
+	}
+	return alert, true, nil
+}
+
+func evalString(program cel.Program, vars map[string]interface{}) (string, error) {
+	out, _, err := program.Eval(vars)
+	if err != nil {
+		return "", err
+	}
+	value, ok := out.Value().(string)
+	if !ok {
+		return "", fmt.Errorf("expression returned %T, not a string", out.Value())
+	}
+	return value, nil
+}
+
+func activation(ev event.Event) map[string]interface{} {
+	object, oldObject := ev.Object, ev.OldObject
+	if object == nil {
+		object = map[string]interface{}{}
+	}
+	if oldObject == nil {
+		oldObject = map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"eventType": ev.Type,
+		"group":     ev.GVR.Group,
+		"version":   ev.GVR.Version,
+		"resource":  ev.GVR.Resource,
+		"ns":        ev.Namespace,
+		"name":      ev.Name,
+		"object":    object,
+		"oldObject": oldObject,
+	}
+}
+
+func objectName(ev event.Event) string {
+	if ev.Namespace == "" {
+		return ev.Name
+	}
+	return ev.Namespace + "/" + ev.Name
+}
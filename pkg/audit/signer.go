@@ -0,0 +1,196 @@
+// Package audit signs events and chains them per resource, so that an
+// archived event stream is tamper-evident.
+package audit
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+// Signer signs events. Without a state file the chain starts anew with every
+// Signer, the first event of a resource after a restart has no PrevHash. The
+// chains only prove that no event is missing up to the last one of a stream,
+// removing the newest events of a resource is not detected.
+type Signer struct {
+	cfg        config.SigningConfig
+	hmacKey    []byte
+	privateKey ed25519.PrivateKey
+
+	mu   sync.Mutex
+	last map[string]string
+}
+
+// NewSigner returns nil when signing is disabled.
+func NewSigner(cfg config.SigningConfig) (*Signer, error) {
+	if cfg.Algorithm == "" {
+		return nil, nil
+	}
+	s := &Signer{cfg: cfg, last: make(map[string]string)}
+	if err := s.loadState(); err != nil {
+		return nil, fmt.Errorf("failed to load signing state: %w", err)
+	}
+	switch cfg.Algorithm {
+	case config.SigningHMACSHA256:
+		key, err := hmacKey(cfg)
+		if err != nil {
+			return nil, err
+		}
+		s.hmacKey = key
+	case config.SigningEd25519:
+		if cfg.KeyFile == "" {
+			return nil, fmt.Errorf("ed25519 signing requires signing.keyFile")
+		}
+		key, err := readPEM(cfg.KeyFile, x509.ParsePKCS8PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		privateKey, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("%s is not an ed25519 private key", cfg.KeyFile)
+		}
+		s.privateKey = privateKey
+	default:
+		return nil, fmt.Errorf("unknown signing algorithm %q, expected hmac-sha256 or ed25519", cfg.Algorithm)
+	}
+	return s, nil
+}
+
+// Sign sets the signature of ev. It is a no-op on a nil Signer.
+func (s *Signer) Sign(ev *event.Event) error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := chainKey(*ev)
+	ev.Signature = &event.Signature{Algorithm: s.cfg.Algorithm, KeyID: s.cfg.KeyID, PrevHash: s.last[key]}
+	hash, err := Hash(*ev)
+	if err != nil {
+		return err
+	}
+	ev.Signature.Hash = hash
+	if s.privateKey != nil {
+		ev.Signature.Value = base64.StdEncoding.EncodeToString(ed25519.Sign(s.privateKey, []byte(hash)))
+	} else {
+		ev.Signature.Value = base64.StdEncoding.EncodeToString(hmacSHA256(s.hmacKey, hash))
+	}
+	s.last[key] = hash
+	return s.saveState()
+}
+
+// loadState reads the last hashes of the state file, if any.
+func (s *Signer) loadState() error {
+	if s.cfg.StateFile == "" {
+		return nil
+	}
+	data, err := os.ReadFile(s.cfg.StateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &s.last)
+}
+
+// saveState writes the last hashes to the state file after every event, so
+// that a crash does not break the chains. It must be called with s.mu held.
+func (s *Signer) saveState() error {
+	if s.cfg.StateFile == "" {
+		return nil
+	}
+	data, err := json.Marshal(s.last)
+	if err != nil {
+		return err
+	}
+	tmp := s.cfg.StateFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to save signing state: %w", err)
+	}
+	if err := os.Rename(tmp, s.cfg.StateFile); err != nil {
+		return fmt.Errorf("failed to save signing state: %w", err)
+	}
+	return nil
+}
+
+// Hash returns the SHA-256 of the JSON encoding of ev with the hash and value
+// of its signature empty.
+func Hash(ev event.Event) (string, error) {
+	if ev.Signature != nil {
+		signature := *ev.Signature
+		signature.Hash, signature.Value = "", ""
+		ev.Signature = &signature
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// chainKey is the resource whose events form a chain, e.g. "deployments.apps",
+// prefixed with the tenant, e.g. "team-a:deployments.apps".
+func chainKey(ev event.Event) string {
+	key := ev.GVR.Resource
+	if ev.GVR.Group != "" {
+		key += "." + ev.GVR.Group
+	}
+	if ev.Tenant != "" {
+		key = ev.Tenant + ":" + key
+	}
+	return key
+}
+
+func hmacKey(cfg config.SigningConfig) ([]byte, error) {
+	if cfg.Key != "" {
+		return []byte(cfg.Key), nil
+	}
+	if cfg.KeyFile == "" {
+		return nil, fmt.Errorf("hmac-sha256 signing requires signing.key or signing.keyFile")
+	}
+	data, err := os.ReadFile(cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return nil, fmt.Errorf("%s is empty", cfg.KeyFile)
+	}
+	return []byte(key), nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func readPEM(path string, parse func([]byte) (interface{}, error)) (interface{}, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New(path + " is not PEM encoded")
+	}
+	key, err := parse(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
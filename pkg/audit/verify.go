@@ -0,0 +1,145 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+// maxLineSize bounds a single event like the event store does.
+const maxLineSize = 16 << 20
+
+// Verifier checks signatures and the chains of a stream of events.
+type Verifier struct {
+	hmacKey   []byte
+	publicKey ed25519.PublicKey
+	algorithm string
+	last      map[string]string
+	// AllowRestarts accepts events without PrevHash after other events of
+	// their resource, i.e. restarts of a watcher without signing.stateFile.
+	// Events lost around a restart are not detected then.
+	AllowRestarts bool
+}
+
+// NewVerifier uses the key of cfg, ed25519 signatures are checked with
+// PublicKeyFile or else the public part of KeyFile.
+func NewVerifier(cfg config.SigningConfig) (*Verifier, error) {
+	v := &Verifier{algorithm: cfg.Algorithm, last: make(map[string]string)}
+	switch cfg.Algorithm {
+	case config.SigningHMACSHA256:
+		key, err := hmacKey(cfg)
+		if err != nil {
+			return nil, err
+		}
+		v.hmacKey = key
+	case config.SigningEd25519:
+		if cfg.PublicKeyFile == "" {
+			signer, err := NewSigner(cfg)
+			if err != nil {
+				return nil, err
+			}
+			v.publicKey = signer.privateKey.Public().(ed25519.PublicKey)
+			break
+		}
+		key, err := readPEM(cfg.PublicKeyFile, x509.ParsePKIXPublicKey)
+		if err != nil {
+			return nil, err
+		}
+		publicKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, fmt.Errorf("%s is not an ed25519 public key", cfg.PublicKeyFile)
+		}
+		v.publicKey = publicKey
+	case "":
+		return nil, fmt.Errorf("signing.algorithm is required")
+	default:
+		return nil, fmt.Errorf("unknown signing algorithm %q, expected hmac-sha256 or ed25519", cfg.Algorithm)
+	}
+	return v, nil
+}
+
+// Verify checks ev, which must follow the events passed before. The first
+// event of a resource may link to an event outside of the stream.
+func (v *Verifier) Verify(ev event.Event) error {
+	signature := ev.Signature
+	if signature == nil {
+		return fmt.Errorf("event is not signed")
+	}
+	if signature.Algorithm != v.algorithm {
+		return fmt.Errorf("signed with %s, expected %s", signature.Algorithm, v.algorithm)
+	}
+	hash, err := Hash(ev)
+	if err != nil {
+		return err
+	}
+	if hash != signature.Hash {
+		return fmt.Errorf("hash mismatch, the event was modified")
+	}
+	value, err := base64.StdEncoding.DecodeString(signature.Value)
+	if err != nil {
+		return fmt.Errorf("invalid signature: %w", err)
+	}
+	var valid bool
+	if v.publicKey != nil {
+		valid = ed25519.Verify(v.publicKey, []byte(hash), value)
+	} else {
+		valid = hmac.Equal(value, hmacSHA256(v.hmacKey, hash))
+	}
+	if !valid {
+		return fmt.Errorf("invalid signature")
+	}
+	key := chainKey(ev)
+	last, seen := v.last[key]
+	v.last[key] = hash
+	if !seen {
+		return nil
+	}
+	if signature.PrevHash == "" {
+		if v.AllowRestarts {
+			return nil
+		}
+		return fmt.Errorf("chain of %s restarted, events before it may be missing", key)
+	}
+	if signature.PrevHash != last {
+		return fmt.Errorf("chain of %s broken, events are missing or reordered", key)
+	}
+	return nil
+}
+
+// VerifyStream checks the JSON lines of r and reports the problems by line.
+// Numbers are kept as written, so that hashes can be recomputed exactly.
+func (v *Verifier) VerifyStream(r io.Reader) (int, []error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64<<10), maxLineSize)
+	var errs []error
+	count := 0
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		count++
+		decoder := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		decoder.UseNumber()
+		var ev event.Event
+		if err := decoder.Decode(&ev); err != nil {
+			errs = append(errs, fmt.Errorf("line %d: %w", line, err))
+			continue
+		}
+		if err := v.Verify(ev); err != nil {
+			errs = append(errs, fmt.Errorf("line %d (%s %s/%s): %w", line, ev.Type, ev.Namespace, ev.Name, err))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		errs = append(errs, err)
+	}
+	return count, errs
+}
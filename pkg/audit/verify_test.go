@@ -0,0 +1,154 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+var testSigning = config.SigningConfig{Algorithm: config.SigningHMACSHA256, Key: "secret"}
+
+func testEvent(tenant, resource, name string) event.Event {
+	return event.Event{
+		SchemaVersion: event.SchemaVersion,
+		Tenant:        tenant,
+		GVR:           event.GVR{Version: "v1", Resource: resource},
+		Namespace:     "default",
+		Name:          name,
+		Type:          "Update",
+		Object:        map[string]interface{}{"spec": map[string]interface{}{"replicas": 3}},
+	}
+}
+
+// signAll signs the events in order with signer.
+func signAll(t *testing.T, signer *Signer, events ...event.Event) []event.Event {
+	t.Helper()
+	for i := range events {
+		if err := signer.Sign(&events[i]); err != nil {
+			t.Fatalf("Sign: %v", err)
+		}
+	}
+	return events
+}
+
+func stream(t *testing.T, events ...event.Event) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	for _, ev := range events {
+		data, err := json.Marshal(ev)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf.Write(append(data, '\n'))
+	}
+	return &buf
+}
+
+func newSigner(t *testing.T, cfg config.SigningConfig) *Signer {
+	t.Helper()
+	signer, err := NewSigner(cfg)
+	if err != nil {
+		t.Fatalf("NewSigner: %v", err)
+	}
+	return signer
+}
+
+func TestVerifyStream(t *testing.T) {
+	signed := signAll(t, newSigner(t, testSigning),
+		testEvent("", "pods", "a"),
+		testEvent("", "pods", "b"),
+		testEvent("", "configmaps", "c"),
+		testEvent("", "pods", "d"),
+	)
+	modified := append([]event.Event(nil), signed...)
+	modified[1].Name = "x"
+	tests := []struct {
+		name   string
+		events []event.Event
+		key    string
+		want   []string
+	}{
+		{name: "intact", events: signed},
+		{name: "tail removed", events: signed[:3]},
+		{name: "head removed", events: signed[1:]},
+		{name: "modified", events: modified, want: []string{"line 2 (Update default/x): hash mismatch", "line 4 (Update default/d): chain of pods broken"}},
+		{name: "removed", events: []event.Event{signed[0], signed[2], signed[3]}, want: []string{"line 3 (Update default/d): chain of pods broken"}},
+		{name: "reordered", events: []event.Event{signed[1], signed[0], signed[2], signed[3]}, want: []string{"line 2", "line 4"}},
+		{name: "wrong key", events: signed[:1], key: "other", want: []string{"line 1 (Update default/a): invalid signature"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testSigning
+			if tt.key != "" {
+				cfg.Key = tt.key
+			}
+			verifier, err := NewVerifier(cfg)
+			if err != nil {
+				t.Fatalf("NewVerifier: %v", err)
+			}
+			count, errs := verifier.VerifyStream(stream(t, tt.events...))
+			if count != len(tt.events) {
+				t.Errorf("verified %d events, want %d", count, len(tt.events))
+			}
+			if len(errs) != len(tt.want) {
+				t.Fatalf("errors %v, want %d containing %q", errs, len(tt.want), tt.want)
+			}
+			for i, err := range errs {
+				if !strings.Contains(err.Error(), tt.want[i]) {
+					t.Errorf("error %q, want it to contain %q", err, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestVerifyRestarts(t *testing.T) {
+	before := signAll(t, newSigner(t, testSigning), testEvent("", "pods", "a"))
+	after := signAll(t, newSigner(t, testSigning), testEvent("", "pods", "b"))
+	events := append(before, after...)
+
+	verifier, _ := NewVerifier(testSigning)
+	if _, errs := verifier.VerifyStream(stream(t, events...)); len(errs) != 1 || !strings.Contains(errs[0].Error(), "chain of pods restarted") {
+		t.Errorf("errors %v, want the restart reported", errs)
+	}
+	verifier, _ = NewVerifier(testSigning)
+	verifier.AllowRestarts = true
+	if _, errs := verifier.VerifyStream(stream(t, events...)); len(errs) != 0 {
+		t.Errorf("errors %v with restarts allowed", errs)
+	}
+}
+
+func TestSignerStateFile(t *testing.T) {
+	cfg := testSigning
+	cfg.StateFile = filepath.Join(t.TempDir(), "signing.json")
+	before := signAll(t, newSigner(t, cfg), testEvent("", "pods", "a"))
+	after := signAll(t, newSigner(t, cfg), testEvent("", "pods", "b"))
+	if after[0].Signature.PrevHash != before[0].Signature.Hash {
+		t.Fatalf("chain did not continue across restarts: prevHash %q, want %q", after[0].Signature.PrevHash, before[0].Signature.Hash)
+	}
+	verifier, _ := NewVerifier(cfg)
+	if _, errs := verifier.VerifyStream(stream(t, append(before, after...)...)); len(errs) != 0 {
+		t.Errorf("errors %v", errs)
+	}
+}
+
+func TestTenantChains(t *testing.T) {
+	signed := signAll(t, newSigner(t, testSigning),
+		testEvent("team-a", "pods", "a"),
+		testEvent("team-b", "pods", "a"),
+		testEvent("team-a", "pods", "b"),
+	)
+	if signed[1].Signature.PrevHash != "" {
+		t.Errorf("first event of team-b links to %q, want a chain of its own", signed[1].Signature.PrevHash)
+	}
+	// Dropping the events of one tenant keeps the chains of the others intact.
+	verifier, _ := NewVerifier(testSigning)
+	if _, errs := verifier.VerifyStream(stream(t, signed[0], signed[2])); len(errs) != 0 {
+		t.Errorf("errors %v", errs)
+	}
+}
@@ -0,0 +1,270 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadEnv(t *testing.T) {
+	t.Setenv("WATCHER_CLUSTER", "prod")
+	t.Setenv("WATCHER_BURST", "5")
+	t.Setenv("WATCHER_EMPTY", "")
+	path := writeConfig(t, `
+cluster: ${WATCHER_CLUSTER}
+logLevel: ${WATCHER_UNSET:-warn}
+# ${WATCHER_COMMENTED} is not expanded in comments
+common:
+  rateLimit:
+    burst: ${WATCHER_BURST}
+  namespaces: ["${WATCHER_EMPTY:-default}", "${WATCHER_EMPTY}x"]
+resources:
+  - resource: pods
+    version: v1
+    transform:
+      jq: "$${keep} | $eventType"
+`)
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Cluster != "prod" {
+		t.Errorf("cluster = %q, want prod", cfg.Cluster)
+	}
+	if cfg.LogLevel != "warn" {
+		t.Errorf("logLevel = %q, want the fallback warn", cfg.LogLevel)
+	}
+	if cfg.Common.RateLimit.Burst != 5 {
+		t.Errorf("burst = %d, want 5", cfg.Common.RateLimit.Burst)
+	}
+	if want := []string{"default", "x"}; !reflect.DeepEqual(cfg.Common.Namespaces, want) {
+		t.Errorf("namespaces = %v, want %v", cfg.Common.Namespaces, want)
+	}
+	if got, want := cfg.Resources[0].Transform.JQ, "${keep} | $eventType"; got != want {
+		t.Errorf("jq = %q, want %q", got, want)
+	}
+}
+
+func TestLoadMissingEnv(t *testing.T) {
+	path := writeConfig(t, "cluster: ${WATCHER_MISSING_A}\nlogLevel: ${WATCHER_MISSING_B}\n")
+	_, err := Load(path)
+	if err == nil || !strings.Contains(err.Error(), "WATCHER_MISSING_A, WATCHER_MISSING_B") {
+		t.Fatalf("Load() error = %v, want both missing variables", err)
+	}
+}
+
+func TestLoadStrict(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name:    "unknown top-level field",
+			content: "clustr: prod\n",
+			want:    []string{`line 1: unknown field "clustr"`, `did you mean "cluster"?`},
+		},
+		{
+			name:    "unknown nested field",
+			content: "resources:\n  - resource: pods\n    version: v1\n    debounse: 1s\n",
+			want:    []string{`line 4: unknown field "debounse" in resources[0]`},
+		},
+		{
+			name:    "type mismatch",
+			content: "queue:\n  capacity: many\n",
+			want:    []string{"line 2:", "many"},
+		},
+		{
+			name:    "all errors",
+			content: "clustr: prod\nqueue:\n  capacity: many\n",
+			want:    []string{`unknown field "clustr"`, "line 3:"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, tt.content))
+			if err == nil {
+				t.Fatal("Load() succeeded, want an error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Load() error = %q, want it to contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+const profilesConfig = `
+profile: staging
+common:
+  resync: 1m
+  namespaces: [default]
+sinks:
+  - name: log
+    type: webhook
+    webhook:
+      url: http://log
+profiles:
+  base:
+    common:
+      namespaces: [apps, tools]
+    sinks:
+      - name: log
+        webhook:
+          timeout: 5s
+  staging:
+    extends: base
+    cluster: staging
+  prod:
+    extends: base
+    cluster: prod
+    sinks:
+      - name: oncall
+        type: pagerduty
+`
+
+func TestLoadProfiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		overrides []Override
+		cluster   string
+		sinks     []string
+	}{
+		{name: "selected in the file", cluster: "staging", sinks: []string{"log"}},
+		{name: "selected by flag", overrides: []Override{{Path: "profile", Value: "prod"}}, cluster: "prod", sinks: []string{"log", "oncall"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load(writeConfig(t, profilesConfig), tt.overrides...)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			if cfg.Cluster != tt.cluster {
+				t.Errorf("cluster = %q, want %q", cfg.Cluster, tt.cluster)
+			}
+			var sinks []string
+			for _, sink := range cfg.Sinks {
+				sinks = append(sinks, sink.Name)
+			}
+			if !reflect.DeepEqual(sinks, tt.sinks) {
+				t.Errorf("sinks = %v, want %v", sinks, tt.sinks)
+			}
+			// Lists of values are replaced, maps merged.
+			if want := []string{"apps", "tools"}; !reflect.DeepEqual(cfg.Common.Namespaces, want) {
+				t.Errorf("namespaces = %v, want %v", cfg.Common.Namespaces, want)
+			}
+			if cfg.Common.Resync == nil || *cfg.Common.Resync != time.Minute {
+				t.Errorf("resync = %v, want the value of the file", cfg.Common.Resync)
+			}
+			webhook := cfg.Sinks[0].Webhook
+			if webhook == nil || webhook.URL != "http://log" || webhook.Timeout != 5*time.Second {
+				t.Errorf("webhook = %+v, want the inherited sink merged", webhook)
+			}
+		})
+	}
+}
+
+func TestLoadProfileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "unknown", content: "profile: dev\nprofiles:\n  prod: {}\n", want: `unknown profile "dev"`},
+		{name: "cycle", content: "profile: a\nprofiles:\n  a: {extends: b}\n  b: {extends: a}\n", want: "extends itself"},
+		{name: "not a map", content: "profiles:\n  prod: [a]\n", want: `line 2: profile "prod" must be a map`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Load(writeConfig(t, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Load() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseOverride(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Override
+		wantErr bool
+	}{
+		{in: "cluster=prod", want: Override{Path: "cluster", Value: "prod"}},
+		{in: "sinks.log.webhook.url=http://a?b=c", want: Override{Path: "sinks.log.webhook.url", Value: "http://a?b=c"}},
+		{in: "cluster=", want: Override{Path: "cluster"}},
+		{in: "cluster", wantErr: true},
+		{in: "=prod", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseOverride(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseOverride(%q) = %+v, want an error", tt.in, got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ParseOverride(%q) = %+v, %v, want %+v", tt.in, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadOverrides(t *testing.T) {
+	path := writeConfig(t, `
+resources:
+  - resource: pods
+    version: v1
+sinks:
+  - name: log
+    type: webhook
+    webhook:
+      url: http://log
+`)
+	cfg, err := Load(path,
+		Override{Path: "cluster", Value: "prod"},
+		Override{Path: "sinks.log.webhook.url", Value: "http://other"},
+		Override{Path: "resources.0.namespaces", Value: "[a, b]"},
+		Override{Path: "queue.capacity", Value: "10"},
+	)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Cluster != "prod" {
+		t.Errorf("cluster = %q, want prod", cfg.Cluster)
+	}
+	if got := cfg.Sinks[0].Webhook.URL; got != "http://other" {
+		t.Errorf("url = %q, want http://other", got)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(cfg.Resources[0].Namespaces, want) {
+		t.Errorf("namespaces = %v, want %v", cfg.Resources[0].Namespaces, want)
+	}
+	if cfg.Queue.Capacity != 10 {
+		t.Errorf("capacity = %d, want 10", cfg.Queue.Capacity)
+	}
+
+	for _, override := range []Override{
+		{Path: "sinks.missing.type", Value: "webhook"},
+		{Path: "resources.3.version", Value: "v2"},
+		{Path: "queue.capacity", Value: "many"},
+	} {
+		if _, err := Load(path, override); err == nil {
+			t.Errorf("Load() with override %s=%s succeeded, want an error", override.Path, override.Value)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LoadDir merges all *.yaml files of dir in lexical order, e.g. a common file
+// owned by the platform team and one resource file per application team.
+// Maps are merged, lists are concatenated and later files win for single values.
+func LoadDir(dir string, overrides ...Override) (*Config, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no *.yaml files in %s", dir)
+	}
+	root := &yaml.Node{}
+	for _, path := range paths {
+		node, err := readNode(path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		// Decode every file on its own, errors of the merged document can not
+		// be traced back to a file. Profiles are applied once the files are
+		// merged, a file may select the profile of another one.
+		stripped, profiles := stripProfiles(node)
+		if errs := checkProfiles(profiles); len(errs) > 0 {
+			return nil, inFile(path, errors.Join(errs...))
+		}
+		if _, err := decode(stripped); err != nil {
+			return nil, inFile(path, err)
+		}
+		mergeNode(root, node)
+	}
+	root, err = applyProfile(root, selectedProfile(overrides))
+	if err != nil {
+		return nil, err
+	}
+	if err := applyOverrides(root, overrides); err != nil {
+		return nil, err
+	}
+	return decode(root)
+}
+
+// inFile prefixes every joined error with path.
+func inFile(path string, err error) error {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	var errs []error
+	for _, err := range joined.Unwrap() {
+		errs = append(errs, fmt.Errorf("%s: %w", path, err))
+	}
+	return errors.Join(errs...)
+}
+
+// mergeNode merges src into dst. Null values, e.g. a file of comments only,
+// leave dst unchanged.
+func mergeNode(dst, src *yaml.Node) {
+	switch {
+	case src.Kind == 0 || isNull(src):
+	case dst.Kind == 0 || isNull(dst):
+		*dst = *src
+	case dst.Kind == yaml.DocumentNode && src.Kind == yaml.DocumentNode:
+		mergeNode(dst.Content[0], src.Content[0])
+	case dst.Kind == yaml.MappingNode && src.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			key, value := src.Content[i], src.Content[i+1]
+			if existing := mappingValue(dst, key.Value); existing != nil {
+				mergeNode(existing, value)
+			} else {
+				dst.Content = append(dst.Content, key, value)
+			}
+		}
+	case dst.Kind == yaml.SequenceNode && src.Kind == yaml.SequenceNode:
+		dst.Content = append(dst.Content, src.Content...)
+	default:
+		*dst = *src
+	}
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func isNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Tag == "!!null"
+}
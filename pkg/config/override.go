@@ -0,0 +1,85 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Override replaces a single value of the config file, e.g. from a command
+// line flag. Path is a dot separated list of keys, list entries are selected
+// by index or by their name field: "sinks.oncall.pagerduty.url". Value is
+// parsed as YAML, so "[a, b]" sets a list.
+type Override struct {
+	Path  string
+	Value string
+}
+
+// ParseOverride parses "path=value".
+func ParseOverride(s string) (Override, error) {
+	path, value, ok := strings.Cut(s, "=")
+	if !ok || path == "" {
+		return Override{}, fmt.Errorf("invalid override %q, expected path=value", s)
+	}
+	return Override{Path: path, Value: value}, nil
+}
+
+func applyOverrides(root *yaml.Node, overrides []Override) error {
+	if len(overrides) == 0 {
+		return nil
+	}
+	if root.Kind == 0 {
+		*root = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	for _, override := range overrides {
+		var value yaml.Node
+		if err := yaml.Unmarshal([]byte(override.Value), &value); err != nil {
+			return fmt.Errorf("override %s: %w", override.Path, err)
+		}
+		node := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str"}
+		if value.Kind == yaml.DocumentNode {
+			node = value.Content[0]
+		}
+		if err := setNode(root.Content[0], strings.Split(override.Path, "."), node); err != nil {
+			return fmt.Errorf("override %s: %w", override.Path, err)
+		}
+	}
+	return nil
+}
+
+func setNode(node *yaml.Node, path []string, value *yaml.Node) error {
+	if len(path) == 0 {
+		*node = *value
+		return nil
+	}
+	key := path[0]
+	if isNull(node) {
+		*node = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	}
+	switch node.Kind {
+	case yaml.MappingNode:
+		if existing := mappingValue(node, key); existing != nil {
+			return setNode(existing, path[1:], value)
+		}
+		child := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, child)
+		return setNode(child, path[1:], value)
+	case yaml.SequenceNode:
+		if index, err := strconv.Atoi(key); err == nil {
+			if index < 0 || index >= len(node.Content) {
+				return fmt.Errorf("index %d out of range", index)
+			}
+			return setNode(node.Content[index], path[1:], value)
+		}
+		for _, item := range node.Content {
+			if name := mappingValue(item, "name"); name != nil && name.Value == key {
+				return setNode(item, path[1:], value)
+			}
+		}
+		return fmt.Errorf("no entry named %q", key)
+	default:
+		return fmt.Errorf("%q is not a map or list", key)
+	}
+}
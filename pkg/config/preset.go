@@ -0,0 +1,164 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+)
+
+const (
+	// PresetSecurity watches RBAC, Secrets, admission webhooks, privileged
+	// Pods and NetworkPolicies.
+	PresetSecurity = "security"
+	// PresetImages emits ImageChanged events of workloads and CronJobs.
+	PresetImages = "images"
+	// PresetScaling emits Scaled events of workloads and HPAs.
+	PresetScaling = "scaling"
+	// PresetNodes emits the lifecycle events of Nodes.
+	PresetNodes = "nodes"
+)
+
+var presets = map[string]func() []ResourceConfig{
+	PresetSecurity: securityPreset,
+	PresetImages:   imagesPreset,
+	PresetScaling:  scalingPreset,
+	PresetNodes:    nodesPreset,
+}
+
+// privilegedPodScript keeps the events of Pods that run privileged containers
+// or share host namespaces or paths, updates only when these change. The
+// findings are added as the "privileges" annotation.
+const privilegedPodScript = `
+def privileges(pod):
+    spec = pod.get("spec") or {}
+    found = []
+    for key in ["hostNetwork", "hostPID", "hostIPC"]:
+        if spec.get(key) == True:
+            found.append(key)
+    for volume in spec.get("volumes") or []:
+        if "hostPath" in volume:
+            found.append("hostPath:" + volume.get("name", ""))
+    for kind in ["initContainers", "containers", "ephemeralContainers"]:
+        for container in spec.get(kind) or []:
+            if (container.get("securityContext") or {}).get("privileged") == True:
+                found.append("privileged:" + container.get("name", ""))
+    return found
+
+def process(event_type, old, new):
+    found = privileges(new)
+    if not found or (old and privileges(old) == found):
+        return False
+    return {"annotations": {"privileges": ",".join(found)}}
+`
+
+func securityPreset() []ResourceConfig {
+	// Changes are attributed to their field manager, which is kept out of
+	// the payload.
+	audited := func(group, resource string) ResourceConfig {
+		return ResourceConfig{
+			Group:         group,
+			Version:       "v1",
+			Resource:      resource,
+			FilterConfig:  FilterConfig{ExcludePaths: []string{"metadata.managedFields"}},
+			CacheConfig:   CacheConfig{StripLastAppliedAnnotation: true},
+			PayloadConfig: PayloadConfig{IncludeOldObject: true, ChangedBy: true},
+		}
+	}
+	resources := []ResourceConfig{
+		audited("rbac.authorization.k8s.io", "clusterrolebindings"),
+		audited("rbac.authorization.k8s.io", "rolebindings"),
+		audited("rbac.authorization.k8s.io", "clusterroles"),
+		audited("rbac.authorization.k8s.io", "roles"),
+		audited("admissionregistration.k8s.io", "validatingwebhookconfigurations"),
+		audited("admissionregistration.k8s.io", "mutatingwebhookconfigurations"),
+		audited("networking.k8s.io", "networkpolicies"),
+	}
+	// Secret values never leave the API server, the last-applied annotation
+	// would carry them as well.
+	secrets := audited("", "secrets")
+	secrets.MetadataOnly = true
+	secrets.IncludeOldObject = false
+	pods := audited("", "pods")
+	pods.ExcludePaths = append(pods.ExcludePaths, "status", "spec.containers[*].env", "spec.initContainers[*].env")
+	pods.IncludeOldObject = false
+	pods.ChangedBy = false
+	pods.Script = ScriptConfig{Source: privilegedPodScript}
+	return append(resources, secrets, pods)
+}
+
+func imagesPreset() []ResourceConfig {
+	watched := func(group, resource string) ResourceConfig {
+		return ResourceConfig{
+			Group:         group,
+			Version:       "v1",
+			Resource:      resource,
+			ImageChanges:  true,
+			FilterConfig:  FilterConfig{ExcludePaths: []string{"metadata.managedFields", "status"}},
+			CacheConfig:   CacheConfig{StripLastAppliedAnnotation: true},
+			PayloadConfig: PayloadConfig{ChangedBy: true},
+		}
+	}
+	return []ResourceConfig{
+		watched("apps", "deployments"),
+		watched("apps", "statefulsets"),
+		watched("apps", "daemonsets"),
+		watched("batch", "cronjobs"),
+	}
+}
+
+func scalingPreset() []ResourceConfig {
+	watched := func(group, version, resource string, includePaths ...string) ResourceConfig {
+		return ResourceConfig{
+			Group:        group,
+			Version:      version,
+			Resource:     resource,
+			ScaleChanges: true,
+			FilterConfig: FilterConfig{IncludePaths: includePaths},
+		}
+	}
+	return []ResourceConfig{
+		watched("apps", "v1", "deployments", "metadata.labels", "spec.replicas"),
+		watched("apps", "v1", "statefulsets", "metadata.labels", "spec.replicas"),
+		watched("autoscaling", "v2", "horizontalpodautoscalers",
+			"metadata.labels", "spec.scaleTargetRef", "spec.minReplicas", "spec.maxReplicas",
+			"status.currentReplicas", "status.desiredReplicas"),
+	}
+}
+
+func nodesPreset() []ResourceConfig {
+	return []ResourceConfig{{
+		Version:       "v1",
+		Resource:      "nodes",
+		NodeLifecycle: true,
+		FilterConfig: FilterConfig{
+			IncludePaths: []string{"metadata.labels", "spec.unschedulable", "spec.taints", "status.nodeInfo"},
+		},
+		PayloadConfig: PayloadConfig{ChangedBy: true},
+	}}
+}
+
+// expandPresets appends the entries of cfg.Presets to cfg.Resources. An entry
+// of the same group and resource in the config replaces the preset one,
+// presets watching the same resource each add their entry.
+func expandPresets(cfg *Config) error {
+	configured := slices.Clone(cfg.Resources)
+	var seen []string
+	for _, name := range cfg.Presets {
+		preset, ok := presets[name]
+		if !ok {
+			return fmt.Errorf("unknown preset %q, expected %s, %s, %s or %s", name, PresetSecurity, PresetImages, PresetScaling, PresetNodes)
+		}
+		// Config directories concatenate the lists of their files.
+		if slices.Contains(seen, name) {
+			continue
+		}
+		seen = append(seen, name)
+		for _, resource := range preset() {
+			if !slices.ContainsFunc(configured, func(rc ResourceConfig) bool {
+				return rc.Group == resource.Group && rc.Resource == resource.Resource
+			}) {
+				cfg.Resources = append(cfg.Resources, resource)
+			}
+		}
+	}
+	return nil
+}
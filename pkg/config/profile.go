@@ -0,0 +1,196 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	profileKey  = "profile"
+	profilesKey = "profiles"
+	extendsKey  = "extends"
+)
+
+// selectedProfile is the profile selected by overrides, e.g. -profile.
+func selectedProfile(overrides []Override) string {
+	var name string
+	for _, override := range overrides {
+		if override.Path == profileKey {
+			name = override.Value
+		}
+	}
+	return name
+}
+
+// applyProfile returns a copy of root without its profiles and with the
+// selected profile merged over it, after the profiles it extends. selected
+// replaces the profile key when it is not empty. Profiles are overlays of the
+// config, e.g. a base profile and one per environment extending it:
+//
+//	profile: prod
+//	profiles:
+//	  base:
+//	    common: {resync: 10m}
+//	  prod:
+//	    extends: base
+//	    sinks: [{name: oncall, type: pagerduty}]
+//
+// Maps are merged and single values replaced. Lists of maps such as sinks
+// are extended, an entry with the name of an inherited one is merged into
+// it. Lists of values such as namespaces replace the inherited list.
+func applyProfile(root *yaml.Node, selected string) (*yaml.Node, error) {
+	resolved, profiles := stripProfiles(root)
+	if errs := checkProfiles(profiles); len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	if resolved == root {
+		return root, nil
+	}
+	top := resolved.Content[0]
+	if selected == "" {
+		if name := mappingValue(top, profileKey); name != nil {
+			selected = name.Value
+		}
+	}
+	if selected == "" {
+		return resolved, nil
+	}
+	var chain []*yaml.Node
+	for name := selected; name != ""; {
+		if profiles == nil || mappingValue(profiles, name) == nil {
+			return nil, fmt.Errorf("unknown profile %q", name)
+		}
+		body := mappingValue(profiles, name)
+		if slices.Contains(chain, body) {
+			return nil, fmt.Errorf("profile %q extends itself through its ancestors", name)
+		}
+		chain = append(chain, body)
+		name = ""
+		if extends := mappingValue(body, extendsKey); extends != nil {
+			name = extends.Value
+		}
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		body := copyNode(chain[i])
+		removeKey(body, extendsKey)
+		overlayNode(top, body)
+	}
+	if name := mappingValue(top, profileKey); name != nil {
+		name.Value = selected
+	} else {
+		top.Content = append(top.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: profileKey}, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: selected})
+	}
+	return resolved, nil
+}
+
+// stripProfiles returns a copy of root without the profiles and the profiles.
+// root is returned as is when it is not a map.
+func stripProfiles(root *yaml.Node) (*yaml.Node, *yaml.Node) {
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 || root.Content[0].Kind != yaml.MappingNode {
+		return root, nil
+	}
+	stripped := copyNode(root)
+	return stripped, removeKey(stripped.Content[0], profilesKey)
+}
+
+// checkProfiles reports malformed profiles and their unknown fields, also of
+// the profiles that are not selected.
+func checkProfiles(profiles *yaml.Node) []error {
+	if profiles == nil || isNull(profiles) {
+		return nil
+	}
+	if profiles.Kind != yaml.MappingNode {
+		return []error{fmt.Errorf("line %d: profiles must be a map of profile names", profiles.Line)}
+	}
+	var errs []error
+	for i := 0; i+1 < len(profiles.Content); i += 2 {
+		name, body := profiles.Content[i].Value, profiles.Content[i+1]
+		if body.Kind != yaml.MappingNode {
+			errs = append(errs, fmt.Errorf("line %d: profile %q must be a map", body.Line, name))
+			continue
+		}
+		if extends := mappingValue(body, extendsKey); extends != nil && extends.Kind != yaml.ScalarNode {
+			errs = append(errs, fmt.Errorf("line %d: profiles.%s.extends must be a profile name", extends.Line, name))
+		}
+		fields := copyNode(body)
+		removeKey(fields, extendsKey)
+		removeKey(fields, profileKey)
+		errs = append(errs, unknownFields(fields, reflect.TypeOf(Config{}), joinPath(profilesKey, name))...)
+	}
+	return errs
+}
+
+// overlayNode merges the profile node src into dst.
+func overlayNode(dst, src *yaml.Node) {
+	switch {
+	case dst.Kind == yaml.MappingNode && src.Kind == yaml.MappingNode:
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			key, value := src.Content[i], src.Content[i+1]
+			if existing := mappingValue(dst, key.Value); existing != nil {
+				overlayNode(existing, value)
+			} else {
+				dst.Content = append(dst.Content, key, value)
+			}
+		}
+	case dst.Kind == yaml.SequenceNode && src.Kind == yaml.SequenceNode && mapEntries(src):
+		for _, item := range src.Content {
+			index := slices.IndexFunc(dst.Content, func(existing *yaml.Node) bool {
+				return sameName(existing, item)
+			})
+			if index >= 0 {
+				overlayNode(dst.Content[index], item)
+			} else {
+				dst.Content = append(dst.Content, item)
+			}
+		}
+	default:
+		*dst = *src
+	}
+}
+
+// mapEntries reports whether node is a non-empty list of maps.
+func mapEntries(node *yaml.Node) bool {
+	if len(node.Content) == 0 {
+		return false
+	}
+	for _, item := range node.Content {
+		if item.Kind != yaml.MappingNode {
+			return false
+		}
+	}
+	return true
+}
+
+// sameName reports whether the list entries a and b have the same name.
+func sameName(a, b *yaml.Node) bool {
+	if a.Kind != yaml.MappingNode {
+		return false
+	}
+	nameA, nameB := mappingValue(a, "name"), mappingValue(b, "name")
+	return nameA != nil && nameB != nil && nameA.Value == nameB.Value
+}
+
+// removeKey removes key from the mapping node and returns its value.
+func removeKey(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			value := node.Content[i+1]
+			node.Content = slices.Delete(node.Content, i, i+2)
+			return value
+		}
+	}
+	return nil
+}
+
+func copyNode(node *yaml.Node) *yaml.Node {
+	copied := *node
+	copied.Content = make([]*yaml.Node, len(node.Content))
+	for i, child := range node.Content {
+		copied.Content[i] = copyNode(child)
+	}
+	return &copied
+}
@@ -0,0 +1,144 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	timeType        = reflect.TypeOf(time.Time{})
+	unmarshalerType = reflect.TypeOf((*yaml.Unmarshaler)(nil)).Elem()
+)
+
+// unknownFields reports the keys of node that do not match a field of t, e.g.
+// a misspelled includePathes. Unlike the known fields mode of the decoder it
+// reports all of them, with the line numbers of the original file.
+func unknownFields(node *yaml.Node, t reflect.Type, path string) []error {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == timeType || reflect.PointerTo(t).Implements(unmarshalerType) {
+		return nil
+	}
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return nil
+		}
+		return unknownFields(node.Content[0], t, path)
+	case yaml.AliasNode:
+		return unknownFields(node.Alias, t, path)
+	}
+
+	var errs []error
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			field, ok := fields[key.Value]
+			if !ok {
+				errs = append(errs, unknownFieldError(key, path, fields))
+				continue
+			}
+			errs = append(errs, unknownFields(value, field, joinPath(path, key.Value))...)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			errs = append(errs, unknownFields(node.Content[i+1], t.Elem(), joinPath(path, node.Content[i].Value))...)
+		}
+	case reflect.Slice:
+		if node.Kind != yaml.SequenceNode {
+			return nil
+		}
+		for i, item := range node.Content {
+			errs = append(errs, unknownFields(item, t.Elem(), fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	}
+	return errs
+}
+
+// yamlFields maps the keys of struct t to their field types, including the
+// fields of inlined structs.
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("yaml")
+		if tag == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if strings.Contains(options, "inline") {
+			for key, value := range yamlFields(field.Type) {
+				fields[key] = value
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+func unknownFieldError(key *yaml.Node, path string, fields map[string]reflect.Type) error {
+	msg := fmt.Sprintf("line %d: unknown field %q", key.Line, key.Value)
+	if path != "" {
+		msg += " in " + path
+	}
+	if suggestion := closestField(key.Value, fields); suggestion != "" {
+		msg += fmt.Sprintf(", did you mean %q?", suggestion)
+	}
+	return fmt.Errorf("%s", msg)
+}
+
+// closestField returns the field name within an edit distance of two of name.
+func closestField(name string, fields map[string]reflect.Type) string {
+	best, bestDistance := "", 3
+	for field := range fields {
+		if distance := editDistance(strings.ToLower(name), strings.ToLower(field)); distance < bestDistance ||
+			(distance == bestDistance && field < best) {
+			best, bestDistance = field, distance
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// TenantConfig is the share of a team in a shared watcher. Its resources only
+// watch the namespaces of the tenant and its sinks only receive the events of
+// its resources, the resources and sinks of the config receive all events.
+type TenantConfig struct {
+	Name string `yaml:"name"`
+	// Namespaces and NamespaceSelector select the namespaces of the tenant,
+	// at least one is required.
+	Namespaces        []string         `yaml:"namespaces"`
+	NamespaceSelector *LabelSelector   `yaml:"namespaceSelector"`
+	Resources         []ResourceConfig `yaml:"resources"`
+	// Sinks are named "<tenant>/<name>" in logs and metrics.
+	Sinks []SinkConfig `yaml:"sinks"`
+}
+
+// expandTenants appends the resources and sinks of cfg.Tenants to the config.
+// Resource entries only watch namespaced resources and either all namespaces
+// of their tenant or some of its listed ones, the common namespace filters do
+// not apply to them.
+func expandTenants(cfg *Config) error {
+	var errs []error
+	var seen []string
+	for i, tenant := range cfg.Tenants {
+		switch {
+		case tenant.Name == "" || strings.Contains(tenant.Name, "/"):
+			errs = append(errs, fmt.Errorf("tenants[%d]: a name without slashes is required", i))
+			continue
+		case slices.Contains(seen, tenant.Name):
+			errs = append(errs, fmt.Errorf("tenants[%d]: duplicate name %q", i, tenant.Name))
+			continue
+		case len(tenant.Namespaces) == 0 && tenant.NamespaceSelector == nil:
+			errs = append(errs, fmt.Errorf("tenant %s: namespaces or namespaceSelector is required", tenant.Name))
+			continue
+		}
+		seen = append(seen, tenant.Name)
+		for j, resource := range tenant.Resources {
+			name := fmt.Sprintf("tenant %s: resources[%d]", tenant.Name, j)
+			if resource.NamespaceSelector != nil {
+				errs = append(errs, fmt.Errorf("%s: namespaceSelector is set on the tenant", name))
+				continue
+			}
+			if resource.Scope != "" && resource.Scope != ScopeNamespaced {
+				errs = append(errs, fmt.Errorf("%s: tenants only watch namespaced resources", name))
+				continue
+			}
+			if outside := slices.DeleteFunc(slices.Clone(resource.Namespaces), func(namespace string) bool {
+				return slices.Contains(tenant.Namespaces, namespace)
+			}); len(outside) > 0 {
+				errs = append(errs, fmt.Errorf("%s: namespaces %s are not listed by the tenant", name, strings.Join(outside, ", ")))
+				continue
+			}
+			if len(resource.Namespaces) == 0 {
+				resource.Namespaces = tenant.Namespaces
+				resource.NamespaceSelector = tenant.NamespaceSelector
+			}
+			resource.Scope = ScopeNamespaced
+			resource.Tenant = tenant.Name
+			cfg.Resources = append(cfg.Resources, resource)
+		}
+		for j, sink := range tenant.Sinks {
+			if sink.Name == "" {
+				sink.Name = fmt.Sprintf("%s-%d", sink.Type, j)
+			}
+			sink.Name = tenant.Name + "/" + sink.Name
+			sink.Tenant = tenant.Name
+			cfg.Sinks = append(cfg.Sinks, sink)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,212 @@
+// Package event defines the events produced by the watcher.
+package event
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// SchemaVersion is the version of the Event JSON schema. It changes whenever
+// fields are renamed or removed, adding fields keeps the version.
+const SchemaVersion = "v1"
+
+// TypeFlapping is the type of the synthetic event emitted for objects updated
+// more often than the configured flapping threshold.
+const TypeFlapping = "Flapping"
+
+// TypeResync is the type of the events emitted for every unchanged object on
+// each resync period of its resource.
+const TypeResync = "Resync"
+
+// TypeImageChanged replaces Update events of resources watched for image
+// changes, see Event.Images.
+const TypeImageChanged = "ImageChanged"
+
+// TypeScaled replaces Update events of resources watched for scale changes,
+// see Event.Scale.
+const TypeScaled = "Scaled"
+
+// TypeRecreated replaces a Delete and the Add of an object with the same
+// namespace and name within the recreate window, and updates that change the
+// UID of an object. Its diff is between the deleted and the added object.
+const TypeRecreated = "Recreated"
+
+// TypeSyncSummary replaces the Add events of the initial list of a resource
+// watched with initialSync summary, see Event.Summary.
+const TypeSyncSummary = "SyncSummary"
+
+// TypeCertificateExpiring warns about a certificate that expires within the
+// configured window, see config.CertificateExpiryConfig.
+const TypeCertificateExpiring = "CertificateExpiring"
+
+// Lifecycle events replacing the updates of Nodes watched with nodeLifecycle.
+// Their diff holds the change they report.
+const (
+	TypeNodeReady          = "NodeReady"
+	TypeNodeNotReady       = "NodeNotReady"
+	TypeNodeCordoned       = "NodeCordoned"
+	TypeNodeUncordoned     = "NodeUncordoned"
+	TypeNodeTaintsChanged  = "NodeTaintsChanged"
+	TypeNodeKubeletChanged = "NodeKubeletChanged"
+)
+
+// FieldChange describes a single changed field between two object versions.
+type FieldChange struct {
+	Path string      `json:"path"`
+	Old  interface{} `json:"old,omitempty"`
+	New  interface{} `json:"new,omitempty"`
+}
+
+// ImageChange is the image of a container before and after an update. Old is
+// empty for added containers, New for removed ones.
+type ImageChange struct {
+	Container string `json:"container"`
+	Old       string `json:"old,omitempty"`
+	New       string `json:"new,omitempty"`
+}
+
+// ScaleChange is the replica count before and after an update.
+type ScaleChange struct {
+	From int64 `json:"from"`
+	To   int64 `json:"to"`
+}
+
+// SyncSummary describes the objects of a resource after the initial list.
+// Checksum is the hex SHA-256 of the sorted lines "<namespace>/<name>
+// <resourceVersion>\n" of the objects, cluster-scoped objects are named
+// without a namespace.
+type SyncSummary struct {
+	Objects    int64    `json:"objects"`
+	Namespaces []string `json:"namespaces,omitempty"`
+	Checksum   string   `json:"checksum"`
+}
+
+// GVR identifies the resource of an event.
+type GVR struct {
+	Group    string `json:"group"`
+	Version  string `json:"version"`
+	Resource string `json:"resource"`
+}
+
+func NewGVR(gvr schema.GroupVersionResource) GVR {
+	return GVR{Group: gvr.Group, Version: gvr.Version, Resource: gvr.Resource}
+}
+
+func (g GVR) GroupVersionResource() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: g.Group, Version: g.Version, Resource: g.Resource}
+}
+
+// Owner is the top-level controller of an object, e.g. the Deployment of a Pod.
+type Owner struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	UID        string `json:"uid,omitempty"`
+}
+
+// ObjectReference identifies the object a Kubernetes Event is about.
+type ObjectReference struct {
+	APIVersion string `json:"apiVersion,omitempty"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	UID        string `json:"uid,omitempty"`
+}
+
+// Group is a correlated resource group an event belongs to. Key identifies
+// the instance of the group, e.g. "default/checkout" for the checkout app.
+type Group struct {
+	Name string `json:"name"`
+	Key  string `json:"key"`
+}
+
+// ChangedBy is the field manager that most likely performed an update.
+type ChangedBy struct {
+	Manager     string    `json:"manager"`
+	Operation   string    `json:"operation"`
+	Subresource string    `json:"subresource,omitempty"`
+	Time        time.Time `json:"time"`
+}
+
+// Alert statuses.
+const (
+	AlertTriggered = "triggered"
+	AlertResolved  = "resolved"
+)
+
+// Alert is raised by an alerting rule matching the event. An alert is resolved
+// when its rule no longer matches a later event of the object or the object is deleted.
+type Alert struct {
+	Rule     string `json:"rule"`
+	Status   string `json:"status"`
+	Severity string `json:"severity"`
+	Summary  string `json:"summary"`
+	DedupKey string `json:"dedupKey"`
+}
+
+// Event is a single filtered change of a watched object. Its JSON encoding is
+// the contract shared by all sinks, webhooks and plugins.
+type Event struct {
+	SchemaVersion string    `json:"schemaVersion"`
+	Cluster       string    `json:"cluster,omitempty"`
+	GVR           GVR       `json:"gvr"`
+	Namespace     string    `json:"namespace,omitempty"`
+	Name          string    `json:"name"`
+	UID           string    `json:"uid,omitempty"`
+	Type          string    `json:"eventType"`
+	Timestamp     time.Time `json:"timestamp"`
+	// PreviousUID is the UID of the deleted object a Recreated event replaces.
+	PreviousUID string `json:"previousUid,omitempty"`
+	// Object is the filtered and transformed object.
+	Object map[string]interface{} `json:"object"`
+	// OldObject is the filtered previous object of Update events, when enabled.
+	OldObject map[string]interface{} `json:"oldObject,omitempty"`
+	Diff      []FieldChange          `json:"diff,omitempty"`
+	// Images lists the changed container images of ImageChanged events.
+	Images []ImageChange `json:"images,omitempty"`
+	// Scale is the replica count change of Scaled events.
+	Scale *ScaleChange `json:"scale,omitempty"`
+	// Summary describes the initial list of SyncSummary events, which have
+	// no object.
+	Summary *SyncSummary `json:"summary,omitempty"`
+	// TextDiff is a unified diff of the filtered YAML of Update events, when enabled.
+	TextDiff string `json:"textDiff,omitempty"`
+	// Owner is the root owner of the object, when owner resolution is enabled.
+	Owner *Owner `json:"owner,omitempty"`
+	// ChangedBy attributes Update events to a field manager, when enabled.
+	ChangedBy *ChangedBy `json:"changedBy,omitempty"`
+	// InvolvedObject is the object a watched Kubernetes Event is about, when
+	// correlation is enabled.
+	InvolvedObject *ObjectReference `json:"involvedObject,omitempty"`
+	// Impacted lists the watched workloads using a ConfigMap or Secret, when
+	// impact analysis is enabled.
+	Impacted []ObjectReference `json:"impacted,omitempty"`
+	// Groups lists the correlated groups of the object.
+	Groups []Group `json:"groups,omitempty"`
+	// ClusterMetadata holds the configured and detected cluster key/values.
+	ClusterMetadata map[string]string `json:"clusterMetadata,omitempty"`
+	// Tenant is the tenant of the resource entry that emitted the event.
+	Tenant string `json:"tenant,omitempty"`
+	// Alerts lists the alerting rules the event matched.
+	Alerts []Alert `json:"alerts,omitempty"`
+	// Annotations are free-form key/values attached by scripts.
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Truncated is set when the payload exceeded the configured maximum size
+	// and was truncated or summarized.
+	Truncated bool `json:"truncated,omitempty"`
+	// Signature signs the event and links it to the previous event of the
+	// resource, when signing is enabled.
+	Signature *Signature `json:"signature,omitempty"`
+}
+
+// Signature makes a stream of events tamper-evident. Hash is the SHA-256 of
+// the JSON encoding of the event with Hash and Value empty, PrevHash the Hash
+// of the previous event of the same resource. Value signs Hash.
+type Signature struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"keyId,omitempty"`
+	PrevHash  string `json:"prevHash,omitempty"`
+	Hash      string `json:"hash"`
+	Value     string `json:"value"`
+}
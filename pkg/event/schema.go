@@ -0,0 +1,9 @@
+package event
+
+import _ "embed"
+
+// AvroSchema is the Avro schema of the events written by sinks with the avro
+// format. The Protobuf schema is event.proto.
+//
+//go:embed event.avsc
+var AvroSchema string
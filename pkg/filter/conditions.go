@@ -0,0 +1,84 @@
+package filter
+
+import (
+	"fmt"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+)
+
+// Conditions selects events by the values of object fields, a declarative
+// alternative to CEL for the common cases.
+type Conditions struct {
+	equals    []fieldCondition
+	changedTo []fieldCondition
+}
+
+type fieldCondition struct {
+	path  FieldPath
+	value string
+}
+
+// NewConditions parses the paths of the conditions. It returns nil when there
+// are none.
+func NewConditions(equals, changedTo []config.FieldCondition) (*Conditions, error) {
+	if len(equals) == 0 && len(changedTo) == 0 {
+		return nil, nil
+	}
+	c := &Conditions{}
+	var err error
+	if c.equals, err = parseConditions(equals); err != nil {
+		return nil, err
+	}
+	if c.changedTo, err = parseConditions(changedTo); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func parseConditions(conditions []config.FieldCondition) ([]fieldCondition, error) {
+	parsed := make([]fieldCondition, 0, len(conditions))
+	for _, condition := range conditions {
+		path, err := ParseFieldPath(condition.Path)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, fieldCondition{path: path, value: condition.Value})
+	}
+	return parsed, nil
+}
+
+// Match reports whether an event passes all conditions. Every fieldEquals
+// path of obj must hold its value. Every fieldChangedTo path must hold its
+// value in obj but not in oldObj, objects of Add events count as changed and
+// other events without an old object never match. A nil Conditions matches
+// everything.
+func (c *Conditions) Match(eventType string, oldObj, obj map[string]interface{}) bool {
+	if c == nil {
+		return true
+	}
+	for _, condition := range c.equals {
+		if !condition.holds(obj) {
+			return false
+		}
+	}
+	if len(c.changedTo) > 0 && oldObj == nil && eventType != "Add" {
+		return false
+	}
+	for _, condition := range c.changedTo {
+		if !condition.holds(obj) || (oldObj != nil && condition.holds(oldObj)) {
+			return false
+		}
+	}
+	return true
+}
+
+// holds reports whether any value selected by the path equals the expected
+// value, compared as text so that "3" matches a number and "true" a bool.
+func (c fieldCondition) holds(obj map[string]interface{}) bool {
+	for _, value := range c.path.Values(obj) {
+		if fmt.Sprint(value) == c.value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package filter
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"gopkg.in/yaml.v3"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+// Diff returns the list of changed leaf fields between oldObj and newObj.
+func Diff(oldObj, newObj map[string]interface{}) []event.FieldChange {
+	var changes []event.FieldChange
+	diffValues("", oldObj, newObj, &changes)
+	return changes
+}
+
+func diffValues(path string, oldValue, newValue interface{}, changes *[]event.FieldChange) {
+	if reflect.DeepEqual(oldValue, newValue) {
+		return
+	}
+	oldMap, oldIsMap := oldValue.(map[string]interface{})
+	newMap, newIsMap := newValue.(map[string]interface{})
+	if oldIsMap && newIsMap {
+		keys := make(map[string]struct{}, len(oldMap)+len(newMap))
+		for k := range oldMap {
+			keys[k] = struct{}{}
+		}
+		for k := range newMap {
+			keys[k] = struct{}{}
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			diffValues(joinPath(path, k), oldMap[k], newMap[k], changes)
+		}
+		return
+	}
+	oldList, oldIsList := oldValue.([]interface{})
+	newList, newIsList := newValue.([]interface{})
+	if oldIsList && newIsList {
+		for i := 0; i < len(oldList) || i < len(newList); i++ {
+			var o, n interface{}
+			if i < len(oldList) {
+				o = oldList[i]
+			}
+			if i < len(newList) {
+				n = newList[i]
+			}
+			diffValues(fmt.Sprintf("%s[%d]", path, i), o, n, changes)
+		}
+		return
+	}
+	*changes = append(*changes, event.FieldChange{Path: path, Old: oldValue, New: newValue})
+}
+
+// TextDiff renders a unified diff of the YAML encodings of oldObj and newObj,
+// like kubectl diff. name labels the compared objects in the header.
+func TextDiff(name string, oldObj, newObj map[string]interface{}) (string, error) {
+	oldYAML, err := marshalYAML(oldObj)
+	if err != nil {
+		return "", err
+	}
+	newYAML, err := marshalYAML(newObj)
+	if err != nil {
+		return "", err
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(oldYAML),
+		B:        splitLines(newYAML),
+		FromFile: name + " (old)",
+		ToFile:   name + " (new)",
+		Context:  3,
+	})
+}
+
+func marshalYAML(obj map[string]interface{}) (string, error) {
+	var buf strings.Builder
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(obj); err != nil {
+		return "", err
+	}
+	return buf.String(), encoder.Close()
+}
+
+// splitLines splits s after every newline, without an empty last line.
+func splitLines(s string) []string {
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
@@ -0,0 +1,52 @@
+// Package filter selects, reshapes and compares the parts of objects that end up in events.
+package filter
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// Filter keeps the include paths of an object and then removes the exclude paths.
+type Filter struct {
+	includePaths []FieldPath
+	excludePaths []FieldPath
+}
+
+func New(includePaths, excludePaths []string) (*Filter, error) {
+	f := &Filter{}
+	for _, path := range includePaths {
+		parsed, err := ParseFieldPath(path)
+		if err != nil {
+			return nil, err
+		}
+		f.includePaths = append(f.includePaths, parsed)
+	}
+	for _, path := range excludePaths {
+		parsed, err := ParseFieldPath(path)
+		if err != nil {
+			return nil, err
+		}
+		f.excludePaths = append(f.excludePaths, parsed)
+	}
+	return f, nil
+}
+
+// Apply returns a filtered copy of obj. A nil filter returns a plain copy.
+func (f *Filter) Apply(obj map[string]interface{}) map[string]interface{} {
+	if f == nil {
+		return runtime.DeepCopyJSON(obj)
+	}
+	var filtered map[string]interface{}
+	if len(f.includePaths) > 0 {
+		var included interface{} = map[string]interface{}{}
+		for _, path := range f.includePaths {
+			included = includeFieldPath(included, obj, path)
+		}
+		filtered = compactLists(included).(map[string]interface{})
+	} else {
+		filtered = runtime.DeepCopyJSON(obj)
+	}
+	for _, path := range f.excludePaths {
+		excludeFieldPath(filtered, path)
+	}
+	return filtered
+}
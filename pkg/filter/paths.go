@@ -0,0 +1,250 @@
+package filter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// pathSegment is a single step of a field path: a map key, a list index or
+// a list wildcard.
+type pathSegment struct {
+	key      string
+	index    int
+	isIndex  bool
+	wildcard bool
+}
+
+// FieldPath is a parsed include/exclude path such as
+// "spec.containers[*].image" or `metadata.annotations["app.kubernetes.io/name"]`.
+type FieldPath []pathSegment
+
+// ParseFieldPath parses an include/exclude path.
+func ParseFieldPath(path string) (FieldPath, error) {
+	var segments FieldPath
+	i := 0
+	for i < len(path) {
+		switch path[i] {
+		case '.':
+			if i == 0 || i == len(path)-1 || path[i+1] == '.' {
+				return nil, fmt.Errorf("invalid path %q: empty field name", path)
+			}
+			i++
+		case '[':
+			end := strings.IndexByte(path[i:], ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid path %q: missing ]", path)
+			}
+			inner := path[i+1 : i+end]
+			switch {
+			case inner == "*":
+				segments = append(segments, pathSegment{wildcard: true})
+			case len(inner) >= 2 && (inner[0] == '"' || inner[0] == '\'') && inner[len(inner)-1] == inner[0]:
+				segments = append(segments, pathSegment{key: inner[1 : len(inner)-1]})
+			default:
+				index, err := strconv.Atoi(inner)
+				if err != nil || index < 0 {
+					return nil, fmt.Errorf("invalid path %q: bad index %q", path, inner)
+				}
+				segments = append(segments, pathSegment{index: index, isIndex: true})
+			}
+			i += end + 1
+		default:
+			end := strings.IndexAny(path[i:], ".[")
+			if end < 0 {
+				end = len(path) - i
+			}
+			segments = append(segments, pathSegment{key: path[i : i+end]})
+			i += end
+		}
+	}
+	if len(segments) == 0 {
+		return nil, fmt.Errorf("invalid path %q: empty path", path)
+	}
+	return segments, nil
+}
+
+// listPlaceholder marks list positions not selected by any include path.
+type listPlaceholder struct{}
+
+// includeFieldPath copies the values selected by path from src into dst,
+// keeping the surrounding structure.
+func includeFieldPath(dst, src interface{}, path FieldPath) interface{} {
+	if len(path) == 0 {
+		return runtime.DeepCopyJSONValue(src)
+	}
+	segment := path[0]
+	if segment.isIndex || segment.wildcard {
+		srcList, ok := src.([]interface{})
+		if !ok {
+			return dst
+		}
+		dstList, ok := dst.([]interface{})
+		if !ok {
+			dstList = make([]interface{}, len(srcList))
+			for i := range dstList {
+				dstList[i] = listPlaceholder{}
+			}
+		}
+		for i := range srcList {
+			if segment.isIndex && i != segment.index {
+				continue
+			}
+			current := dstList[i]
+			if _, ok := current.(listPlaceholder); ok {
+				current = nil
+			}
+			if value := includeFieldPath(current, srcList[i], path[1:]); value != nil {
+				dstList[i] = value
+			}
+		}
+		return dstList
+	}
+	srcMap, ok := src.(map[string]interface{})
+	if !ok {
+		return dst
+	}
+	value, found := srcMap[segment.key]
+	if !found {
+		return dst
+	}
+	dstMap, ok := dst.(map[string]interface{})
+	if !ok {
+		dstMap = make(map[string]interface{})
+	}
+	if included := includeFieldPath(dstMap[segment.key], value, path[1:]); included != nil {
+		dstMap[segment.key] = included
+	}
+	if len(dstMap) == 0 {
+		return dst
+	}
+	return dstMap
+}
+
+// compactLists removes the positions of lists that no include path selected.
+func compactLists(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = compactLists(item)
+		}
+		return v
+	case []interface{}:
+		compacted := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			if _, ok := item.(listPlaceholder); ok {
+				continue
+			}
+			compacted = append(compacted, compactLists(item))
+		}
+		return compacted
+	}
+	return value
+}
+
+// excludeFieldPath removes the values selected by path from obj in place.
+func excludeFieldPath(obj interface{}, path FieldPath) interface{} {
+	if len(path) == 0 {
+		return obj
+	}
+	segment := path[0]
+	last := len(path) == 1
+	if segment.isIndex || segment.wildcard {
+		list, ok := obj.([]interface{})
+		if !ok {
+			return obj
+		}
+		if last {
+			if segment.wildcard {
+				return []interface{}{}
+			}
+			if segment.index < len(list) {
+				return append(list[:segment.index:segment.index], list[segment.index+1:]...)
+			}
+			return list
+		}
+		for i := range list {
+			if segment.isIndex && i != segment.index {
+				continue
+			}
+			list[i] = excludeFieldPath(list[i], path[1:])
+		}
+		return list
+	}
+	m, ok := obj.(map[string]interface{})
+	if !ok {
+		return obj
+	}
+	if last {
+		delete(m, segment.key)
+		return m
+	}
+	if value, found := m[segment.key]; found {
+		m[segment.key] = excludeFieldPath(value, path[1:])
+	}
+	return m
+}
+
+// Values returns the values selected by p in obj, one per matching list entry
+// for wildcards. Missing fields select nothing.
+func (p FieldPath) Values(obj interface{}) []interface{} {
+	if len(p) == 0 {
+		return []interface{}{obj}
+	}
+	segment := p[0]
+	if segment.isIndex || segment.wildcard {
+		list, ok := obj.([]interface{})
+		if !ok {
+			return nil
+		}
+		if segment.isIndex {
+			if segment.index >= len(list) {
+				return nil
+			}
+			return p[1:].Values(list[segment.index])
+		}
+		var values []interface{}
+		for _, item := range list {
+			values = append(values, p[1:].Values(item)...)
+		}
+		return values
+	}
+	m, ok := obj.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	value, found := m[segment.key]
+	if !found {
+		return nil
+	}
+	return p[1:].Values(value)
+}
+
+// Covers reports whether path selects the field of p or a field below it,
+// e.g. "spec.containers[*].image" covers "spec.containers[0].image" and
+// "spec" covers both.
+func (p FieldPath) Covers(path FieldPath) bool {
+	if len(path) < len(p) {
+		return false
+	}
+	for i, segment := range p {
+		other := path[i]
+		switch {
+		case segment.wildcard:
+			if !other.isIndex && !other.wildcard {
+				return false
+			}
+		case segment.isIndex:
+			if !other.isIndex || other.index != segment.index {
+				return false
+			}
+		default:
+			if other.isIndex || other.wildcard || other.key != segment.key {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,146 @@
+package filter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFieldPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    FieldPath
+		wantErr bool
+	}{
+		{path: "metadata.name", want: FieldPath{{key: "metadata"}, {key: "name"}}},
+		{path: "spec.containers[*].image", want: FieldPath{{key: "spec"}, {key: "containers"}, {wildcard: true}, {key: "image"}}},
+		{path: "spec.containers[1].image", want: FieldPath{{key: "spec"}, {key: "containers"}, {index: 1, isIndex: true}, {key: "image"}}},
+		{path: `metadata.annotations["app.kubernetes.io/name"]`, want: FieldPath{{key: "metadata"}, {key: "annotations"}, {key: "app.kubernetes.io/name"}}},
+		{path: `metadata.labels['a.b']`, want: FieldPath{{key: "metadata"}, {key: "labels"}, {key: "a.b"}}},
+		{path: "items[0][2]", want: FieldPath{{key: "items"}, {index: 0, isIndex: true}, {index: 2, isIndex: true}}},
+		{path: "", wantErr: true},
+		{path: ".spec", wantErr: true},
+		{path: "spec.", wantErr: true},
+		{path: "spec..replicas", wantErr: true},
+		{path: "spec.containers[*", wantErr: true},
+		{path: "spec.containers[-1]", wantErr: true},
+		{path: "spec.containers[x]", wantErr: true},
+		{path: `metadata.labels["a.b']`, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := ParseFieldPath(tt.path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseFieldPath(%q) = %v, want an error", tt.path, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseFieldPath(%q): %v", tt.path, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseFieldPath(%q) = %+v, want %+v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterApply(t *testing.T) {
+	obj := func() map[string]interface{} {
+		return map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"name": "web",
+				"annotations": map[string]interface{}{
+					"app.kubernetes.io/name": "web",
+					"other":                  "x",
+				},
+			},
+			"spec": map[string]interface{}{
+				"replicas": int64(2),
+				"containers": []interface{}{
+					map[string]interface{}{"name": "app", "image": "app:1"},
+					map[string]interface{}{"name": "sidecar", "image": "proxy:2"},
+				},
+			},
+		}
+	}
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		want    map[string]interface{}
+	}{
+		{
+			name:    "wildcard",
+			include: []string{"spec.containers[*].image"},
+			want: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"image": "app:1"},
+						map[string]interface{}{"image": "proxy:2"},
+					},
+				},
+			},
+		},
+		{
+			name:    "index",
+			include: []string{"spec.containers[1].name"},
+			want: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"name": "sidecar"},
+					},
+				},
+			},
+		},
+		{
+			name:    "quoted key",
+			include: []string{`metadata.annotations["app.kubernetes.io/name"]`},
+			want: map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{"app.kubernetes.io/name": "web"},
+				},
+			},
+		},
+		{
+			name:    "exclude after include",
+			include: []string{"metadata", "spec.replicas"},
+			exclude: []string{`metadata.annotations['other']`},
+			want: map[string]interface{}{
+				"metadata": map[string]interface{}{
+					"name":        "web",
+					"annotations": map[string]interface{}{"app.kubernetes.io/name": "web"},
+				},
+				"spec": map[string]interface{}{"replicas": int64(2)},
+			},
+		},
+		{
+			name:    "exclude wildcard",
+			exclude: []string{"metadata", "spec.containers[*].name", "spec.replicas"},
+			want: map[string]interface{}{
+				"spec": map[string]interface{}{
+					"containers": []interface{}{
+						map[string]interface{}{"image": "app:1"},
+						map[string]interface{}{"image": "proxy:2"},
+					},
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := New(tt.include, tt.exclude)
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			input := obj()
+			got := f.Apply(input)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Apply() = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(input, obj()) {
+				t.Errorf("Apply() modified its input: %v", input)
+			}
+		})
+	}
+}
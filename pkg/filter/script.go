@@ -0,0 +1,221 @@
+package filter
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"go.starlark.net/starlark"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+)
+
+const scriptMaxExecutionSteps = 1_000_000
+
+// ScriptResult is the decision of a script about an event.
+type ScriptResult struct {
+	Drop        bool
+	Object      map[string]interface{}
+	Annotations map[string]string
+}
+
+// Script runs the process function of a Starlark script, see config.ScriptConfig.
+type Script struct {
+	name    string
+	process starlark.Callable
+}
+
+// NewScript returns nil when no script is configured.
+func NewScript(cfg config.ScriptConfig) (*Script, error) {
+	source, name := cfg.Source, "script"
+	if cfg.File != "" {
+		data, err := os.ReadFile(cfg.File)
+		if err != nil {
+			return nil, err
+		}
+		source, name = string(data), cfg.File
+	}
+	if source == "" {
+		return nil, nil
+	}
+	thread := &starlark.Thread{Name: name}
+	globals, err := starlark.ExecFile(thread, name, source, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load script: %w", err)
+	}
+	process, ok := globals["process"].(starlark.Callable)
+	if !ok {
+		return nil, fmt.Errorf("script %s does not define process(event_type, old, new)", name)
+	}
+	globals.Freeze()
+	return &Script{name: name, process: process}, nil
+}
+
+// Run calls the script's process function. A nil script keeps every event.
+func (s *Script) Run(eventType string, oldObj, newObj map[string]interface{}) (*ScriptResult, error) {
+	if s == nil {
+		return &ScriptResult{}, nil
+	}
+	oldValue, err := toStarlark(oldObj)
+	if err != nil {
+		return nil, err
+	}
+	newValue, err := toStarlark(newObj)
+	if err != nil {
+		return nil, err
+	}
+	if oldObj == nil {
+		oldValue = starlark.None
+	}
+	thread := &starlark.Thread{Name: s.name}
+	thread.SetMaxExecutionSteps(scriptMaxExecutionSteps)
+	value, err := starlark.Call(thread, s.process, starlark.Tuple{starlark.String(eventType), oldValue, newValue}, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch v := value.(type) {
+	case starlark.NoneType:
+		return &ScriptResult{}, nil
+	case starlark.Bool:
+		return &ScriptResult{Drop: !bool(v)}, nil
+	case *starlark.Dict:
+		return parseScriptResult(v)
+	}
+	return nil, fmt.Errorf("process returned unsupported value of type %s", value.Type())
+}
+
+func parseScriptResult(dict *starlark.Dict) (*ScriptResult, error) {
+	result := &ScriptResult{}
+	if drop, found, _ := dict.Get(starlark.String("drop")); found {
+		result.Drop = bool(drop.Truth())
+	}
+	if object, found, _ := dict.Get(starlark.String("object")); found && object != starlark.None {
+		converted, err := fromStarlark(object)
+		if err != nil {
+			return nil, err
+		}
+		m, ok := converted.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("object returned by process must be a dict")
+		}
+		result.Object = m
+	}
+	if annotations, found, _ := dict.Get(starlark.String("annotations")); found && annotations != starlark.None {
+		annotationsDict, ok := annotations.(*starlark.Dict)
+		if !ok {
+			return nil, fmt.Errorf("annotations returned by process must be a dict")
+		}
+		result.Annotations = make(map[string]string, annotationsDict.Len())
+		for _, item := range annotationsDict.Items() {
+			key, ok := starlark.AsString(item[0])
+			if !ok {
+				return nil, fmt.Errorf("annotation keys must be strings")
+			}
+			value, ok := starlark.AsString(item[1])
+			if !ok {
+				value = item[1].String()
+			}
+			result.Annotations[key] = value
+		}
+	}
+	return result, nil
+}
+
+func toStarlark(value interface{}) (starlark.Value, error) {
+	switch v := value.(type) {
+	case nil:
+		return starlark.None, nil
+	case bool:
+		return starlark.Bool(v), nil
+	case string:
+		return starlark.String(v), nil
+	case int64:
+		return starlark.MakeInt64(v), nil
+	case int:
+		return starlark.MakeInt(v), nil
+	case float64:
+		return starlark.Float(v), nil
+	case []interface{}:
+		items := make([]starlark.Value, len(v))
+		for i, item := range v {
+			converted, err := toStarlark(item)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = converted
+		}
+		return starlark.NewList(items), nil
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		dict := starlark.NewDict(len(v))
+		for _, key := range keys {
+			converted, err := toStarlark(v[key])
+			if err != nil {
+				return nil, err
+			}
+			if err := dict.SetKey(starlark.String(key), converted); err != nil {
+				return nil, err
+			}
+		}
+		return dict, nil
+	}
+	return nil, fmt.Errorf("unsupported value of type %T", value)
+}
+
+func fromStarlark(value starlark.Value) (interface{}, error) {
+	switch v := value.(type) {
+	case starlark.NoneType:
+		return nil, nil
+	case starlark.Bool:
+		return bool(v), nil
+	case starlark.String:
+		return string(v), nil
+	case starlark.Int:
+		i, ok := v.Int64()
+		if !ok {
+			return nil, fmt.Errorf("integer %s out of range", v)
+		}
+		return i, nil
+	case starlark.Float:
+		return float64(v), nil
+	case *starlark.List:
+		items := make([]interface{}, v.Len())
+		for i := 0; i < v.Len(); i++ {
+			converted, err := fromStarlark(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			items[i] = converted
+		}
+		return items, nil
+	case starlark.Tuple:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			converted, err := fromStarlark(item)
+			if err != nil {
+				return nil, err
+			}
+			items[i] = converted
+		}
+		return items, nil
+	case *starlark.Dict:
+		m := make(map[string]interface{}, v.Len())
+		for _, item := range v.Items() {
+			key, ok := starlark.AsString(item[0])
+			if !ok {
+				return nil, fmt.Errorf("dict keys must be strings, got %s", item[0].Type())
+			}
+			converted, err := fromStarlark(item[1])
+			if err != nil {
+				return nil, err
+			}
+			m[key] = converted
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("unsupported starlark value of type %s", value.Type())
+}
@@ -0,0 +1,112 @@
+package filter
+
+import (
+	"fmt"
+
+	"github.com/itchyny/gojq"
+	"k8s.io/client-go/util/jsonpath"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+)
+
+// Transformer applies a compiled transform expression to event payloads.
+type Transformer struct {
+	jq       *gojq.Code
+	jsonPath *jsonpath.JSONPath
+}
+
+// NewTransformer returns nil when no expression is configured.
+func NewTransformer(cfg config.TransformConfig) (*Transformer, error) {
+	if cfg.JQ != "" && cfg.JSONPath != "" {
+		return nil, fmt.Errorf("only one of jq and jsonPath can be set")
+	}
+	switch {
+	case cfg.JQ != "":
+		query, err := gojq.Parse(cfg.JQ)
+		if err != nil {
+			return nil, fmt.Errorf("invalid jq expression: %w", err)
+		}
+		code, err := gojq.Compile(query, gojq.WithVariables([]string{"$eventType"}))
+		if err != nil {
+			return nil, fmt.Errorf("invalid jq expression: %w", err)
+		}
+		return &Transformer{jq: code}, nil
+	case cfg.JSONPath != "":
+		jp := jsonpath.New("transform").AllowMissingKeys(true)
+		if err := jp.Parse(cfg.JSONPath); err != nil {
+			return nil, fmt.Errorf("invalid jsonPath expression: %w", err)
+		}
+		return &Transformer{jsonPath: jp}, nil
+	}
+	return nil, nil
+}
+
+// Transform returns the reshaped payload, or nil if the event should be dropped.
+// Results that are not objects are wrapped as {"result": value}. A nil
+// transformer returns obj unchanged.
+func (t *Transformer) Transform(eventType string, obj map[string]interface{}) (map[string]interface{}, error) {
+	if t == nil {
+		return obj, nil
+	}
+	var result interface{}
+	if t.jq != nil {
+		iter := t.jq.Run(normalizeNumbers(obj), eventType)
+		value, ok := iter.Next()
+		if !ok {
+			// An empty result (e.g. from select) drops the event.
+			return nil, nil
+		}
+		if err, isErr := value.(error); isErr {
+			return nil, err
+		}
+		result = value
+	} else {
+		results, err := t.jsonPath.FindResults(obj)
+		if err != nil {
+			return nil, err
+		}
+		var values []interface{}
+		for _, group := range results {
+			for _, v := range group {
+				if v.IsValid() && v.CanInterface() {
+					values = append(values, v.Interface())
+				}
+			}
+		}
+		switch len(values) {
+		case 0:
+		case 1:
+			result = values[0]
+		default:
+			result = values
+		}
+	}
+	if m, ok := result.(map[string]interface{}); ok {
+		return m, nil
+	}
+	return map[string]interface{}{"result": result}, nil
+}
+
+// normalizeNumbers converts the integer types used by unstructured objects
+// into the int type understood by gojq.
+func normalizeNumbers(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		normalized := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			normalized[key] = normalizeNumbers(item)
+		}
+		return normalized
+	case []interface{}:
+		normalized := make([]interface{}, len(v))
+		for i, item := range v {
+			normalized[i] = normalizeNumbers(item)
+		}
+		return normalized
+	case int64:
+		return int(v)
+	case int32:
+		return int(v)
+	}
+	return value
+}
@@ -0,0 +1,161 @@
+package filter
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+// minTruncateSize keeps short values such as names intact.
+const minTruncateSize = 64
+
+// LimitSize shrinks ev until its JSON encoding fits into maxSize bytes and
+// marks it as truncated. With config.OversizeTruncate the largest values are
+// replaced with a "[truncated N bytes]" marker, with config.OversizeSummarize
+// the objects are replaced with the sizes of their fields. The objects are
+// copied before they are modified. It reports whether ev was changed.
+func LimitSize(ev *event.Event, maxSize int, mode string) bool {
+	if maxSize <= 0 || jsonSize(ev) <= maxSize {
+		return false
+	}
+	if mode == config.OversizeSummarize {
+		ev.Object = summarize(ev.Object)
+		if ev.OldObject != nil {
+			ev.OldObject = summarize(ev.OldObject)
+		}
+		diff := make([]event.FieldChange, len(ev.Diff))
+		for i, change := range ev.Diff {
+			diff[i] = event.FieldChange{Path: change.Path, Old: jsonSize(change.Old), New: jsonSize(change.New)}
+		}
+		ev.Diff = diff
+	} else {
+		ev.Object = copyValue(ev.Object).(map[string]interface{})
+		if ev.OldObject != nil {
+			ev.OldObject = copyValue(ev.OldObject).(map[string]interface{})
+		}
+		diff := make([]event.FieldChange, len(ev.Diff))
+		for i, change := range ev.Diff {
+			diff[i] = event.FieldChange{Path: change.Path, Old: copyValue(change.Old), New: copyValue(change.New)}
+		}
+		ev.Diff = diff
+	}
+	ev.Truncated = true
+	truncate(ev, maxSize)
+	return true
+}
+
+type candidate struct {
+	size int
+	set  func(interface{})
+}
+
+// truncate replaces values of ev with markers until it fits into maxSize.
+// The smallest value whose replacement is enough is picked first, so that as
+// little as possible is lost, otherwise the largest one.
+func truncate(ev *event.Event, maxSize int) {
+	for size := jsonSize(ev); size > maxSize; size = jsonSize(ev) {
+		excess := size - maxSize
+		var candidates []candidate
+		collect(ev.Object, &candidates)
+		collect(ev.OldObject, &candidates)
+		if ev.TextDiff != "" {
+			addCandidate(ev.TextDiff, func(v interface{}) { ev.TextDiff = v.(string) }, &candidates)
+		}
+		for i := range ev.Diff {
+			change := &ev.Diff[i]
+			addCandidate(change.Old, func(v interface{}) { change.Old = v }, &candidates)
+			addCandidate(change.New, func(v interface{}) { change.New = v }, &candidates)
+		}
+		var smallestCovering, largest *candidate
+		for i := range candidates {
+			c := &candidates[i]
+			// The marker is encoded as a quoted string.
+			if c.size-len(marker(c.size))-2 >= excess && (smallestCovering == nil || c.size < smallestCovering.size) {
+				smallestCovering = c
+			}
+			if largest == nil || c.size > largest.size {
+				largest = c
+			}
+		}
+		best := smallestCovering
+		if best == nil {
+			best = largest
+		}
+		if best == nil {
+			return
+		}
+		best.set(marker(best.size))
+	}
+}
+
+func collect(value interface{}, candidates *[]candidate) {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for key, child := range value {
+			addCandidate(child, func(v interface{}) { value[key] = v }, candidates)
+		}
+	case []interface{}:
+		for i, child := range value {
+			addCandidate(child, func(v interface{}) { value[i] = v }, candidates)
+		}
+	}
+}
+
+func addCandidate(value interface{}, set func(interface{}), candidates *[]candidate) {
+	if size := jsonSize(value); size > minTruncateSize {
+		*candidates = append(*candidates, candidate{size: size, set: set})
+	}
+	collect(value, candidates)
+}
+
+func marker(size int) string {
+	return fmt.Sprintf("[truncated %d bytes]", size)
+}
+
+// summarize replaces the fields of obj with their encoded size, maps one level
+// down keep their keys, e.g. the keys of ConfigMap data.
+func summarize(obj map[string]interface{}) map[string]interface{} {
+	summary := make(map[string]interface{}, len(obj))
+	for key, value := range obj {
+		child, ok := value.(map[string]interface{})
+		if !ok {
+			summary[key] = jsonSize(value)
+			continue
+		}
+		sizes := make(map[string]interface{}, len(child))
+		for childKey, childValue := range child {
+			sizes[childKey] = jsonSize(childValue)
+		}
+		summary[key] = sizes
+	}
+	return summary
+}
+
+func copyValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(value))
+		for key, child := range value {
+			copied[key] = copyValue(child)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(value))
+		for i, child := range value {
+			copied[i] = copyValue(child)
+		}
+		return copied
+	default:
+		return value
+	}
+}
+
+func jsonSize(value interface{}) int {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}
@@ -0,0 +1,89 @@
+package filter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+const defaultWebhookTimeout = 5 * time.Second
+
+// WebhookResponse is the webhook's answer. An empty body or a 204
+// response keeps the event unchanged.
+type WebhookResponse struct {
+	Drop        bool                   `json:"drop"`
+	Object      map[string]interface{} `json:"object"`
+	Annotations map[string]string      `json:"annotations"`
+}
+
+// Webhook is an external transformation endpoint, see config.TransformWebhookConfig.
+type Webhook struct {
+	cfg    config.TransformWebhookConfig
+	client *http.Client
+}
+
+// NewWebhook returns nil when no URL is configured.
+func NewWebhook(cfg config.TransformWebhookConfig) *Webhook {
+	if cfg.URL == "" {
+		return nil
+	}
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	return &Webhook{cfg: cfg, client: &http.Client{Timeout: timeout}}
+}
+
+// Call posts the event and returns the webhook's decision. A nil webhook keeps
+// every event unchanged.
+func (w *Webhook) Call(ctx context.Context, ev event.Event, oldObj map[string]interface{}) (*WebhookResponse, error) {
+	if w == nil {
+		return &WebhookResponse{}, nil
+	}
+	// The webhook always gets the previous object to base its decision on.
+	ev.OldObject = oldObj
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range w.cfg.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	result := &WebhookResponse{}
+	if resp.StatusCode == http.StatusNoContent || len(bytes.TrimSpace(respBody)) == 0 {
+		return result, nil
+	}
+	if err := json.Unmarshal(respBody, result); err != nil {
+		return nil, fmt.Errorf("invalid webhook response: %w", err)
+	}
+	return result, nil
+}
+
+// DropOnFailure reports whether events are dropped when the webhook fails.
+func (w *Webhook) DropOnFailure() bool {
+	return w != nil && w.cfg.FailurePolicy == config.FailurePolicyDrop
+}
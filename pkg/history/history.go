@@ -0,0 +1,120 @@
+// Package history keeps the recent versions of every watched object in
+// memory, so that the evolution of a single object can be looked up.
+package history
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+const defaultMaxObjects = 10000
+
+// Version is an object as its event reported it.
+type Version struct {
+	Type      string                 `json:"eventType"`
+	Timestamp time.Time              `json:"timestamp"`
+	UID       string                 `json:"uid,omitempty"`
+	Object    map[string]interface{} `json:"object"`
+	Diff      []event.FieldChange    `json:"diff,omitempty"`
+	ChangedBy *event.ChangedBy       `json:"changedBy,omitempty"`
+}
+
+type entry struct {
+	key      string
+	versions []Version
+}
+
+// History holds the last versions of the objects with the most recent
+// events, the objects without events for the longest time are evicted first.
+// Deleted objects are kept, a recreated object continues their history.
+type History struct {
+	versions   int
+	maxObjects int
+
+	mu      sync.Mutex
+	objects map[string]*list.Element
+	// recent orders the objects by their last event, newest first.
+	recent *list.List
+}
+
+// New returns nil when the history is disabled.
+func New(cfg config.HistoryConfig) *History {
+	if cfg.Versions <= 0 || cfg.Token == "" {
+		return nil
+	}
+	h := &History{versions: cfg.Versions, maxObjects: cfg.MaxObjects, objects: make(map[string]*list.Element), recent: list.New()}
+	if h.maxObjects <= 0 {
+		h.maxObjects = defaultMaxObjects
+	}
+	return h
+}
+
+// Add records the object of ev. Resyncs and events that repeat the resource
+// version of the last version, e.g. Flapping events, are skipped. It is a
+// no-op on a nil History.
+func (h *History) Add(ev event.Event) {
+	if h == nil || ev.Name == "" || ev.Type == event.TypeResync {
+		return
+	}
+	version := Version{Type: ev.Type, Timestamp: ev.Timestamp, UID: ev.UID, Object: ev.Object, Diff: ev.Diff, ChangedBy: ev.ChangedBy}
+	key := objectKey(resourceName(ev.GVR), ev.Namespace, ev.Name)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	element, ok := h.objects[key]
+	if !ok {
+		element = h.recent.PushFront(&entry{key: key})
+		h.objects[key] = element
+		if h.recent.Len() > h.maxObjects {
+			oldest := h.recent.Back()
+			h.recent.Remove(oldest)
+			delete(h.objects, oldest.Value.(*entry).key)
+		}
+	}
+	h.recent.MoveToFront(element)
+	e := element.Value.(*entry)
+	if n := len(e.versions); n > 0 && ev.Type != "Delete" && sameResourceVersion(e.versions[n-1].Object, ev.Object) {
+		return
+	}
+	e.versions = append(e.versions, version)
+	if len(e.versions) > h.versions {
+		e.versions = append(e.versions[:0:0], e.versions[len(e.versions)-h.versions:]...)
+	}
+}
+
+// Get returns the versions of an object, oldest first. Resources are named
+// like for pausing, e.g. "pods" or "deployments.apps".
+func (h *History) Get(resource, namespace, name string) ([]Version, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	element, ok := h.objects[objectKey(resource, namespace, name)]
+	if !ok {
+		return nil, false
+	}
+	return append([]Version(nil), element.Value.(*entry).versions...), true
+}
+
+// resourceName is the resource of gvr qualified by its group, e.g.
+// "deployments.apps".
+func resourceName(gvr event.GVR) string {
+	if gvr.Group == "" {
+		return gvr.Resource
+	}
+	return gvr.Resource + "." + gvr.Group
+}
+
+func objectKey(resource, namespace, name string) string {
+	return resource + "/" + namespace + "/" + name
+}
+
+func sameResourceVersion(a, b map[string]interface{}) bool {
+	version := func(obj map[string]interface{}) string {
+		metadata, _ := obj["metadata"].(map[string]interface{})
+		resourceVersion, _ := metadata["resourceVersion"].(string)
+		return resourceVersion
+	}
+	return version(a) != "" && version(a) == version(b)
+}
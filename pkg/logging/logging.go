@@ -0,0 +1,164 @@
+// Package logging sets up the application logger.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+)
+
+// ParseLevel parses debug, info, warn or error, case-insensitively.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(s))
+	return level, err
+}
+
+// WithLevel returns a logger writing to the handler of logger at level
+// instead of the handler's own level, e.g. debug logs of a single resource.
+// A raised Toggle of a logger created by New still applies.
+func WithLevel(logger *slog.Logger, level slog.Leveler) *slog.Logger {
+	handler := logger.Handler()
+	var toggle *Toggle
+	if leveled, ok := handler.(*levelHandler); ok {
+		handler, toggle = leveled.handler, leveled.toggle
+	}
+	return slog.New(&levelHandler{level: level, toggle: toggle, handler: handler})
+}
+
+// allLevels makes the wrapped handlers leave the level check to levelHandler.
+const allLevels = slog.Level(math.MinInt32)
+
+// levelHandler relies on the wrapped handler checking the level in Enabled
+// only, which holds for the slog handlers.
+type levelHandler struct {
+	level   slog.Leveler
+	toggle  *Toggle
+	handler slog.Handler
+}
+
+func (h *levelHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level() || h.toggle.enabled(level)
+}
+
+func (h *levelHandler) Handle(ctx context.Context, record slog.Record) error {
+	return h.handler.Handle(ctx, record)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{level: h.level, toggle: h.toggle, handler: h.handler.WithAttrs(attrs)}
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{level: h.level, toggle: h.toggle, handler: h.handler.WithGroup(name)}
+}
+
+// New creates the logger described by cfg at level. The optional toggle lowers
+// the level of the logger and all loggers derived with WithLevel at runtime.
+// The returned closer closes the log file, if any.
+func New(level slog.Leveler, cfg config.LoggingConfig, toggle *Toggle) (*slog.Logger, io.Closer, error) {
+	timeFormat, err := parseTimeFormat(cfg.TimeFormat)
+	if err != nil {
+		return nil, nil, err
+	}
+	var w io.Writer
+	var closer io.Closer = nopCloser{}
+	switch cfg.Output {
+	case "", "stdout":
+		w = os.Stdout
+	case "stderr":
+		w = os.Stderr
+	default:
+		file, err := openRotatingFile(cfg.Output, cfg.Rotation.MaxSizeBytes, cfg.Rotation.MaxBackups)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open log file: %w", err)
+		}
+		w, closer = file, file
+	}
+
+	var handler slog.Handler
+	opts := &slog.HandlerOptions{Level: allLevels, ReplaceAttr: timeFormat.replaceAttr}
+	switch cfg.Format {
+	case "", config.LogFormatJSON:
+		handler = slog.NewJSONHandler(w, opts)
+	case config.LogFormatLogfmt:
+		handler = slog.NewTextHandler(w, opts)
+	case config.LogFormatText:
+		handler = newTextHandler(w, timeFormat.append)
+	default:
+		closer.Close()
+		return nil, nil, fmt.Errorf("unknown log format %q, expected json, logfmt or text", cfg.Format)
+	}
+	return slog.New(&levelHandler{level: level, toggle: toggle, handler: handler}), closer, nil
+}
+
+// Validate checks cfg without opening the log file.
+func Validate(cfg config.LoggingConfig) error {
+	switch cfg.Format {
+	case "", config.LogFormatJSON, config.LogFormatLogfmt, config.LogFormatText:
+	default:
+		return fmt.Errorf("unknown log format %q, expected json, logfmt or text", cfg.Format)
+	}
+	_, err := parseTimeFormat(cfg.TimeFormat)
+	return err
+}
+
+type nopCloser struct{}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// timeFormat formats record timestamps as either a layout or a unix time.
+type timeFormat struct {
+	layout string
+	unit   time.Duration
+}
+
+func parseTimeFormat(s string) (timeFormat, error) {
+	switch strings.ToLower(s) {
+	case "", "rfc3339nano":
+		return timeFormat{layout: time.RFC3339Nano}, nil
+	case "rfc3339":
+		return timeFormat{layout: time.RFC3339}, nil
+	case "unix":
+		return timeFormat{unit: time.Second}, nil
+	case "unixmilli":
+		return timeFormat{unit: time.Millisecond}, nil
+	}
+	// A layout has to format the reference time into something else.
+	if time.Unix(0, 0).UTC().Format(s) == s {
+		return timeFormat{}, fmt.Errorf("invalid time format %q", s)
+	}
+	return timeFormat{layout: s}, nil
+}
+
+func (f timeFormat) value(t time.Time) slog.Value {
+	if f.unit > 0 {
+		return slog.Int64Value(t.UnixNano() / int64(f.unit))
+	}
+	return slog.StringValue(t.Format(f.layout))
+}
+
+func (f timeFormat) replaceAttr(groups []string, attr slog.Attr) slog.Attr {
+	if len(groups) == 0 && attr.Key == slog.TimeKey && attr.Value.Kind() == slog.KindTime {
+		attr.Value = f.value(attr.Value.Time())
+	}
+	return attr
+}
+
+func (f timeFormat) append(buf []byte, record slog.Record) []byte {
+	if f.unit > 0 {
+		return strconv.AppendInt(buf, record.Time.UnixNano()/int64(f.unit), 10)
+	}
+	return record.Time.AppendFormat(buf, f.layout)
+}
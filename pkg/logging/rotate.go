@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+)
+
+const defaultMaxBackups = 5
+
+// rotatingFile appends to path and renames it to path.1, path.2, ... once it
+// would grow beyond maxSize, keeping maxBackups old files.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+}
+
+func openRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	if maxBackups <= 0 {
+		maxBackups = defaultMaxBackups
+	}
+	f := &rotatingFile{path: path, maxSize: maxSize, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	os.Remove(fmt.Sprintf("%s.%d", f.path, f.maxBackups))
+	for i := f.maxBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+	}
+	// Keep writing to the same file when it can not be renamed, there is no
+	// better place to report the error.
+	renameErr := os.Rename(f.path, f.path+".1")
+	if err := f.open(); err != nil {
+		return errors.Join(renameErr, err)
+	}
+	return nil
+}
+
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Close()
+}
@@ -0,0 +1,116 @@
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"golang.org/x/exp/slog"
+)
+
+// textHandler writes one human readable line per record:
+//
+//	2024-06-01T12:00:00.000Z INFO  Cache synced successfully key=value
+//
+// Structured values such as events are written as JSON.
+type textHandler struct {
+	w          io.Writer
+	mu         *sync.Mutex
+	timeFormat func(buf []byte, record slog.Record) []byte
+	// attrs are preformatted, prefix is the dotted path of the open groups.
+	attrs  string
+	prefix string
+}
+
+// newTextHandler returns a handler for all levels, New wraps it in a levelHandler.
+func newTextHandler(w io.Writer, timeFormat func([]byte, slog.Record) []byte) *textHandler {
+	return &textHandler{w: w, mu: &sync.Mutex{}, timeFormat: timeFormat}
+}
+
+func (h *textHandler) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (h *textHandler) Handle(_ context.Context, record slog.Record) error {
+	buf := h.timeFormat(nil, record)
+	buf = append(buf, ' ')
+	buf = append(buf, fmt.Sprintf("%-5s", record.Level.String())...)
+	buf = append(buf, ' ')
+	buf = append(buf, record.Message...)
+	buf = append(buf, h.attrs...)
+	record.Attrs(func(attr slog.Attr) bool {
+		buf = appendAttr(buf, h.prefix, attr)
+		return true
+	})
+	buf = append(buf, '\n')
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.w.Write(buf)
+	return err
+}
+
+func (h *textHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	clone := *h
+	var buf []byte
+	for _, attr := range attrs {
+		buf = appendAttr(buf, h.prefix, attr)
+	}
+	clone.attrs += string(buf)
+	return &clone
+}
+
+func (h *textHandler) WithGroup(name string) slog.Handler {
+	clone := *h
+	clone.prefix += name + "."
+	return &clone
+}
+
+func appendAttr(buf []byte, prefix string, attr slog.Attr) []byte {
+	if attr.Equal(slog.Attr{}) {
+		return buf
+	}
+	value := attr.Value.Resolve()
+	if value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, child := range value.Group() {
+			buf = appendAttr(buf, prefix, child)
+		}
+		return buf
+	}
+	buf = append(buf, ' ')
+	buf = append(buf, prefix...)
+	buf = append(buf, attr.Key...)
+	buf = append(buf, '=')
+	return append(buf, formatValue(value)...)
+}
+
+func formatValue(value slog.Value) string {
+	var s string
+	switch value.Kind() {
+	case slog.KindString:
+		s = value.String()
+	case slog.KindAny:
+		if err, ok := value.Any().(error); ok {
+			s = err.Error()
+			break
+		}
+		data, err := json.Marshal(value.Any())
+		if err != nil {
+			s = fmt.Sprint(value.Any())
+			break
+		}
+		return string(data)
+	default:
+		return value.String()
+	}
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return strconv.Quote(s)
+	}
+	return s
+}
@@ -0,0 +1,50 @@
+package logging
+
+import (
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// Toggle temporarily lowers the log level, e.g. to debug event filtering in
+// production without a restart. The level reverts on its own once the
+// duration passed. A nil Toggle is never raised.
+type Toggle struct {
+	level atomic.Int64
+	// until is the UnixNano time the override ends, zero when not raised.
+	until atomic.Int64
+}
+
+func NewToggle() *Toggle {
+	return &Toggle{}
+}
+
+// Raise logs records at level and above for d.
+func (t *Toggle) Raise(level slog.Level, d time.Duration) {
+	t.level.Store(int64(level))
+	t.until.Store(time.Now().Add(d).UnixNano())
+}
+
+// Reset ends the override.
+func (t *Toggle) Reset() {
+	t.until.Store(0)
+}
+
+// Status returns the override level and when it ends, ok is false when the
+// toggle is not raised.
+func (t *Toggle) Status() (level slog.Level, until time.Time, ok bool) {
+	if t == nil {
+		return 0, time.Time{}, false
+	}
+	nanos := t.until.Load()
+	if nanos == 0 || time.Now().UnixNano() >= nanos {
+		return 0, time.Time{}, false
+	}
+	return slog.Level(t.level.Load()), time.Unix(0, nanos), true
+}
+
+func (t *Toggle) enabled(level slog.Level) bool {
+	override, _, ok := t.Status()
+	return ok && level >= override
+}
@@ -0,0 +1,78 @@
+// Package metrics holds the Prometheus metrics of the watcher.
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var DroppedEventsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "k8s_resource_watcher_events_dropped_total",
+	Help: "Number of events dropped before reaching a sink.",
+}, []string{"scope", "name", "reason"})
+
+var QueueDepth = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "k8s_resource_watcher_queue_depth",
+	Help: "Number of events waiting in the internal queue.",
+})
+
+var QueueCapacity = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "k8s_resource_watcher_queue_capacity",
+	Help: "Capacity of the internal event queue.",
+})
+
+var WatchErrorsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "k8s_resource_watcher_watch_errors_total",
+	Help: "Number of failed list/watch calls per resource.",
+}, []string{"group", "version", "resource", "reason"})
+
+var RelistsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "k8s_resource_watcher_relists_total",
+	Help: "Number of relists after a watch ended, reason is expired when the resource version was too old.",
+}, []string{"group", "version", "resource", "reason"})
+
+var LastRelistTimestamp = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "k8s_resource_watcher_last_relist_timestamp_seconds",
+	Help: "Unix time of the last relist per resource.",
+}, []string{"group", "version", "resource"})
+
+var HandlerBacklog = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "k8s_resource_watcher_handler_backlog",
+	Help: "Number of informer notifications waiting for a handler worker per resource.",
+}, []string{"group", "version", "resource"})
+
+var FlappingEventsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "k8s_resource_watcher_flapping_events_total",
+	Help: "Number of objects detected as flapping.",
+}, []string{"group", "version", "resource"})
+
+var TruncatedEventsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "k8s_resource_watcher_truncated_events_total",
+	Help: "Number of events truncated or summarized for exceeding the maximum payload size.",
+}, []string{"group", "version", "resource"})
+
+// latencyBuckets range from the second granularity of Kubernetes timestamps
+// to the minutes of a stalled watch or sink.
+var latencyBuckets = []float64{0.5, 1, 2, 5, 10, 30, 60, 120, 300, 600}
+
+var EventLagSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "k8s_resource_watcher_event_lag_seconds",
+	Help:    "Seconds between the change of an object in the cluster and its event.",
+	Buckets: latencyBuckets,
+}, []string{"group", "version", "resource"})
+
+var DeliveryLatencySeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "k8s_resource_watcher_delivery_latency_seconds",
+	Help:    "Seconds between an event and its delivery to a sink.",
+	Buckets: latencyBuckets,
+}, []string{"sink"})
+
+var OutboxPending = promauto.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "k8s_resource_watcher_outbox_pending",
+	Help: "Number of events in the outbox of an at-least-once sink waiting for delivery.",
+}, []string{"sink"})
+
+var DeliveryRetriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "k8s_resource_watcher_delivery_retries_total",
+	Help: "Number of failed deliveries of at-least-once sinks that are retried.",
+}, []string{"sink"})
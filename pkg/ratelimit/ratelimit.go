@@ -0,0 +1,47 @@
+// Package ratelimit provides the token bucket applied to events.
+package ratelimit
+
+import (
+	"context"
+
+	"golang.org/x/time/rate"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+)
+
+// Limiter is a token bucket applied to events. A nil limiter allows everything.
+type Limiter struct {
+	limiter *rate.Limiter
+	policy  string
+}
+
+// New returns nil when the config does not set a rate.
+func New(cfg config.RateLimitConfig) *Limiter {
+	if cfg.EventsPerSecond <= 0 {
+		return nil
+	}
+	burst := cfg.Burst
+	if burst <= 0 {
+		burst = 1
+	}
+	policy := cfg.Policy
+	if policy == "" {
+		policy = config.RateLimitPolicyDrop
+	}
+	return &Limiter{
+		limiter: rate.NewLimiter(rate.Limit(cfg.EventsPerSecond), burst),
+		policy:  policy,
+	}
+}
+
+// Allow reports whether the event may pass. With the queue policy it waits
+// for a token instead of dropping the event.
+func (l *Limiter) Allow(ctx context.Context) bool {
+	if l == nil {
+		return true
+	}
+	if l.policy == config.RateLimitPolicyQueue {
+		return l.limiter.Wait(ctx) == nil
+	}
+	return l.limiter.Allow()
+}
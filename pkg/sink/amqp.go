@@ -0,0 +1,136 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"golang.org/x/exp/slog"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+const defaultAMQPRoutingKey = "{group}.{version}.{resource}.{namespace}.{eventType}"
+
+// AMQPSink publishes every event as a persistent message to an AMQP
+// 0.9.1 exchange, e.g. of RabbitMQ. The connection is opened on the first
+// event and opened again after it was lost.
+type AMQPSink struct {
+	cfg     config.AMQPSinkConfig
+	logger  *slog.Logger
+	encoder encoder
+
+	mu      sync.Mutex
+	conn    *amqp.Connection
+	channel *amqp.Channel
+}
+
+func NewAMQPSink(cfg *config.AMQPSinkConfig, format config.FormatConfig, logger *slog.Logger) (*AMQPSink, error) {
+	if cfg == nil || cfg.URL == "" {
+		return nil, fmt.Errorf("amqp sink requires amqp.url")
+	}
+	if _, err := amqp.ParseURI(cfg.URL); err != nil {
+		return nil, fmt.Errorf("invalid amqp.url: %w", err)
+	}
+	encoder, err := newEncoder(format)
+	if err != nil {
+		return nil, err
+	}
+	s := &AMQPSink{cfg: *cfg, logger: logger, encoder: encoder}
+	if s.cfg.RoutingKey == "" {
+		s.cfg.RoutingKey = defaultAMQPRoutingKey
+	}
+	return s, nil
+}
+
+func (s *AMQPSink) Send(ctx context.Context, ev event.Event) error {
+	data, err := s.encoder.Encode(ctx, ev)
+	if err != nil {
+		return err
+	}
+	headers := amqp.Table{}
+	for key, value := range messageAttributes(ev) {
+		headers[key] = value
+	}
+	msg := amqp.Publishing{
+		ContentType:  s.encoder.ContentType(),
+		DeliveryMode: amqp.Persistent,
+		Timestamp:    ev.Timestamp,
+		Type:         ev.Type,
+		Headers:      headers,
+		Body:         data,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// A connection that broke since the last event is only noticed when
+	// publishing, so the event is retried once on a new connection.
+	for attempt := 0; ; attempt++ {
+		err := s.publish(ctx, expandKey(s.cfg.RoutingKey, ev), msg)
+		if err == nil || attempt > 0 || ctx.Err() != nil {
+			return err
+		}
+		s.logger.Warn("Failed to publish to AMQP, reconnecting", "error", err)
+		s.closeConnection()
+	}
+}
+
+func (s *AMQPSink) publish(ctx context.Context, routingKey string, msg amqp.Publishing) error {
+	if s.channel == nil || s.channel.IsClosed() {
+		s.closeConnection()
+		if err := s.connect(); err != nil {
+			return err
+		}
+	}
+	confirmation, err := s.channel.PublishWithDeferredConfirmWithContext(ctx, s.cfg.Exchange, routingKey, false, false, msg)
+	if err != nil {
+		return err
+	}
+	if confirmation == nil {
+		return nil
+	}
+	acked, err := confirmation.WaitContext(ctx)
+	if err != nil {
+		return err
+	}
+	if !acked {
+		return fmt.Errorf("broker rejected the message")
+	}
+	return nil
+}
+
+func (s *AMQPSink) connect() error {
+	conn, err := amqp.Dial(s.cfg.URL)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	channel, err := conn.Channel()
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to open channel: %w", err)
+	}
+	if s.cfg.Confirm {
+		if err := channel.Confirm(false); err != nil {
+			conn.Close()
+			return fmt.Errorf("failed to enable publisher confirms: %w", err)
+		}
+	}
+	s.conn, s.channel = conn, channel
+	return nil
+}
+
+func (s *AMQPSink) closeConnection() {
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	s.conn, s.channel = nil, nil
+}
+
+func (s *AMQPSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closeConnection()
+	return nil
+}
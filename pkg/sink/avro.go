@@ -0,0 +1,258 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+// avroEncoder writes events in the Avro binary encoding of event.AvroSchema.
+// With a schema registry the schema is registered on the first event and
+// every message starts with its ID in the Confluent wire format: a zero
+// magic byte followed by the big-endian 4 byte schema ID.
+type avroEncoder struct {
+	registry config.SchemaRegistryConfig
+	client   *http.Client
+
+	mu       sync.Mutex
+	schemaID int
+}
+
+func newAvroEncoder(registry config.SchemaRegistryConfig) (*avroEncoder, error) {
+	if registry.URL != "" && registry.Subject == "" {
+		return nil, fmt.Errorf("format.schemaRegistry requires subject")
+	}
+	return &avroEncoder{registry: registry, client: &http.Client{Timeout: httpTimeout(registry.Timeout)}}, nil
+}
+
+func (e *avroEncoder) ContentType() string {
+	return "application/avro"
+}
+
+func (e *avroEncoder) Encode(ctx context.Context, ev event.Event) ([]byte, error) {
+	var w avroWriter
+	if e.registry.URL != "" {
+		id, err := e.register(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to register schema: %w", err)
+		}
+		w.buf.WriteByte(0)
+		_ = binary.Write(&w.buf, binary.BigEndian, int32(id))
+	}
+	w.string(ev.SchemaVersion)
+	w.string(ev.Cluster)
+	w.string(ev.GVR.Group)
+	w.string(ev.GVR.Version)
+	w.string(ev.GVR.Resource)
+	w.string(ev.Namespace)
+	w.string(ev.Name)
+	w.string(ev.UID)
+	w.string(ev.Type)
+	w.time(ev.Timestamp)
+	for _, obj := range []map[string]interface{}{ev.Object, ev.OldObject} {
+		if err := w.optionalJSON(obj, obj == nil); err != nil {
+			return nil, err
+		}
+	}
+	w.long(int64(len(ev.Diff)))
+	for _, change := range ev.Diff {
+		w.string(change.Path)
+		for _, value := range []interface{}{change.Old, change.New} {
+			if err := w.optionalJSON(value, value == nil); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(ev.Diff) > 0 {
+		w.long(0)
+	}
+	w.string(ev.TextDiff)
+	w.optional(ev.Owner != nil, func() {
+		w.strings(ev.Owner.APIVersion, ev.Owner.Kind, ev.Owner.Name, ev.Owner.UID)
+	})
+	w.optional(ev.ChangedBy != nil, func() {
+		w.strings(ev.ChangedBy.Manager, ev.ChangedBy.Operation, ev.ChangedBy.Subresource)
+		w.time(ev.ChangedBy.Time)
+	})
+	w.optional(ev.InvolvedObject != nil, func() {
+		ref := ev.InvolvedObject
+		w.strings(ref.APIVersion, ref.Kind, ref.Namespace, ref.Name, ref.UID)
+	})
+	w.long(int64(len(ev.Groups)))
+	for _, group := range ev.Groups {
+		w.strings(group.Name, group.Key)
+	}
+	if len(ev.Groups) > 0 {
+		w.long(0)
+	}
+	w.stringMap(ev.ClusterMetadata)
+	w.long(int64(len(ev.Alerts)))
+	for _, alert := range ev.Alerts {
+		w.strings(alert.Rule, alert.Status, alert.Severity, alert.Summary, alert.DedupKey)
+	}
+	if len(ev.Alerts) > 0 {
+		w.long(0)
+	}
+	w.stringMap(ev.Annotations)
+	w.boolean(ev.Truncated)
+	w.optional(ev.Signature != nil, func() {
+		signature := ev.Signature
+		w.strings(signature.Algorithm, signature.KeyID, signature.PrevHash, signature.Hash, signature.Value)
+	})
+	w.long(int64(len(ev.Images)))
+	for _, image := range ev.Images {
+		w.strings(image.Container, image.Old, image.New)
+	}
+	if len(ev.Images) > 0 {
+		w.long(0)
+	}
+	w.optional(ev.Scale != nil, func() {
+		w.long(ev.Scale.From)
+		w.long(ev.Scale.To)
+	})
+	w.long(int64(len(ev.Impacted)))
+	for _, ref := range ev.Impacted {
+		w.strings(ref.APIVersion, ref.Kind, ref.Namespace, ref.Name, ref.UID)
+	}
+	if len(ev.Impacted) > 0 {
+		w.long(0)
+	}
+	w.string(ev.Tenant)
+	w.string(ev.PreviousUID)
+	w.optional(ev.Summary != nil, func() {
+		w.long(ev.Summary.Objects)
+		w.long(int64(len(ev.Summary.Namespaces)))
+		for _, namespace := range ev.Summary.Namespaces {
+			w.string(namespace)
+		}
+		if len(ev.Summary.Namespaces) > 0 {
+			w.long(0)
+		}
+		w.string(ev.Summary.Checksum)
+	})
+	return w.buf.Bytes(), nil
+}
+
+// register returns the ID of the schema under the configured subject,
+// registering it the first time.
+func (e *avroEncoder) register(ctx context.Context) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.schemaID != 0 {
+		return e.schemaID, nil
+	}
+	body, err := json.Marshal(map[string]string{"schema": event.AvroSchema})
+	if err != nil {
+		return 0, err
+	}
+	endpoint := fmt.Sprintf("%s/subjects/%s/versions", strings.TrimSuffix(e.registry.URL, "/"), url.PathEscape(e.registry.Subject))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/vnd.schemaregistry.v1+json")
+	if e.registry.Username != "" {
+		req.SetBasicAuth(e.registry.Username, e.registry.Password)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return 0, fmt.Errorf("%s returned %s: %s", endpoint, resp.Status, bytes.TrimSpace(respBody))
+	}
+	var registered struct {
+		ID int `json:"id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&registered); err != nil {
+		return 0, err
+	}
+	e.schemaID = registered.ID
+	return e.schemaID, nil
+}
+
+// avroWriter implements the parts of the Avro binary encoding used by the
+// event schema.
+type avroWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *avroWriter) long(v int64) {
+	w.buf.Write(binary.AppendVarint(nil, v))
+}
+
+func (w *avroWriter) boolean(v bool) {
+	if v {
+		w.buf.WriteByte(1)
+	} else {
+		w.buf.WriteByte(0)
+	}
+}
+
+func (w *avroWriter) string(s string) {
+	w.long(int64(len(s)))
+	w.buf.WriteString(s)
+}
+
+func (w *avroWriter) strings(values ...string) {
+	for _, s := range values {
+		w.string(s)
+	}
+}
+
+// time writes a timestamp-micros, the zero time as 0.
+func (w *avroWriter) time(t time.Time) {
+	if t.IsZero() {
+		w.long(0)
+		return
+	}
+	w.long(t.UnixMicro())
+}
+
+// optional writes a ["null", T] union, write writes the T value.
+func (w *avroWriter) optional(present bool, write func()) {
+	if !present {
+		w.long(0)
+		return
+	}
+	w.long(1)
+	write()
+}
+
+func (w *avroWriter) optionalJSON(value interface{}, null bool) error {
+	if null {
+		w.long(0)
+		return nil
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	w.long(1)
+	w.string(string(data))
+	return nil
+}
+
+// stringMap writes a map as a single block followed by the empty block.
+func (w *avroWriter) stringMap(m map[string]string) {
+	if len(m) > 0 {
+		w.long(int64(len(m)))
+		for _, key := range sortedKeys(m) {
+			w.strings(key, m[key])
+		}
+	}
+	w.long(0)
+}
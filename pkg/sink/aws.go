@@ -0,0 +1,207 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// awsCredentials are the keys requests to AWS are signed with.
+type awsCredentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+	// expires is zero for static credentials.
+	expires time.Time
+}
+
+// awsCredentialSource reads the credentials from the environment. With IAM
+// roles for service accounts (IRSA) the EKS pod identity webhook sets
+// AWS_ROLE_ARN and AWS_WEB_IDENTITY_TOKEN_FILE, the projected token is
+// exchanged for temporary credentials via STS and refreshed before they
+// expire. Otherwise AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY are used.
+type awsCredentialSource struct {
+	region string
+	client *http.Client
+
+	mu          sync.Mutex
+	credentials awsCredentials
+}
+
+func (s *awsCredentialSource) Credentials(ctx context.Context) (awsCredentials, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.credentials.accessKeyID != "" && (s.credentials.expires.IsZero() || time.Now().Before(s.credentials.expires)) {
+		return s.credentials, nil
+	}
+	roleARN, tokenFile := os.Getenv("AWS_ROLE_ARN"), os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE")
+	if roleARN != "" && tokenFile != "" {
+		credentials, err := s.assumeRoleWithWebIdentity(ctx, roleARN, tokenFile)
+		if err != nil {
+			return awsCredentials{}, fmt.Errorf("failed to assume role %s: %w", roleARN, err)
+		}
+		s.credentials = credentials
+		return credentials, nil
+	}
+	if os.Getenv("AWS_ACCESS_KEY_ID") == "" || os.Getenv("AWS_SECRET_ACCESS_KEY") == "" {
+		return awsCredentials{}, fmt.Errorf("no AWS credentials, set up IRSA or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	s.credentials = awsCredentials{
+		accessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	return s.credentials, nil
+}
+
+func (s *awsCredentialSource) assumeRoleWithWebIdentity(ctx context.Context, roleARN, tokenFile string) (awsCredentials, error) {
+	token, err := os.ReadFile(tokenFile)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	sessionName := os.Getenv("AWS_ROLE_SESSION_NAME")
+	if sessionName == "" {
+		sessionName = fmt.Sprintf("k8s-resource-watcher-%d", time.Now().Unix())
+	}
+	endpoint := "https://sts.amazonaws.com/"
+	if s.region != "" {
+		endpoint = fmt.Sprintf("https://sts.%s.amazonaws.com/", s.region)
+	}
+	form := url.Values{
+		"Action":           {"AssumeRoleWithWebIdentity"},
+		"Version":          {"2011-06-15"},
+		"RoleArn":          {roleARN},
+		"RoleSessionName":  {sessionName},
+		"WebIdentityToken": {strings.TrimSpace(string(token))},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	body, err := doAWSRequest(s.client, req)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	var response struct {
+		Credentials struct {
+			AccessKeyID     string    `xml:"AccessKeyId"`
+			SecretAccessKey string    `xml:"SecretAccessKey"`
+			SessionToken    string    `xml:"SessionToken"`
+			Expiration      time.Time `xml:"Expiration"`
+		} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+	}
+	if err := xml.Unmarshal(body, &response); err != nil {
+		return awsCredentials{}, err
+	}
+	return awsCredentials{
+		accessKeyID:     response.Credentials.AccessKeyID,
+		secretAccessKey: response.Credentials.SecretAccessKey,
+		sessionToken:    response.Credentials.SessionToken,
+		expires:         response.Credentials.Expiration.Add(-5 * time.Minute),
+	}, nil
+}
+
+// postAWSForm sends a query API request signed with signature version 4.
+func postAWSForm(ctx context.Context, client *http.Client, credentials *awsCredentialSource, service, endpoint string, form url.Values) error {
+	creds, err := credentials.Credentials(ctx)
+	if err != nil {
+		return err
+	}
+	body := form.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	signAWSRequest(req, []byte(body), creds, credentials.region, service, time.Now().UTC())
+	_, err = doAWSRequest(client, req)
+	return err
+}
+
+func doAWSRequest(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("%s returned %s: %s", req.URL.Host, resp.Status, bytes.TrimSpace(body))
+	}
+	return body, nil
+}
+
+// signAWSRequest adds the signature version 4 headers to req.
+func signAWSRequest(req *http.Request, body []byte, creds awsCredentials, region, service string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.sessionToken)
+	}
+	payloadHash := sha256Hex(body)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for key, values := range req.Header {
+		headers[strings.ToLower(key)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		strings.ReplaceAll(req.URL.Query().Encode(), "+", "%20"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+	key := hmacSHA256([]byte("AWS4"+creds.secretAccessKey), date)
+	for _, part := range []string{region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,90 @@
+package sink
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+)
+
+const defaultBatchMaxLatency = time.Second
+
+// batcher collects encoded events and hands them to send once maxEvents or
+// maxBytes is reached, or at the latest after maxLatency. Batches are sent
+// one at a time in the order the events were added.
+type batcher struct {
+	cfg    config.BatchConfig
+	send   func(ctx context.Context, batch [][]byte) error
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	pending [][]byte
+	size    int
+	// sending serializes batches, it is held while a batch is sent.
+	sending sync.Mutex
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func newBatcher(cfg config.BatchConfig, logger *slog.Logger, send func(context.Context, [][]byte) error) *batcher {
+	if cfg.MaxLatency <= 0 {
+		cfg.MaxLatency = defaultBatchMaxLatency
+	}
+	b := &batcher{cfg: cfg, send: send, logger: logger, stop: make(chan struct{}), done: make(chan struct{})}
+	go b.flushLoop()
+	return b
+}
+
+// Add queues data. When the batch is full it is sent right away and the
+// error of sending it is returned.
+func (b *batcher) Add(ctx context.Context, data []byte) error {
+	b.mu.Lock()
+	b.pending = append(b.pending, data)
+	b.size += len(data)
+	full := (b.cfg.MaxEvents > 0 && len(b.pending) >= b.cfg.MaxEvents) ||
+		(b.cfg.MaxBytes > 0 && b.size >= b.cfg.MaxBytes)
+	b.mu.Unlock()
+	if !full {
+		return nil
+	}
+	return b.Flush(ctx)
+}
+
+// Flush sends the pending events.
+func (b *batcher) Flush(ctx context.Context) error {
+	b.sending.Lock()
+	defer b.sending.Unlock()
+	b.mu.Lock()
+	batch := b.pending
+	b.pending, b.size = nil, 0
+	b.mu.Unlock()
+	if len(batch) == 0 {
+		return nil
+	}
+	return b.send(ctx, batch)
+}
+
+func (b *batcher) flushLoop() {
+	defer close(b.done)
+	ticker := time.NewTicker(b.cfg.MaxLatency)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := b.Flush(context.Background()); err != nil {
+				b.logger.Error("Failed to send batch", "error", err)
+			}
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+// Close stops the periodic flush, pending events are sent by Flush.
+func (b *batcher) Close() {
+	close(b.stop)
+	<-b.done
+}
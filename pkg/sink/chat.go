@@ -0,0 +1,168 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"text/template"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+const (
+	telegramURL         = "https://api.telegram.org"
+	defaultChatTemplate = `{{.Type}} {{.GVR.Resource}} {{if .Namespace}}{{.Namespace}}/{{end}}{{.Name}}
+{{- range .Diff}}
+{{.Path}}: {{.Old}} -> {{.New}}
+{{- end}}`
+	// Longer messages are rejected by Discord and Telegram.
+	discordMaxLength  = 2000
+	telegramMaxLength = 4096
+)
+
+// Chat sink types.
+const (
+	chatTeams    = "teams"
+	chatDiscord  = "discord"
+	chatTelegram = "telegram"
+)
+
+type chatRoute struct {
+	cfg      config.ChatRouteConfig
+	template *template.Template
+}
+
+// ChatSink posts every event as a message rendered from a template to a
+// Microsoft Teams or Discord incoming webhook or a Telegram chat. Routes can
+// send the events of some resources to other destinations.
+type ChatSink struct {
+	kind     string
+	cfg      config.ChatSinkConfig
+	template *template.Template
+	routes   []chatRoute
+	client   *http.Client
+}
+
+func NewChatSink(kind string, cfg *config.ChatSinkConfig) (*ChatSink, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("%s sink requires a %s section", kind, kind)
+	}
+	s := &ChatSink{kind: kind, cfg: *cfg, client: &http.Client{Timeout: httpTimeout(cfg.Timeout)}}
+	var err error
+	if s.template, err = template.New(kind).Parse(valueOr(cfg.Template, defaultChatTemplate)); err != nil {
+		return nil, fmt.Errorf("invalid %s.template: %w", kind, err)
+	}
+	if kind == chatTelegram && cfg.BotToken == "" {
+		return nil, fmt.Errorf("telegram sink requires telegram.botToken")
+	}
+	if err := s.checkDestination(cfg.URL, cfg.ChatID, len(cfg.Routes) > 0); err != nil {
+		return nil, err
+	}
+	for i, route := range cfg.Routes {
+		r := chatRoute{cfg: route, template: s.template}
+		if route.Template != "" {
+			if r.template, err = template.New(kind).Parse(route.Template); err != nil {
+				return nil, fmt.Errorf("invalid %s.routes[%d].template: %w", kind, i, err)
+			}
+		}
+		if route.URL == "" && route.ChatID == "" {
+			if err := s.checkDestination(cfg.URL, cfg.ChatID, false); err != nil {
+				return nil, fmt.Errorf("%s.routes[%d]: %w", kind, i, err)
+			}
+		}
+		s.routes = append(s.routes, r)
+	}
+	if s.cfg.URL == "" && kind == chatTelegram {
+		s.cfg.URL = telegramURL
+	}
+	return s, nil
+}
+
+// checkDestination reports whether the sink has a destination, which is
+// optional when routes may provide it.
+func (s *ChatSink) checkDestination(url, chatID string, optional bool) error {
+	switch {
+	case optional:
+		return nil
+	case s.kind == chatTelegram && chatID == "":
+		return fmt.Errorf("telegram sink requires telegram.chatID")
+	case s.kind != chatTelegram && url == "":
+		return fmt.Errorf("%s sink requires %s.url", s.kind, s.kind)
+	}
+	return nil
+}
+
+func (s *ChatSink) Send(ctx context.Context, ev event.Event) error {
+	url, chatID, tmpl := s.cfg.URL, s.cfg.ChatID, s.template
+	if route := s.route(ev); route != nil {
+		tmpl = route.template
+		if route.cfg.URL != "" {
+			url = route.cfg.URL
+		}
+		if route.cfg.ChatID != "" {
+			chatID = route.cfg.ChatID
+		}
+	} else if s.checkDestination(url, chatID, false) != nil {
+		// No default destination, only routed events are sent.
+		return nil
+	}
+	var text bytes.Buffer
+	if err := tmpl.Execute(&text, ev); err != nil {
+		return fmt.Errorf("failed to render message: %w", err)
+	}
+	switch s.kind {
+	case chatTeams:
+		return postJSON(ctx, s.client, url, nil, teamsCard(text.String()))
+	case chatDiscord:
+		return postJSON(ctx, s.client, url, nil, map[string]string{"content": cut(text.String(), discordMaxLength)})
+	default:
+		endpoint := fmt.Sprintf("%s/bot%s/sendMessage", url, s.cfg.BotToken)
+		return postJSON(ctx, s.client, endpoint, nil, map[string]string{"chat_id": chatID, "text": cut(text.String(), telegramMaxLength)})
+	}
+}
+
+// route returns the first route matching the resource and namespace of ev.
+func (s *ChatSink) route(ev event.Event) *chatRoute {
+	for i := range s.routes {
+		route := &s.routes[i]
+		if (route.cfg.Group == "" || route.cfg.Group == ev.GVR.Group) &&
+			(route.cfg.Resource == "" || route.cfg.Resource == ev.GVR.Resource) &&
+			(route.cfg.Namespace == "" || route.cfg.Namespace == ev.Namespace) {
+			return route
+		}
+	}
+	return nil
+}
+
+func (s *ChatSink) Close() error {
+	return nil
+}
+
+// teamsCard wraps text into an Adaptive Card message for Teams webhooks.
+func teamsCard(text string) map[string]interface{} {
+	return map[string]interface{}{
+		"type": "message",
+		"attachments": []interface{}{map[string]interface{}{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content": map[string]interface{}{
+				"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+				"type":    "AdaptiveCard",
+				"version": "1.4",
+				"body": []interface{}{
+					map[string]interface{}{"type": "TextBlock", "text": text, "wrap": true},
+				},
+			},
+		}},
+	}
+}
+
+// cut shortens text to at most maxLength runes.
+func cut(text string, maxLength int) string {
+	runes := []rune(text)
+	if len(runes) <= maxLength {
+		return text
+	}
+	return string(runes[:maxLength-1]) + "…"
+}
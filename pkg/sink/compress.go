@@ -0,0 +1,42 @@
+package sink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+
+	"github.com/klauspost/compress/zstd"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+)
+
+// zstdEncoder is safe for concurrent EncodeAll calls.
+var zstdEncoder, _ = zstd.NewWriter(nil)
+
+func validateCompression(compression string) error {
+	switch compression {
+	case "", config.CompressionGzip, config.CompressionZstd:
+		return nil
+	}
+	return fmt.Errorf("unknown compression %q, expected gzip or zstd", compression)
+}
+
+// compress encodes data with compression, the returned value is the matching
+// Content-Encoding header or empty when data is returned unchanged.
+func compress(compression string, data []byte) ([]byte, string, error) {
+	switch compression {
+	case config.CompressionGzip:
+		var buf bytes.Buffer
+		w := gzip.NewWriter(&buf)
+		if _, err := w.Write(data); err != nil {
+			return nil, "", err
+		}
+		if err := w.Close(); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "gzip", nil
+	case config.CompressionZstd:
+		return zstdEncoder.EncodeAll(data, nil), "zstd", nil
+	}
+	return data, "", nil
+}
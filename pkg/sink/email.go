@@ -0,0 +1,221 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+	"time"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+const (
+	defaultDigestInterval  = 24 * time.Hour
+	defaultDigestMaxEvents = 1000
+	defaultSMTPPort        = 587
+	defaultDigestSubject   = `{{if .Cluster}}{{.Cluster}}: {{end}}{{len .Events}} Kubernetes changes`
+	defaultDigestText      = `Changes from {{.Since.Format "2006-01-02 15:04"}} to {{.Until.Format "2006-01-02 15:04 MST"}}:
+{{range .Events}}
+{{.Timestamp.Format "15:04:05"}} {{.Type}} {{.GVR.Resource}} {{if .Namespace}}{{.Namespace}}/{{end}}{{.Name}}
+{{- range .Diff}}
+    {{.Path}}: {{.Old}} -> {{.New}}
+{{- end}}
+{{- end}}
+{{if .Dropped}}
+{{.Dropped}} more changes were not included.
+{{end}}`
+	defaultDigestHTML = `<p>Changes from {{.Since.Format "2006-01-02 15:04"}} to {{.Until.Format "2006-01-02 15:04 MST"}}:</p>
+<table>
+<tr><th>Time</th><th>Event</th><th>Resource</th><th>Object</th><th>Changes</th></tr>
+{{range .Events}}<tr>
+<td>{{.Timestamp.Format "15:04:05"}}</td><td>{{.Type}}</td><td>{{.GVR.Resource}}</td>
+<td>{{if .Namespace}}{{.Namespace}}/{{end}}{{.Name}}</td>
+<td>{{range .Diff}}<code>{{.Path}}</code>: {{.Old}} &rarr; {{.New}}<br>{{end}}</td>
+</tr>
+{{end}}</table>
+{{if .Dropped}}<p>{{.Dropped}} more changes were not included.</p>{{end}}`
+)
+
+// digest is the data of the email templates.
+type digest struct {
+	Cluster string
+	Since   time.Time
+	Until   time.Time
+	Events  []event.Event
+	// Dropped counts the events beyond maxEvents.
+	Dropped int
+}
+
+// EmailSink collects events and mails them as a digest once per interval.
+// Digests without events are not sent.
+type EmailSink struct {
+	cfg     config.EmailSinkConfig
+	logger  *slog.Logger
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+
+	mu      sync.Mutex
+	since   time.Time
+	cluster string
+	events  []event.Event
+	dropped int
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func NewEmailSink(cfg *config.EmailSinkConfig, logger *slog.Logger) (*EmailSink, error) {
+	if cfg == nil || cfg.Host == "" || cfg.From == "" || len(cfg.To) == 0 {
+		return nil, fmt.Errorf("email sink requires email.host, email.from and email.to")
+	}
+	s := &EmailSink{cfg: *cfg, logger: logger.With("sink", "email"), since: time.Now()}
+	if s.cfg.Port == 0 {
+		s.cfg.Port = defaultSMTPPort
+	}
+	if s.cfg.Interval <= 0 {
+		s.cfg.Interval = defaultDigestInterval
+	}
+	if s.cfg.MaxEvents <= 0 {
+		s.cfg.MaxEvents = defaultDigestMaxEvents
+	}
+	var err error
+	if s.subject, err = texttemplate.New("subject").Parse(valueOr(s.cfg.Subject, defaultDigestSubject)); err != nil {
+		return nil, fmt.Errorf("invalid email.subject: %w", err)
+	}
+	if s.text, err = texttemplate.New("text").Parse(valueOr(s.cfg.TextTemplate, defaultDigestText)); err != nil {
+		return nil, fmt.Errorf("invalid email.textTemplate: %w", err)
+	}
+	if s.html, err = htmltemplate.New("html").Parse(valueOr(s.cfg.HTMLTemplate, defaultDigestHTML)); err != nil {
+		return nil, fmt.Errorf("invalid email.htmlTemplate: %w", err)
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.sendLoop()
+	return s, nil
+}
+
+func (s *EmailSink) Send(_ context.Context, ev event.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.events) >= s.cfg.MaxEvents {
+		s.dropped++
+		return nil
+	}
+	s.cluster = ev.Cluster
+	s.events = append(s.events, ev)
+	return nil
+}
+
+func (s *EmailSink) sendLoop() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Flush(context.Background()); err != nil {
+				s.logger.Error("Failed to send digest", "error", err)
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Flush mails the collected events.
+func (s *EmailSink) Flush(_ context.Context) error {
+	s.mu.Lock()
+	d := digest{Cluster: s.cluster, Since: s.since, Until: time.Now(), Events: s.events, Dropped: s.dropped}
+	s.since, s.events, s.dropped = d.Until, nil, 0
+	s.mu.Unlock()
+	if len(d.Events) == 0 {
+		return nil
+	}
+	msg, err := s.message(d)
+	if err != nil {
+		return err
+	}
+	var auth smtp.Auth
+	if s.cfg.Username != "" {
+		auth = smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)
+	}
+	// SendMail upgrades the connection with STARTTLS when the server offers it.
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	return smtp.SendMail(addr, auth, s.cfg.From, s.cfg.To, msg)
+}
+
+// message renders d as a multipart/alternative mail with a text and an HTML part.
+func (s *EmailSink) message(d digest) ([]byte, error) {
+	var subject, text, html bytes.Buffer
+	if err := s.subject.Execute(&subject, d); err != nil {
+		return nil, fmt.Errorf("failed to render subject: %w", err)
+	}
+	if err := s.text.Execute(&text, d); err != nil {
+		return nil, fmt.Errorf("failed to render text: %w", err)
+	}
+	if err := s.html.Execute(&html, d); err != nil {
+		return nil, fmt.Errorf("failed to render html: %w", err)
+	}
+
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{{"text/plain", text.Bytes()}, {"text/html", html.Bytes()}} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write(part.content); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", s.cfg.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.cfg.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject.String())))
+	fmt.Fprintf(&msg, "Date: %s\r\n", d.Until.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&msg, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", parts.Boundary())
+	msg.Write(body.Bytes())
+	return msg.Bytes(), nil
+}
+
+func (s *EmailSink) Close() error {
+	close(s.stop)
+	<-s.done
+	return nil
+}
+
+func valueOr(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
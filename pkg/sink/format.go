@@ -0,0 +1,262 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+// encoder serializes events for the message bus sinks.
+type encoder interface {
+	Encode(ctx context.Context, ev event.Event) ([]byte, error)
+	ContentType() string
+}
+
+// newEncoder returns the encoder of the configured format, JSON by default.
+func newEncoder(cfg config.FormatConfig) (encoder, error) {
+	switch cfg.Type {
+	case "", config.FormatJSON:
+		if cfg.SchemaRegistry.URL != "" {
+			return nil, fmt.Errorf("format.schemaRegistry requires format type avro")
+		}
+		return jsonEncoder{}, nil
+	case config.FormatProtobuf:
+		if cfg.SchemaRegistry.URL != "" {
+			return nil, fmt.Errorf("format.schemaRegistry requires format type avro")
+		}
+		return protobufEncoder{}, nil
+	case config.FormatAvro:
+		return newAvroEncoder(cfg.SchemaRegistry)
+	}
+	return nil, fmt.Errorf("unknown format type %q, expected json, protobuf or avro", cfg.Type)
+}
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) Encode(_ context.Context, ev event.Event) ([]byte, error) {
+	return json.Marshal(ev)
+}
+
+func (jsonEncoder) ContentType() string {
+	return "application/json"
+}
+
+// protobufEncoder writes the Event message of pkg/event/event.proto.
+type protobufEncoder struct{}
+
+func (protobufEncoder) ContentType() string {
+	return "application/x-protobuf"
+}
+
+func (protobufEncoder) Encode(_ context.Context, ev event.Event) ([]byte, error) {
+	var b []byte
+	b = appendProtoString(b, 1, ev.SchemaVersion)
+	b = appendProtoString(b, 2, ev.Cluster)
+	b = appendProtoMessage(b, 3, func(b []byte) []byte {
+		b = appendProtoString(b, 1, ev.GVR.Group)
+		b = appendProtoString(b, 2, ev.GVR.Version)
+		return appendProtoString(b, 3, ev.GVR.Resource)
+	})
+	b = appendProtoString(b, 4, ev.Namespace)
+	b = appendProtoString(b, 5, ev.Name)
+	b = appendProtoString(b, 6, ev.UID)
+	b = appendProtoString(b, 7, ev.Type)
+	var err error
+	if b, err = appendProtoTime(b, 8, ev.Timestamp); err != nil {
+		return nil, err
+	}
+	for i, obj := range []map[string]interface{}{ev.Object, ev.OldObject} {
+		if obj == nil {
+			continue
+		}
+		s, err := structpb.NewStruct(obj)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode object: %w", err)
+		}
+		if b, err = appendProto(b, protowire.Number(9+i), s); err != nil {
+			return nil, err
+		}
+	}
+	for _, change := range ev.Diff {
+		var message []byte
+		message = appendProtoString(message, 1, change.Path)
+		for i, value := range []interface{}{change.Old, change.New} {
+			if value == nil {
+				continue
+			}
+			v, err := structpb.NewValue(value)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode diff of %s: %w", change.Path, err)
+			}
+			if message, err = appendProto(message, protowire.Number(2+i), v); err != nil {
+				return nil, err
+			}
+		}
+		b = protowire.AppendTag(b, 11, protowire.BytesType)
+		b = protowire.AppendBytes(b, message)
+	}
+	b = appendProtoString(b, 12, ev.TextDiff)
+	if owner := ev.Owner; owner != nil {
+		b = appendProtoMessage(b, 13, func(b []byte) []byte {
+			b = appendProtoString(b, 1, owner.APIVersion)
+			b = appendProtoString(b, 2, owner.Kind)
+			b = appendProtoString(b, 3, owner.Name)
+			return appendProtoString(b, 4, owner.UID)
+		})
+	}
+	if changedBy := ev.ChangedBy; changedBy != nil {
+		var message []byte
+		message = appendProtoString(message, 1, changedBy.Manager)
+		message = appendProtoString(message, 2, changedBy.Operation)
+		message = appendProtoString(message, 3, changedBy.Subresource)
+		if message, err = appendProtoTime(message, 4, changedBy.Time); err != nil {
+			return nil, err
+		}
+		b = protowire.AppendTag(b, 14, protowire.BytesType)
+		b = protowire.AppendBytes(b, message)
+	}
+	if ref := ev.InvolvedObject; ref != nil {
+		b = appendProtoMessage(b, 15, func(b []byte) []byte {
+			b = appendProtoString(b, 1, ref.APIVersion)
+			b = appendProtoString(b, 2, ref.Kind)
+			b = appendProtoString(b, 3, ref.Namespace)
+			b = appendProtoString(b, 4, ref.Name)
+			return appendProtoString(b, 5, ref.UID)
+		})
+	}
+	for _, group := range ev.Groups {
+		b = appendProtoMessage(b, 16, func(b []byte) []byte {
+			b = appendProtoString(b, 1, group.Name)
+			return appendProtoString(b, 2, group.Key)
+		})
+	}
+	b = appendProtoMap(b, 17, ev.ClusterMetadata)
+	for _, alert := range ev.Alerts {
+		b = appendProtoMessage(b, 18, func(b []byte) []byte {
+			b = appendProtoString(b, 1, alert.Rule)
+			b = appendProtoString(b, 2, alert.Status)
+			b = appendProtoString(b, 3, alert.Severity)
+			b = appendProtoString(b, 4, alert.Summary)
+			return appendProtoString(b, 5, alert.DedupKey)
+		})
+	}
+	b = appendProtoMap(b, 19, ev.Annotations)
+	if ev.Truncated {
+		b = protowire.AppendTag(b, 20, protowire.VarintType)
+		b = protowire.AppendVarint(b, 1)
+	}
+	if signature := ev.Signature; signature != nil {
+		b = appendProtoMessage(b, 21, func(b []byte) []byte {
+			b = appendProtoString(b, 1, signature.Algorithm)
+			b = appendProtoString(b, 2, signature.KeyID)
+			b = appendProtoString(b, 3, signature.PrevHash)
+			b = appendProtoString(b, 4, signature.Hash)
+			return appendProtoString(b, 5, signature.Value)
+		})
+	}
+	for _, image := range ev.Images {
+		b = appendProtoMessage(b, 22, func(b []byte) []byte {
+			b = appendProtoString(b, 1, image.Container)
+			b = appendProtoString(b, 2, image.Old)
+			return appendProtoString(b, 3, image.New)
+		})
+	}
+	if scale := ev.Scale; scale != nil {
+		b = appendProtoMessage(b, 23, func(b []byte) []byte {
+			b = appendProtoInt(b, 1, scale.From)
+			return appendProtoInt(b, 2, scale.To)
+		})
+	}
+	for _, ref := range ev.Impacted {
+		b = appendProtoMessage(b, 24, func(b []byte) []byte {
+			b = appendProtoString(b, 1, ref.APIVersion)
+			b = appendProtoString(b, 2, ref.Kind)
+			b = appendProtoString(b, 3, ref.Namespace)
+			b = appendProtoString(b, 4, ref.Name)
+			return appendProtoString(b, 5, ref.UID)
+		})
+	}
+	b = appendProtoString(b, 25, ev.Tenant)
+	b = appendProtoString(b, 26, ev.PreviousUID)
+	if summary := ev.Summary; summary != nil {
+		b = appendProtoMessage(b, 27, func(b []byte) []byte {
+			b = appendProtoInt(b, 1, summary.Objects)
+			for _, namespace := range summary.Namespaces {
+				b = appendProtoString(b, 2, namespace)
+			}
+			return appendProtoString(b, 3, summary.Checksum)
+		})
+	}
+	return b, nil
+}
+
+// appendProtoString appends a string field, empty strings are the default
+// and omitted like in generated code.
+func appendProtoString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// appendProtoInt appends an int64 field, zero is omitted.
+func appendProtoInt(b []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+func appendProtoMessage(b []byte, num protowire.Number, fields func([]byte) []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, fields(nil))
+}
+
+func appendProto(b []byte, num protowire.Number, m proto.Message) ([]byte, error) {
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, data), nil
+}
+
+func appendProtoTime(b []byte, num protowire.Number, t time.Time) ([]byte, error) {
+	if t.IsZero() {
+		return b, nil
+	}
+	return appendProto(b, num, timestamppb.New(t))
+}
+
+// appendProtoMap appends a map<string, string> as repeated entry messages,
+// sorted so that equal events encode equally.
+func appendProtoMap(b []byte, num protowire.Number, m map[string]string) []byte {
+	for _, key := range sortedKeys(m) {
+		b = appendProtoMessage(b, num, func(b []byte) []byte {
+			b = appendProtoString(b, 1, key)
+			return appendProtoString(b, 2, m[key])
+		})
+	}
+	return b
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
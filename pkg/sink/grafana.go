@@ -0,0 +1,105 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+	"github.com/fl64/k8s-resource-watcher/pkg/filter"
+)
+
+// GrafanaSink creates a Grafana annotation for every selected event, tagged
+// with the resource, namespace, name and event type, so that dashboards show
+// when workloads changed.
+type GrafanaSink struct {
+	cfg          config.GrafanaSinkConfig
+	changedPaths []filter.FieldPath
+	template     *template.Template
+	client       *http.Client
+}
+
+func NewGrafanaSink(cfg *config.GrafanaSinkConfig) (*GrafanaSink, error) {
+	if cfg == nil || cfg.URL == "" {
+		return nil, fmt.Errorf("grafana sink requires grafana.url")
+	}
+	s := &GrafanaSink{cfg: *cfg, client: &http.Client{Timeout: httpTimeout(cfg.Timeout)}}
+	s.cfg.URL = strings.TrimSuffix(s.cfg.URL, "/")
+	for _, path := range cfg.ChangedPaths {
+		parsed, err := filter.ParseFieldPath(path)
+		if err != nil {
+			return nil, fmt.Errorf("invalid grafana.changedPaths: %w", err)
+		}
+		s.changedPaths = append(s.changedPaths, parsed)
+	}
+	var err error
+	if s.template, err = template.New("grafana").Parse(valueOr(cfg.Template, defaultChatTemplate)); err != nil {
+		return nil, fmt.Errorf("invalid grafana.template: %w", err)
+	}
+	return s, nil
+}
+
+func (s *GrafanaSink) Send(ctx context.Context, ev event.Event) error {
+	if !s.selects(ev) {
+		return nil
+	}
+	var text bytes.Buffer
+	if err := s.template.Execute(&text, ev); err != nil {
+		return fmt.Errorf("failed to render annotation: %w", err)
+	}
+	tags := append([]string{ev.GVR.Resource, ev.Type, objectPath(ev)}, s.cfg.Tags...)
+	if ev.Namespace != "" {
+		tags = append(tags, "namespace:"+ev.Namespace)
+	}
+	annotation := map[string]interface{}{
+		"time": ev.Timestamp.UnixMilli(),
+		"tags": tags,
+		"text": text.String(),
+	}
+	if s.cfg.DashboardUID != "" {
+		annotation["dashboardUID"] = s.cfg.DashboardUID
+	}
+	if s.cfg.PanelID != 0 {
+		annotation["panelId"] = s.cfg.PanelID
+	}
+	var headers map[string]string
+	if s.cfg.Token != "" {
+		headers = map[string]string{"Authorization": "Bearer " + s.cfg.Token}
+	}
+	return postJSON(ctx, s.client, s.cfg.URL+"/api/annotations", headers, annotation)
+}
+
+// selects reports whether ev has a selected resource and event type and, if
+// changed paths are configured, changed one of them.
+func (s *GrafanaSink) selects(ev event.Event) bool {
+	if len(s.cfg.Resources) > 0 && !slices.Contains(s.cfg.Resources, ev.GVR.Resource) {
+		return false
+	}
+	if len(s.cfg.EventTypes) > 0 && !slices.Contains(s.cfg.EventTypes, ev.Type) {
+		return false
+	}
+	if len(s.changedPaths) == 0 {
+		return true
+	}
+	for _, change := range ev.Diff {
+		changed, err := filter.ParseFieldPath(change.Path)
+		if err != nil {
+			continue
+		}
+		for _, path := range s.changedPaths {
+			if path.Covers(changed) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (s *GrafanaSink) Close() error {
+	return nil
+}
@@ -0,0 +1,175 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/alert"
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+const (
+	defaultHTTPTimeout = 5 * time.Second
+	pagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+	opsgenieURL        = "https://api.opsgenie.com"
+)
+
+// PagerDutySink triggers and resolves PagerDuty incidents for the alerts of
+// events, events without alerts are ignored.
+type PagerDutySink struct {
+	cfg    config.PagerDutySinkConfig
+	client *http.Client
+}
+
+func NewPagerDutySink(cfg *config.PagerDutySinkConfig) (*PagerDutySink, error) {
+	if cfg == nil || (cfg.RoutingKey == "" && len(cfg.RoutingKeys) == 0) {
+		return nil, fmt.Errorf("pagerduty sink requires pagerduty.routingKey")
+	}
+	s := &PagerDutySink{cfg: *cfg, client: &http.Client{Timeout: httpTimeout(cfg.Timeout)}}
+	if s.cfg.URL == "" {
+		s.cfg.URL = pagerDutyEventsURL
+	}
+	return s, nil
+}
+
+func (s *PagerDutySink) Send(ctx context.Context, ev event.Event) error {
+	for _, a := range ev.Alerts {
+		routingKey := s.cfg.RoutingKey
+		if key, ok := s.cfg.RoutingKeys[a.Severity]; ok {
+			routingKey = key
+		}
+		if routingKey == "" {
+			continue
+		}
+		severity := a.Severity
+		if mapped, ok := s.cfg.SeverityMapping[severity]; ok {
+			severity = mapped
+		}
+		payload := alert.PagerDutyEvent(routingKey, alert.PagerDutySeverity(severity), a, ev)
+		if err := postJSON(ctx, s.client, s.cfg.URL, nil, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *PagerDutySink) Close() error {
+	return nil
+}
+
+// OpsgenieSink creates and closes Opsgenie alerts for the alerts of events,
+// the dedup key is used as the Opsgenie alias.
+type OpsgenieSink struct {
+	cfg    config.OpsgenieSinkConfig
+	client *http.Client
+}
+
+func NewOpsgenieSink(cfg *config.OpsgenieSinkConfig) (*OpsgenieSink, error) {
+	if cfg == nil || cfg.APIKey == "" {
+		return nil, fmt.Errorf("opsgenie sink requires opsgenie.apiKey")
+	}
+	s := &OpsgenieSink{cfg: *cfg, client: &http.Client{Timeout: httpTimeout(cfg.Timeout)}}
+	if s.cfg.URL == "" {
+		s.cfg.URL = opsgenieURL
+	}
+	s.cfg.URL = strings.TrimSuffix(s.cfg.URL, "/")
+	return s, nil
+}
+
+func (s *OpsgenieSink) Send(ctx context.Context, ev event.Event) error {
+	headers := map[string]string{"Authorization": "GenieKey " + s.cfg.APIKey}
+	for _, a := range ev.Alerts {
+		if a.Status == event.AlertResolved {
+			endpoint := fmt.Sprintf("%s/v2/alerts/%s/close?identifierType=alias", s.cfg.URL, url.PathEscape(a.DedupKey))
+			if err := postJSON(ctx, s.client, endpoint, headers, map[string]string{"source": alert.Source(ev)}); err != nil {
+				return err
+			}
+			continue
+		}
+		responders := make([]map[string]string, len(s.cfg.Responders))
+		for i, team := range s.cfg.Responders {
+			responders[i] = map[string]string{"type": "team", "name": team}
+		}
+		payload := map[string]interface{}{
+			"message":     a.Summary,
+			"alias":       a.DedupKey,
+			"description": fmt.Sprintf("%s %s %s", ev.Type, ev.GVR.Resource, alert.Source(ev)),
+			"priority":    s.priority(a.Severity),
+			"source":      alert.Source(ev),
+			"tags":        s.cfg.Tags,
+			"responders":  responders,
+			"details": map[string]string{
+				"rule":      a.Rule,
+				"severity":  a.Severity,
+				"eventType": ev.Type,
+				"namespace": ev.Namespace,
+				"name":      ev.Name,
+			},
+		}
+		if err := postJSON(ctx, s.client, s.cfg.URL+"/v2/alerts", headers, payload); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *OpsgenieSink) priority(severity string) string {
+	if priority, ok := s.cfg.PriorityMapping[severity]; ok {
+		return priority
+	}
+	switch severity {
+	case "critical":
+		return "P1"
+	case "error":
+		return "P2"
+	case "warning":
+		return "P3"
+	case "info":
+		return "P5"
+	}
+	return "P3"
+}
+
+func (s *OpsgenieSink) Close() error {
+	return nil
+}
+
+func httpTimeout(timeout time.Duration) time.Duration {
+	if timeout <= 0 {
+		return defaultHTTPTimeout
+	}
+	return timeout
+}
+
+func postJSON(ctx context.Context, client *http.Client, endpoint string, headers map[string]string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s: %s", endpoint, resp.Status, bytes.TrimSpace(respBody))
+	}
+	return nil
+}
@@ -0,0 +1,38 @@
+package sink
+
+import (
+	"strings"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+// expandKey replaces the placeholders {cluster}, {group}, {version},
+// {resource}, {gvr} (group/version/resource), {namespace}, {name} and
+// {eventType} of template with the details of ev, e.g. to build routing keys
+// or topics. The core group is
+// written as "core" and cluster-scoped objects have the namespace "_", so
+// that keys keep the same number of segments.
+func expandKey(template string, ev event.Event) string {
+	group := ev.GVR.Group
+	if group == "" {
+		group = "core"
+	}
+	namespace := ev.Namespace
+	if namespace == "" {
+		namespace = "_"
+	}
+	cluster := ev.Cluster
+	if cluster == "" {
+		cluster = "_"
+	}
+	return strings.NewReplacer(
+		"{cluster}", cluster,
+		"{group}", group,
+		"{version}", ev.GVR.Version,
+		"{resource}", ev.GVR.Resource,
+		"{gvr}", group+"/"+ev.GVR.Version+"/"+ev.GVR.Resource,
+		"{namespace}", namespace,
+		"{name}", ev.Name,
+		"{eventType}", ev.Type,
+	).Replace(template)
+}
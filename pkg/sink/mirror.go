@@ -0,0 +1,183 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+	"gopkg.in/yaml.v3"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+const defaultCommitInterval = time.Minute
+
+// MirrorSink maintains <directory>/<group>/<resource>/<namespace>/<name>.yaml for
+// every watched object and optionally commits the tree to git. Core resources
+// use "core" as group and cluster-scoped objects "_cluster" as namespace.
+type MirrorSink struct {
+	cfg    config.MirrorSinkConfig
+	logger *slog.Logger
+
+	mu    sync.Mutex
+	dirty bool
+	stop  chan struct{}
+	done  chan struct{}
+}
+
+func NewMirrorSink(cfg *config.MirrorSinkConfig, logger *slog.Logger) (*MirrorSink, error) {
+	if cfg == nil || cfg.Directory == "" {
+		return nil, fmt.Errorf("mirror sink requires mirror.directory")
+	}
+	if err := os.MkdirAll(cfg.Directory, 0o755); err != nil {
+		return nil, err
+	}
+	s := &MirrorSink{cfg: *cfg, logger: logger.With("sink", "mirror")}
+	if !cfg.Git.Enabled {
+		return s, nil
+	}
+	if _, err := os.Stat(filepath.Join(cfg.Directory, ".git")); os.IsNotExist(err) {
+		if err := s.git(context.Background(), "init"); err != nil {
+			return nil, err
+		}
+	}
+	interval := cfg.Git.CommitInterval
+	if interval <= 0 {
+		interval = defaultCommitInterval
+	}
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.commitLoop(interval)
+	return s, nil
+}
+
+func (s *MirrorSink) Send(_ context.Context, ev event.Event) error {
+	path := s.path(ev)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch ev.Type {
+	case "Add", "Update", event.TypeRecreated, event.TypeImageChanged, event.TypeScaled,
+		event.TypeNodeReady, event.TypeNodeNotReady, event.TypeNodeCordoned, event.TypeNodeUncordoned,
+		event.TypeNodeTaintsChanged, event.TypeNodeKubeletChanged:
+		if err := writeManifest(path, ev.Object); err != nil {
+			return err
+		}
+	case "Delete":
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	default:
+		return nil
+	}
+	s.dirty = true
+	return nil
+}
+
+func (s *MirrorSink) path(ev event.Event) string {
+	group := ev.GVR.Group
+	if group == "" {
+		group = "core"
+	}
+	namespace := ev.Namespace
+	if namespace == "" {
+		namespace = "_cluster"
+	}
+	return filepath.Join(s.cfg.Directory, group, ev.GVR.Resource, namespace, ev.Name+".yaml")
+}
+
+// writeManifest replaces the file atomically so readers never see partial manifests.
+func writeManifest(path string, obj map[string]interface{}) error {
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (s *MirrorSink) commitLoop(interval time.Duration) {
+	defer close(s.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.Flush(context.Background()); err != nil {
+				s.logger.Error("Failed to commit mirror", "error", err)
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Flush commits pending changes when git is enabled.
+func (s *MirrorSink) Flush(ctx context.Context) error {
+	if !s.cfg.Git.Enabled {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.dirty {
+		return nil
+	}
+	if err := s.git(ctx, "add", "-A"); err != nil {
+		return err
+	}
+	s.dirty = false
+	// Changes may have cancelled each other out, e.g. an object added and deleted again.
+	status, err := s.gitOutput(ctx, "status", "--porcelain")
+	if err != nil || len(status) == 0 {
+		return err
+	}
+	message := fmt.Sprintf("Mirror update %s", time.Now().UTC().Format(time.RFC3339))
+	if err := s.git(ctx, "commit", "-q", "-m", message); err != nil {
+		return err
+	}
+	if s.cfg.Git.Push {
+		return s.git(ctx, "push", "-q")
+	}
+	return nil
+}
+
+func (s *MirrorSink) git(ctx context.Context, args ...string) error {
+	_, err := s.gitOutput(ctx, args...)
+	return err
+}
+
+func (s *MirrorSink) gitOutput(ctx context.Context, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = s.cfg.Directory
+	cmd.Env = os.Environ()
+	if s.cfg.Git.AuthorName != "" {
+		cmd.Env = append(cmd.Env, "GIT_AUTHOR_NAME="+s.cfg.Git.AuthorName, "GIT_COMMITTER_NAME="+s.cfg.Git.AuthorName)
+	}
+	if s.cfg.Git.AuthorEmail != "" {
+		cmd.Env = append(cmd.Env, "GIT_AUTHOR_EMAIL="+s.cfg.Git.AuthorEmail, "GIT_COMMITTER_EMAIL="+s.cfg.Git.AuthorEmail)
+	}
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", args[0], err, output)
+	}
+	return output, nil
+}
+
+func (s *MirrorSink) Close() error {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+	}
+	return nil
+}
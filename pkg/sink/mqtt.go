@@ -0,0 +1,87 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+	"golang.org/x/exp/slog"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+const (
+	defaultMQTTTopic = "k8s/{cluster}/{gvr}/{namespace}/{name}"
+	defaultMQTTQoS   = 1
+)
+
+// MQTTSink publishes every event as a message to an MQTT broker. The
+// client reconnects on its own, messages are queued while it is connecting.
+type MQTTSink struct {
+	cfg     config.MQTTSinkConfig
+	qos     byte
+	client  mqtt.Client
+	encoder encoder
+}
+
+func NewMQTTSink(cfg *config.MQTTSinkConfig, format config.FormatConfig, logger *slog.Logger) (*MQTTSink, error) {
+	if cfg == nil || cfg.Broker == "" {
+		return nil, fmt.Errorf("mqtt sink requires mqtt.broker")
+	}
+	qos := defaultMQTTQoS
+	if cfg.QoS != nil {
+		qos = *cfg.QoS
+	}
+	if qos < 0 || qos > 2 {
+		return nil, fmt.Errorf("mqtt.qos must be 0, 1 or 2")
+	}
+	encoder, err := newEncoder(format)
+	if err != nil {
+		return nil, err
+	}
+	s := &MQTTSink{cfg: *cfg, qos: byte(qos), encoder: encoder}
+	if s.cfg.Topic == "" {
+		s.cfg.Topic = defaultMQTTTopic
+	}
+	clientID := s.cfg.ClientID
+	if clientID == "" {
+		hostname, _ := os.Hostname()
+		clientID = "k8s-resource-watcher-" + hostname
+	}
+	opts := mqtt.NewClientOptions().
+		AddBroker(s.cfg.Broker).
+		SetClientID(clientID).
+		SetUsername(s.cfg.Username).
+		SetPassword(s.cfg.Password).
+		SetConnectTimeout(httpTimeout(s.cfg.Timeout)).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			logger.Warn("MQTT connection lost", "broker", s.cfg.Broker, "error", err)
+		})
+	s.client = mqtt.NewClient(opts)
+	s.client.Connect()
+	return s, nil
+}
+
+func (s *MQTTSink) Send(ctx context.Context, ev event.Event) error {
+	data, err := s.encoder.Encode(ctx, ev)
+	if err != nil {
+		return err
+	}
+	token := s.client.Publish(expandKey(s.cfg.Topic, ev), s.qos, s.cfg.Retain, data)
+	select {
+	case <-token.Done():
+		return token.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *MQTTSink) Close() error {
+	// Give in-flight messages a moment to be acknowledged.
+	s.client.Disconnect(250)
+	return nil
+}
@@ -0,0 +1,172 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+const (
+	defaultOTLPGRPCEndpoint = "localhost:4317"
+	defaultOTLPHTTPEndpoint = "http://localhost:4318/v1/logs"
+	defaultOTLPServiceName  = "k8s-resource-watcher"
+)
+
+// OTLPSink exports every event as an OpenTelemetry log record over OTLP/gRPC
+// or OTLP/HTTP with protobuf encoding. The cluster and GVR are resource
+// attributes, the object and event type attributes of the record and the
+// JSON event its body.
+type OTLPSink struct {
+	cfg    config.OTLPSinkConfig
+	conn   *grpc.ClientConn
+	grpc   collogspb.LogsServiceClient
+	client *http.Client
+}
+
+func NewOTLPSink(cfg *config.OTLPSinkConfig) (*OTLPSink, error) {
+	if cfg == nil {
+		return nil, fmt.Errorf("otlp sink requires an otlp section")
+	}
+	s := &OTLPSink{cfg: *cfg}
+	if s.cfg.ServiceName == "" {
+		s.cfg.ServiceName = defaultOTLPServiceName
+	}
+	switch s.cfg.Protocol {
+	case "", config.OTLPProtocolGRPC:
+		if s.cfg.Endpoint == "" {
+			s.cfg.Endpoint = defaultOTLPGRPCEndpoint
+		}
+		creds := credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12})
+		if s.cfg.Insecure {
+			creds = insecure.NewCredentials()
+		}
+		// The connection is established in the background and on demand.
+		conn, err := grpc.Dial(s.cfg.Endpoint, grpc.WithTransportCredentials(creds))
+		if err != nil {
+			return nil, fmt.Errorf("invalid otlp.endpoint: %w", err)
+		}
+		s.conn, s.grpc = conn, collogspb.NewLogsServiceClient(conn)
+	case config.OTLPProtocolHTTP:
+		if s.cfg.Endpoint == "" {
+			s.cfg.Endpoint = defaultOTLPHTTPEndpoint
+		}
+		s.client = &http.Client{Timeout: httpTimeout(s.cfg.Timeout)}
+	default:
+		return nil, fmt.Errorf("unknown otlp.protocol %q, expected grpc or http", s.cfg.Protocol)
+	}
+	return s, nil
+}
+
+func (s *OTLPSink) Send(ctx context.Context, ev event.Event) error {
+	req, err := s.request(ev)
+	if err != nil {
+		return err
+	}
+	if s.grpc != nil {
+		ctx, cancel := context.WithTimeout(ctx, httpTimeout(s.cfg.Timeout))
+		defer cancel()
+		if len(s.cfg.Headers) > 0 {
+			ctx = metadata.NewOutgoingContext(ctx, metadata.New(s.cfg.Headers))
+		}
+		_, err := s.grpc.Export(ctx, req)
+		return err
+	}
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	for key, value := range s.cfg.Headers {
+		httpReq.Header.Set(key, value)
+	}
+	resp, err := s.client.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s: %s", s.cfg.Endpoint, resp.Status, bytes.TrimSpace(respBody))
+	}
+	return nil
+}
+
+func (s *OTLPSink) request(ev event.Event) (*collogspb.ExportLogsServiceRequest, error) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return nil, err
+	}
+	resource := []*commonpb.KeyValue{
+		otlpString("service.name", s.cfg.ServiceName),
+		otlpString("k8s.resource.group", ev.GVR.Group),
+		otlpString("k8s.resource.version", ev.GVR.Version),
+		otlpString("k8s.resource.name", ev.GVR.Resource),
+	}
+	if ev.Cluster != "" {
+		resource = append(resource, otlpString("k8s.cluster.name", ev.Cluster))
+	}
+	attributes := []*commonpb.KeyValue{
+		otlpString("event.name", ev.Type),
+		otlpString("k8s.object.name", ev.Name),
+	}
+	if ev.Namespace != "" {
+		attributes = append(attributes, otlpString("k8s.namespace.name", ev.Namespace))
+	}
+	if ev.UID != "" {
+		attributes = append(attributes, otlpString("k8s.object.uid", ev.UID))
+	}
+	severity, severityText := logspb.SeverityNumber_SEVERITY_NUMBER_INFO, "INFO"
+	if ev.Type == event.TypeFlapping || len(ev.Alerts) > 0 {
+		severity, severityText = logspb.SeverityNumber_SEVERITY_NUMBER_WARN, "WARN"
+	}
+	record := &logspb.LogRecord{
+		TimeUnixNano:         uint64(ev.Timestamp.UnixNano()),
+		ObservedTimeUnixNano: uint64(time.Now().UnixNano()),
+		SeverityNumber:       severity,
+		SeverityText:         severityText,
+		Body:                 &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: string(data)}},
+		Attributes:           attributes,
+	}
+	return &collogspb.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{{
+			Resource: &resourcepb.Resource{Attributes: resource},
+			ScopeLogs: []*logspb.ScopeLogs{{
+				Scope:      &commonpb.InstrumentationScope{Name: "github.com/fl64/k8s-resource-watcher"},
+				LogRecords: []*logspb.LogRecord{record},
+			}},
+		}},
+	}, nil
+}
+
+func otlpString(key, value string) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: key, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: value}}}
+}
+
+func (s *OTLPSink) Close() error {
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
@@ -0,0 +1,362 @@
+package sink
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+	"github.com/fl64/k8s-resource-watcher/pkg/metrics"
+)
+
+const (
+	outboxEventsFile        = "events.jsonl"
+	outboxOffsetFile        = "offset"
+	defaultRetryInterval    = time.Second
+	defaultMaxRetryInterval = time.Minute
+	// outboxCompactSize is the size of delivered events after which the
+	// outbox file is rewritten without them.
+	outboxCompactSize = 1 << 20
+	// maxOutboxLineSize bounds a single event like the event store does.
+	maxOutboxLineSize = 16 << 20
+)
+
+// OutboxSink gives a sink at-least-once delivery. Send appends events to a
+// file and returns, they are delivered in order by a background loop that
+// retries failed sends. The offset of the last delivered event is kept next
+// to the file, events after it are delivered again after a restart. Sinks
+// that batch internally count an event as delivered once it is batched.
+type OutboxSink struct {
+	name   string
+	sink   Sink
+	cfg    config.DeliveryConfig
+	logger *slog.Logger
+
+	mu sync.Mutex
+	// file holds the events, the ones before offset are delivered.
+	file    *os.File
+	size    int64
+	offset  int64
+	pending int
+	// drained is closed when pending drops to zero.
+	drained chan struct{}
+	notify  chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+func NewOutboxSink(name string, cfg config.DeliveryConfig, sink Sink, logger *slog.Logger) (*OutboxSink, error) {
+	if cfg.Outbox == "" {
+		return nil, fmt.Errorf("at-least-once delivery requires delivery.outbox")
+	}
+	if cfg.RetryInterval <= 0 {
+		cfg.RetryInterval = defaultRetryInterval
+	}
+	if cfg.MaxRetryInterval < cfg.RetryInterval {
+		cfg.MaxRetryInterval = max(defaultMaxRetryInterval, cfg.RetryInterval)
+	}
+	if err := os.MkdirAll(cfg.Outbox, 0o755); err != nil {
+		return nil, err
+	}
+	s := &OutboxSink{
+		name:    name,
+		sink:    sink,
+		cfg:     cfg,
+		logger:  logger,
+		drained: make(chan struct{}),
+		notify:  make(chan struct{}, 1),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if err := s.open(); err != nil {
+		return nil, fmt.Errorf("failed to open outbox: %w", err)
+	}
+	if s.pending == 0 {
+		close(s.drained)
+	} else {
+		logger.Info("Delivering events left in the outbox", "sink", name, "pending", s.pending)
+	}
+	metrics.OutboxPending.WithLabelValues(name).Set(float64(s.pending))
+	go s.deliverLoop()
+	return s, nil
+}
+
+// open opens the outbox file and counts the events after the stored offset.
+func (s *OutboxSink) open() error {
+	file, err := os.OpenFile(filepath.Join(s.cfg.Outbox, outboxEventsFile), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	s.file, s.size = file, info.Size()
+	data, err := os.ReadFile(filepath.Join(s.cfg.Outbox, outboxOffsetFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if len(data) > 0 {
+		if s.offset, err = strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err != nil {
+			return fmt.Errorf("invalid offset: %w", err)
+		}
+	}
+	// The file was truncated after everything was delivered, but the
+	// offset was not stored anymore.
+	if s.offset > s.size {
+		s.offset = s.size
+	}
+	// A partly written last event of a crash is dropped, it was never
+	// acknowledged to the dispatcher.
+	end := s.offset
+	reader := bufio.NewReader(io.NewSectionReader(file, s.offset, s.size-s.offset))
+	for pos := s.offset; ; {
+		line, err := reader.ReadSlice('\n')
+		pos += int64(len(line))
+		if len(line) > 0 && line[len(line)-1] == '\n' {
+			s.pending++
+			end = pos
+		}
+		if err != nil && !errors.Is(err, bufio.ErrBufferFull) {
+			break
+		}
+	}
+	if end < s.size {
+		if err := file.Truncate(end); err != nil {
+			return err
+		}
+		s.size = end
+	}
+	return nil
+}
+
+// Send appends ev to the outbox.
+func (s *OutboxSink) Send(_ context.Context, ev event.Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+	// Larger events could not be read back, they are never acknowledged.
+	if len(data) > maxOutboxLineSize {
+		metrics.DroppedEventsTotal.WithLabelValues("sink", s.name, "oversize").Inc()
+		return fmt.Errorf("event of %d bytes exceeds the outbox limit of %d bytes", len(data), maxOutboxLineSize)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.cfg.MaxPending > 0 && s.pending >= s.cfg.MaxPending {
+		metrics.DroppedEventsTotal.WithLabelValues("sink", s.name, "outbox_full").Inc()
+		return fmt.Errorf("outbox is full with %d events", s.pending)
+	}
+	if _, err := s.file.WriteAt(data, s.size); err != nil {
+		return err
+	}
+	if err := s.file.Sync(); err != nil {
+		return err
+	}
+	s.size += int64(len(data))
+	if s.pending == 0 {
+		s.drained = make(chan struct{})
+	}
+	s.pending++
+	metrics.OutboxPending.WithLabelValues(s.name).Set(float64(s.pending))
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// next returns the first undelivered line and the offset after it. Lines
+// longer than maxOutboxLineSize, e.g. of older versions, are returned as nil
+// with the offset after their end.
+func (s *OutboxSink) next() ([]byte, int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.offset >= s.size {
+		return nil, 0, false
+	}
+	reader := bufio.NewReaderSize(io.NewSectionReader(s.file, s.offset, s.size-s.offset), 64<<10)
+	var line []byte
+	var length int64
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		length += int64(len(chunk))
+		if length <= maxOutboxLineSize {
+			line = append(line, chunk...)
+		} else {
+			line = nil
+		}
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			break
+		}
+	}
+	return line, s.offset + length, true
+}
+
+func (s *OutboxSink) deliverLoop() {
+	defer close(s.done)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-s.stop
+		cancel()
+	}()
+	for {
+		line, next, ok := s.next()
+		if !ok {
+			select {
+			case <-s.notify:
+				continue
+			case <-s.stop:
+				return
+			}
+		}
+		var ev event.Event
+		if line == nil {
+			s.logger.Error("Skipping oversized outbox entry", "sink", s.name, "limit", maxOutboxLineSize)
+		} else if err := json.Unmarshal(bytes.TrimSpace(line), &ev); err != nil {
+			s.logger.Error("Skipping invalid outbox entry", "sink", s.name, "error", err)
+		} else if !s.deliver(ctx, ev) {
+			return
+		}
+		if err := s.ack(next); err != nil {
+			s.logger.Error("Failed to update outbox", "sink", s.name, "error", err)
+		}
+	}
+}
+
+// deliver sends ev until the sink accepts it, it returns false when the
+// outbox was closed before.
+func (s *OutboxSink) deliver(ctx context.Context, ev event.Event) bool {
+	interval := s.cfg.RetryInterval
+	for {
+		err := s.sink.Send(ctx, ev)
+		if err == nil {
+			metrics.DeliveryLatencySeconds.WithLabelValues(s.name).Observe(time.Since(ev.Timestamp).Seconds())
+			return true
+		}
+		if ctx.Err() != nil {
+			return false
+		}
+		metrics.DeliveryRetriesTotal.WithLabelValues(s.name).Inc()
+		s.logger.Error("Failed to send event, retrying", "sink", s.name, "retryIn", interval, "error", err)
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return false
+		}
+		interval = min(interval*2, s.cfg.MaxRetryInterval)
+	}
+}
+
+// ack marks the events before offset as delivered. The file is emptied once
+// everything is delivered and compacted when the delivered part grows large.
+func (s *OutboxSink) ack(offset int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.offset = offset
+	s.pending--
+	metrics.OutboxPending.WithLabelValues(s.name).Set(float64(s.pending))
+	if s.pending == 0 {
+		close(s.drained)
+	}
+	switch {
+	case s.offset >= s.size:
+		if err := s.file.Truncate(0); err != nil {
+			return err
+		}
+		s.offset, s.size = 0, 0
+	case s.offset >= outboxCompactSize:
+		if err := s.compact(); err != nil {
+			return err
+		}
+	}
+	return s.storeOffset()
+}
+
+// compact rewrites the outbox file with the undelivered events only.
+func (s *OutboxSink) compact() error {
+	path := filepath.Join(s.cfg.Outbox, outboxEventsFile)
+	tmp, err := os.CreateTemp(s.cfg.Outbox, outboxEventsFile+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := io.Copy(tmp, io.NewSectionReader(s.file, s.offset, s.size-s.offset)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	// The offset is reset first, a crash in between redelivers events
+	// instead of skipping them.
+	size := s.size - s.offset
+	s.offset = 0
+	if err := s.storeOffset(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		tmp.Close()
+		return err
+	}
+	s.file.Close()
+	s.file, s.size = tmp, size
+	return nil
+}
+
+func (s *OutboxSink) storeOffset() error {
+	path := filepath.Join(s.cfg.Outbox, outboxOffsetFile)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strconv.FormatInt(s.offset, 10)), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Flush waits until the outbox is delivered and flushes the sink. Events
+// that are not delivered within ctx stay in the outbox.
+func (s *OutboxSink) Flush(ctx context.Context) error {
+	s.mu.Lock()
+	drained := s.drained
+	s.mu.Unlock()
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		return fmt.Errorf("outbox not delivered: %w", ctx.Err())
+	}
+	if flusher, ok := s.sink.(Flusher); ok {
+		return flusher.Flush(ctx)
+	}
+	return nil
+}
+
+// Close stops delivering and closes the sink, the outbox is kept.
+func (s *OutboxSink) Close() error {
+	close(s.stop)
+	<-s.done
+	err := s.sink.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if closeErr := s.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
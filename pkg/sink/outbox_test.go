@@ -0,0 +1,231 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+// recordingSink records the names of the events it accepts and fails the
+// events for which fail returns true.
+type recordingSink struct {
+	mu    sync.Mutex
+	names []string
+	fail  func(ev event.Event) bool
+}
+
+func (r *recordingSink) Send(_ context.Context, ev event.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.fail != nil && r.fail(ev) {
+		return errors.New("unavailable")
+	}
+	r.names = append(r.names, ev.Name)
+	return nil
+}
+
+func (r *recordingSink) Close() error { return nil }
+
+func (r *recordingSink) sent() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.names...)
+}
+
+func (r *recordingSink) setFail(fail func(ev event.Event) bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.fail = fail
+}
+
+func newTestOutbox(t *testing.T, dir string, sink Sink) *OutboxSink {
+	t.Helper()
+	cfg := config.DeliveryConfig{Outbox: dir, RetryInterval: time.Millisecond, MaxRetryInterval: 5 * time.Millisecond}
+	outbox, err := NewOutboxSink("test", cfg, sink, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatalf("NewOutboxSink: %v", err)
+	}
+	return outbox
+}
+
+func sendAll(t *testing.T, outbox *OutboxSink, names ...string) {
+	t.Helper()
+	for _, name := range names {
+		if err := outbox.Send(context.Background(), event.Event{Name: name, Timestamp: time.Now()}); err != nil {
+			t.Fatalf("Send(%s): %v", name, err)
+		}
+	}
+}
+
+func flush(t *testing.T, outbox *OutboxSink) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := outbox.Flush(ctx); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+}
+
+func TestOutboxRetries(t *testing.T) {
+	failures := 3
+	sink := &recordingSink{}
+	sink.setFail(func(ev event.Event) bool {
+		if ev.Name == "b" && failures > 0 {
+			failures--
+			return true
+		}
+		return false
+	})
+	outbox := newTestOutbox(t, t.TempDir(), sink)
+	defer outbox.Close()
+	sendAll(t, outbox, "a", "b", "c")
+	flush(t, outbox)
+	if got, want := sink.sent(), []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("delivered %v, want %v", got, want)
+	}
+}
+
+func TestOutboxRestart(t *testing.T) {
+	dir := t.TempDir()
+	sink := &recordingSink{}
+	sink.setFail(func(ev event.Event) bool { return ev.Name == "c" })
+	outbox := newTestOutbox(t, dir, sink)
+	sendAll(t, outbox, "a", "b", "c", "d")
+	waitFor(t, func() bool { return len(sink.sent()) == 2 })
+	if err := outbox.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	// A crash left a partly written event behind, it is dropped.
+	file, err := os.OpenFile(filepath.Join(dir, outboxEventsFile), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.WriteString(`{"name":"partial"`); err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+
+	restarted := &recordingSink{}
+	restarted.setFail(func(event.Event) bool { return true })
+	outbox = newTestOutbox(t, dir, restarted)
+	defer outbox.Close()
+	outbox.mu.Lock()
+	pending := outbox.pending
+	outbox.mu.Unlock()
+	if pending != 2 {
+		t.Errorf("%d pending events after the restart, want 2", pending)
+	}
+	restarted.setFail(nil)
+	sendAll(t, outbox, "e")
+	flush(t, outbox)
+	if got, want := restarted.sent(), []string{"c", "d", "e"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("delivered %v after the restart, want %v", got, want)
+	}
+	if info, err := os.Stat(filepath.Join(dir, outboxEventsFile)); err != nil || info.Size() != 0 {
+		t.Errorf("outbox not emptied after delivering everything: %v, %v", info, err)
+	}
+}
+
+func TestOutboxCompaction(t *testing.T) {
+	dir := t.TempDir()
+	sink := &recordingSink{}
+	sink.setFail(func(ev event.Event) bool { return ev.Name == "last" })
+	outbox := newTestOutbox(t, dir, sink)
+	payload := strings.Repeat("x", 64<<10)
+	var names []string
+	for i := 0; len(names)*len(payload) < 2*outboxCompactSize; i++ {
+		names = append(names, fmt.Sprintf("event-%d", i))
+	}
+	for _, name := range names {
+		if err := outbox.Send(context.Background(), event.Event{Name: name, Annotations: map[string]string{"payload": payload}}); err != nil {
+			t.Fatalf("Send: %v", err)
+		}
+	}
+	sendAll(t, outbox, "last")
+	waitFor(t, func() bool { return len(sink.sent()) == len(names) })
+	if err := outbox.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(dir, outboxEventsFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Size() >= outboxCompactSize {
+		t.Errorf("outbox of %d bytes was not compacted", info.Size())
+	}
+
+	restarted := &recordingSink{}
+	outbox = newTestOutbox(t, dir, restarted)
+	defer outbox.Close()
+	flush(t, outbox)
+	// The events delivered since the compaction may be delivered again, the
+	// earlier ones never are.
+	got := restarted.sent()
+	if len(got) == 0 || got[len(got)-1] != "last" || len(got) >= len(names) {
+		t.Errorf("delivered %v after the restart, want the events after the compaction", got)
+	}
+}
+
+func TestOutboxOversize(t *testing.T) {
+	dir := t.TempDir()
+	sink := &recordingSink{}
+	outbox := newTestOutbox(t, dir, sink)
+	big := event.Event{Name: "big", Annotations: map[string]string{"payload": strings.Repeat("x", maxOutboxLineSize)}}
+	if err := outbox.Send(context.Background(), big); err == nil {
+		t.Error("Send of an oversized event succeeded")
+	}
+	if err := outbox.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Oversized entries already in the outbox are skipped.
+	line := `{"name":"big","annotations":{"payload":"` + strings.Repeat("x", maxOutboxLineSize) + "\"}}\n"
+	if err := os.WriteFile(filepath.Join(dir, outboxEventsFile), []byte(line+`{"name":"small"}`+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	outbox = newTestOutbox(t, dir, sink)
+	defer outbox.Close()
+	flush(t, outbox)
+	if got, want := sink.sent(), []string{"small"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("delivered %v, want %v", got, want)
+	}
+}
+
+func TestOutboxMaxPending(t *testing.T) {
+	sink := &recordingSink{}
+	sink.setFail(func(event.Event) bool { return true })
+	cfg := config.DeliveryConfig{Outbox: t.TempDir(), MaxPending: 2, RetryInterval: time.Millisecond}
+	outbox, err := NewOutboxSink("test", cfg, sink, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer outbox.Close()
+	sendAll(t, outbox, "a", "b")
+	if err := outbox.Send(context.Background(), event.Event{Name: "c"}); err == nil {
+		t.Error("Send to a full outbox succeeded")
+	}
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met within 5s")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
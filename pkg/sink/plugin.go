@@ -0,0 +1,58 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/go-plugin"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+	"github.com/fl64/k8s-resource-watcher/pkg/sinkplugin"
+)
+
+// PluginSink forwards events to an out-of-tree sink binary.
+type PluginSink struct {
+	client *plugin.Client
+	sink   sinkplugin.Sink
+}
+
+func NewPluginSink(cfg *config.PluginSinkConfig) (*PluginSink, error) {
+	if cfg == nil || cfg.Command == "" {
+		return nil, fmt.Errorf("plugin sink requires plugin.command")
+	}
+	client := plugin.NewClient(&plugin.ClientConfig{
+		HandshakeConfig:  sinkplugin.Handshake,
+		Plugins:          map[string]plugin.Plugin{sinkplugin.PluginName: &sinkplugin.SinkPlugin{}},
+		Cmd:              exec.Command(cfg.Command, cfg.Args...),
+		AllowedProtocols: []plugin.Protocol{plugin.ProtocolNetRPC},
+		Logger:           hclog.New(&hclog.LoggerOptions{Name: "plugin", Level: hclog.Info}),
+	})
+	rpcClient, err := client.Client()
+	if err != nil {
+		client.Kill()
+		return nil, fmt.Errorf("failed to start plugin: %w", err)
+	}
+	raw, err := rpcClient.Dispense(sinkplugin.PluginName)
+	if err != nil {
+		client.Kill()
+		return nil, fmt.Errorf("failed to dispense plugin: %w", err)
+	}
+	sink := raw.(sinkplugin.Sink)
+	if err := sink.Configure(cfg.Settings); err != nil {
+		client.Kill()
+		return nil, fmt.Errorf("failed to configure plugin: %w", err)
+	}
+	return &PluginSink{client: client, sink: sink}, nil
+}
+
+func (s *PluginSink) Send(_ context.Context, ev event.Event) error {
+	return s.sink.Send(ev)
+}
+
+func (s *PluginSink) Close() error {
+	defer s.client.Kill()
+	return s.sink.Close()
+}
@@ -0,0 +1,160 @@
+package sink
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+const (
+	pubSubURL = "https://pubsub.googleapis.com"
+	// gceTokenURL serves tokens of the Kubernetes service account bound with
+	// workload identity, or of the node service account.
+	gceTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+// PubSubSink publishes every event as a message to Google Cloud Pub/Sub.
+type PubSubSink struct {
+	cfg     config.PubSubSinkConfig
+	client  *http.Client
+	tokens  *gceTokenSource
+	encoder encoder
+}
+
+func NewPubSubSink(cfg *config.PubSubSinkConfig, format config.FormatConfig) (*PubSubSink, error) {
+	if cfg == nil || cfg.Project == "" || cfg.Topic == "" {
+		return nil, fmt.Errorf("pubsub sink requires pubsub.project and pubsub.topic")
+	}
+	encoder, err := newEncoder(format)
+	if err != nil {
+		return nil, err
+	}
+	s := &PubSubSink{cfg: *cfg, client: &http.Client{Timeout: httpTimeout(cfg.Timeout)}, encoder: encoder}
+	if s.cfg.Endpoint == "" {
+		s.cfg.Endpoint = pubSubURL
+	}
+	s.cfg.Endpoint = strings.TrimSuffix(s.cfg.Endpoint, "/")
+	if s.cfg.TokenURL == "" {
+		s.cfg.TokenURL = gceTokenURL
+	}
+	if strings.HasPrefix(s.cfg.Endpoint, "https://") {
+		s.tokens = &gceTokenSource{url: s.cfg.TokenURL, client: s.client}
+	}
+	return s, nil
+}
+
+func (s *PubSubSink) Send(ctx context.Context, ev event.Event) error {
+	data, err := s.encoder.Encode(ctx, ev)
+	if err != nil {
+		return err
+	}
+	message := map[string]interface{}{
+		"data":       base64.StdEncoding.EncodeToString(data),
+		"attributes": messageAttributes(ev),
+	}
+	if s.cfg.OrderingKey {
+		message["orderingKey"] = objectPath(ev)
+	}
+	var headers map[string]string
+	if s.tokens != nil {
+		token, err := s.tokens.Token(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to get access token: %w", err)
+		}
+		headers = map[string]string{"Authorization": "Bearer " + token}
+	}
+	endpoint := fmt.Sprintf("%s/v1/projects/%s/topics/%s:publish", s.cfg.Endpoint, url.PathEscape(s.cfg.Project), url.PathEscape(s.topic(ev)))
+	return postJSON(ctx, s.client, endpoint, headers, map[string]interface{}{"messages": []interface{}{message}})
+}
+
+func (s *PubSubSink) topic(ev event.Event) string {
+	if !s.cfg.TopicPerResource {
+		return s.cfg.Topic
+	}
+	if ev.GVR.Group == "" {
+		return s.cfg.Topic + "-" + ev.GVR.Resource
+	}
+	return s.cfg.Topic + "-" + ev.GVR.Resource + "." + ev.GVR.Group
+}
+
+func (s *PubSubSink) Close() error {
+	return nil
+}
+
+// messageAttributes returns the metadata of ev that message buses can route
+// and filter on without decoding the payload.
+func messageAttributes(ev event.Event) map[string]string {
+	attributes := map[string]string{
+		"group":     ev.GVR.Group,
+		"version":   ev.GVR.Version,
+		"resource":  ev.GVR.Resource,
+		"name":      ev.Name,
+		"eventType": ev.Type,
+	}
+	if ev.Namespace != "" {
+		attributes["namespace"] = ev.Namespace
+	}
+	if ev.Cluster != "" {
+		attributes["cluster"] = ev.Cluster
+	}
+	return attributes
+}
+
+// objectPath is namespace/name, or the name of cluster-scoped objects.
+func objectPath(ev event.Event) string {
+	if ev.Namespace == "" {
+		return ev.Name
+	}
+	return ev.Namespace + "/" + ev.Name
+}
+
+// gceTokenSource caches access tokens of the GCE metadata server until
+// shortly before they expire.
+type gceTokenSource struct {
+	url    string
+	client *http.Client
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func (t *gceTokenSource) Token(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token != "" && time.Now().Before(t.expires) {
+		return t.token, nil
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s returned %s", t.url, resp.Status)
+	}
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	t.token = token.AccessToken
+	t.expires = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+	return t.token, nil
+}
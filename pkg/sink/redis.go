@@ -0,0 +1,73 @@
+package sink
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+const defaultRedisStream = "k8s-events"
+
+// RedisSink adds every event to a Redis stream. Entries hold the encoded
+// event in the event field next to the message attributes, so consumers can filter
+// without decoding it.
+type RedisSink struct {
+	cfg     config.RedisSinkConfig
+	client  *redis.Client
+	encoder encoder
+}
+
+func NewRedisSink(cfg *config.RedisSinkConfig, format config.FormatConfig) (*RedisSink, error) {
+	if cfg == nil || cfg.Address == "" {
+		return nil, fmt.Errorf("redis sink requires redis.address")
+	}
+	encoder, err := newEncoder(format)
+	if err != nil {
+		return nil, err
+	}
+	s := &RedisSink{cfg: *cfg, encoder: encoder}
+	if s.cfg.Stream == "" {
+		s.cfg.Stream = defaultRedisStream
+	}
+	opts := &redis.Options{
+		Addr:         s.cfg.Address,
+		Username:     s.cfg.Username,
+		Password:     s.cfg.Password,
+		DB:           s.cfg.DB,
+		DialTimeout:  httpTimeout(s.cfg.Timeout),
+		ReadTimeout:  httpTimeout(s.cfg.Timeout),
+		WriteTimeout: httpTimeout(s.cfg.Timeout),
+	}
+	if s.cfg.TLS {
+		opts.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+	s.client = redis.NewClient(opts)
+	return s, nil
+}
+
+func (s *RedisSink) Send(ctx context.Context, ev event.Event) error {
+	data, err := s.encoder.Encode(ctx, ev)
+	if err != nil {
+		return err
+	}
+	values := []interface{}{"event", string(data)}
+	for key, value := range messageAttributes(ev) {
+		values = append(values, key, value)
+	}
+	return s.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: expandKey(s.cfg.Stream, ev),
+		MaxLen: s.cfg.MaxLen,
+		// Exact trimming is expensive, the stream may exceed maxLen slightly.
+		Approx: true,
+		Values: values,
+	}).Err()
+}
+
+func (s *RedisSink) Close() error {
+	return s.client.Close()
+}
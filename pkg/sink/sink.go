@@ -0,0 +1,296 @@
+// Package sink delivers events to their destinations.
+package sink
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+	"github.com/fl64/k8s-resource-watcher/pkg/logging"
+	"github.com/fl64/k8s-resource-watcher/pkg/metrics"
+	"github.com/fl64/k8s-resource-watcher/pkg/ratelimit"
+)
+
+// Sink is a destination for events.
+type Sink interface {
+	Send(ctx context.Context, ev event.Event) error
+	Close() error
+}
+
+// Flusher is implemented by sinks that buffer events internally.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// New creates the sink described by cfg.
+func New(cfg config.SinkConfig, logger *slog.Logger) (Sink, error) {
+	switch cfg.Type {
+	case "", "log":
+		return &LogSink{logger: logger, omitObjects: cfg.Log.OmitObjects}, nil
+	case "plugin":
+		return NewPluginSink(cfg.Plugin)
+	case "webhook":
+		return NewWebhookSink(cfg.Webhook, logger)
+	case "mirror":
+		return NewMirrorSink(cfg.Mirror, logger)
+	case "pagerduty":
+		return NewPagerDutySink(cfg.PagerDuty)
+	case "opsgenie":
+		return NewOpsgenieSink(cfg.Opsgenie)
+	case "pubsub":
+		return NewPubSubSink(cfg.PubSub, cfg.Format)
+	case "sqs":
+		return NewSQSSink(cfg.SQS)
+	case "sns":
+		return NewSNSSink(cfg.SNS)
+	case "amqp":
+		return NewAMQPSink(cfg.AMQP, cfg.Format, logger)
+	case "mqtt":
+		return NewMQTTSink(cfg.MQTT, cfg.Format, logger)
+	case "redis":
+		return NewRedisSink(cfg.Redis, cfg.Format)
+	case "syslog":
+		return NewSyslogSink(cfg.Syslog)
+	case "email":
+		return NewEmailSink(cfg.Email, logger)
+	case chatTeams:
+		return NewChatSink(cfg.Type, cfg.Teams)
+	case chatDiscord:
+		return NewChatSink(cfg.Type, cfg.Discord)
+	case chatTelegram:
+		return NewChatSink(cfg.Type, cfg.Telegram)
+	case "grafana":
+		return NewGrafanaSink(cfg.Grafana)
+	case "otlp":
+		return NewOTLPSink(cfg.OTLP)
+	}
+	return nil, fmt.Errorf("unknown sink type %q", cfg.Type)
+}
+
+// Validate checks that cfg names a known sink type with its settings, without
+// creating the sink.
+func Validate(cfg config.SinkConfig) error {
+	if cfg.Log.Level != "" {
+		if _, err := logging.ParseLevel(cfg.Log.Level); err != nil {
+			return fmt.Errorf("invalid log level: %w", err)
+		}
+	}
+	if cfg.Format != (config.FormatConfig{}) {
+		switch cfg.Type {
+		case "pubsub", "amqp", "mqtt", "redis":
+			if _, err := newEncoder(cfg.Format); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("format is not supported by sink type %q", cfg.Type)
+		}
+	}
+	switch cfg.Delivery.Guarantee {
+	case "", config.DeliveryAtMostOnce:
+	case config.DeliveryAtLeastOnce:
+		if cfg.Delivery.Outbox == "" {
+			return fmt.Errorf("at-least-once delivery requires delivery.outbox")
+		}
+	default:
+		return fmt.Errorf("unknown delivery guarantee %q", cfg.Delivery.Guarantee)
+	}
+	var settings bool
+	switch cfg.Type {
+	case "", "log":
+		return nil
+	case "plugin":
+		settings = cfg.Plugin != nil
+	case "webhook":
+		settings = cfg.Webhook != nil
+		if settings {
+			if err := validateCompression(cfg.Webhook.Compression); err != nil {
+				return fmt.Errorf("invalid webhook.compression: %w", err)
+			}
+		}
+	case "mirror":
+		settings = cfg.Mirror != nil
+	case "pagerduty":
+		settings = cfg.PagerDuty != nil
+	case "opsgenie":
+		settings = cfg.Opsgenie != nil
+	case "pubsub":
+		settings = cfg.PubSub != nil
+	case "sqs":
+		settings = cfg.SQS != nil
+	case "sns":
+		settings = cfg.SNS != nil
+	case "amqp":
+		settings = cfg.AMQP != nil
+	case "mqtt":
+		settings = cfg.MQTT != nil
+	case "redis":
+		settings = cfg.Redis != nil
+	case "syslog":
+		settings = cfg.Syslog != nil
+	case "email":
+		settings = cfg.Email != nil
+	case chatTeams:
+		settings = cfg.Teams != nil
+	case chatDiscord:
+		settings = cfg.Discord != nil
+	case chatTelegram:
+		settings = cfg.Telegram != nil
+	case "grafana":
+		settings = cfg.Grafana != nil
+	case "otlp":
+		settings = cfg.OTLP != nil
+	default:
+		return fmt.Errorf("unknown sink type %q", cfg.Type)
+	}
+	if !settings {
+		return fmt.Errorf("sink type %q requires a %s section", cfg.Type, cfg.Type)
+	}
+	return nil
+}
+
+// LogSink writes events to the application log.
+type LogSink struct {
+	logger *slog.Logger
+	// omitObjects logs metadata and diffs only.
+	omitObjects bool
+}
+
+func (s *LogSink) Send(_ context.Context, ev event.Event) error {
+	if s.omitObjects {
+		ev.Object, ev.OldObject = nil, nil
+	}
+	s.logger.Info("Event", "event", ev)
+	return nil
+}
+
+func (s *LogSink) Close() error {
+	return nil
+}
+
+type sinkEntry struct {
+	name    string
+	logger  *slog.Logger
+	sink    Sink
+	limiter *ratelimit.Limiter
+	groups  []string
+	tenant  string
+}
+
+// Dispatcher fans events out to all configured sinks.
+type Dispatcher struct {
+	sinks []sinkEntry
+}
+
+func NewDispatcher(configs []config.SinkConfig, logger *slog.Logger) (*Dispatcher, error) {
+	if len(configs) == 0 {
+		configs = []config.SinkConfig{{Name: "log", Type: "log"}}
+	}
+	d := &Dispatcher{}
+	for i, cfg := range configs {
+		// The sink level applies to the sink's own logs and its errors.
+		sinkLogger := logger
+		if cfg.Log.Level != "" {
+			level, err := logging.ParseLevel(cfg.Log.Level)
+			if err != nil {
+				return nil, fmt.Errorf("sink %q: invalid log level: %w", cfg.Name, err)
+			}
+			sinkLogger = logging.WithLevel(logger, level)
+		}
+		sink, err := New(cfg, sinkLogger)
+		if err != nil {
+			return nil, fmt.Errorf("sink %q: %w", cfg.Name, err)
+		}
+		switch cfg.Delivery.Guarantee {
+		case "", config.DeliveryAtMostOnce:
+		case config.DeliveryAtLeastOnce:
+			if sink, err = NewOutboxSink(sinkName(cfg, i), cfg.Delivery, sink, sinkLogger); err != nil {
+				return nil, fmt.Errorf("sink %q: %w", cfg.Name, err)
+			}
+		default:
+			return nil, fmt.Errorf("sink %q: unknown delivery guarantee %q", cfg.Name, cfg.Delivery.Guarantee)
+		}
+		d.sinks = append(d.sinks, sinkEntry{name: sinkName(cfg, i), logger: sinkLogger, sink: sink, limiter: ratelimit.New(cfg.RateLimit), groups: cfg.Groups, tenant: cfg.Tenant})
+	}
+	return d, nil
+}
+
+func sinkName(cfg config.SinkConfig, index int) string {
+	if cfg.Name == "" {
+		return fmt.Sprintf("%s-%d", cfg.Type, index)
+	}
+	return cfg.Name
+}
+
+// Targets returns the names of the sinks of configs that would receive ev,
+// without creating them. Rate limits are not taken into account.
+func Targets(configs []config.SinkConfig, ev event.Event) []string {
+	if len(configs) == 0 {
+		return []string{"log"}
+	}
+	var names []string
+	for i, cfg := range configs {
+		if receives(cfg.Tenant, cfg.Groups, ev) {
+			names = append(names, sinkName(cfg, i))
+		}
+	}
+	return names
+}
+
+func (d *Dispatcher) Dispatch(ctx context.Context, ev event.Event) {
+	for _, entry := range d.sinks {
+		if !receives(entry.tenant, entry.groups, ev) {
+			continue
+		}
+		if !entry.limiter.Allow(ctx) {
+			metrics.DroppedEventsTotal.WithLabelValues("sink", entry.name, "rate_limit").Inc()
+			continue
+		}
+		if err := entry.sink.Send(ctx, ev); err != nil {
+			entry.logger.Error("Failed to send event", "sink", entry.name, "error", err)
+			continue
+		}
+		// Outboxes observe the delivery of their events.
+		if _, ok := entry.sink.(*OutboxSink); !ok {
+			metrics.DeliveryLatencySeconds.WithLabelValues(entry.name).Observe(time.Since(ev.Timestamp).Seconds())
+		}
+	}
+}
+
+// receives reports whether a sink of tenant and groups receives ev. Sinks of
+// a tenant only receive its events.
+func receives(tenant string, groups []string, ev event.Event) bool {
+	return (tenant == "" || tenant == ev.Tenant) && inGroups(groups, ev)
+}
+
+// inGroups reports whether ev belongs to one of the groups of a sink, sinks
+// without groups receive all events.
+func inGroups(groups []string, ev event.Event) bool {
+	if len(groups) == 0 {
+		return true
+	}
+	for _, group := range ev.Groups {
+		if slices.Contains(groups, group.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Close flushes buffering sinks within ctx and closes all sinks.
+func (d *Dispatcher) Close(ctx context.Context) {
+	for _, entry := range d.sinks {
+		if flusher, ok := entry.sink.(Flusher); ok {
+			if err := flusher.Flush(ctx); err != nil {
+				entry.logger.Error("Failed to flush sink", "sink", entry.name, "error", err)
+			}
+		}
+		if err := entry.sink.Close(); err != nil {
+			entry.logger.Error("Failed to close sink", "sink", entry.name, "error", err)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+// SQSSink sends every event as a JSON message to an Amazon SQS queue. FIFO
+// queues receive the events of an object in order, grouped by its UID.
+type SQSSink struct {
+	cfg         config.SQSSinkConfig
+	client      *http.Client
+	credentials *awsCredentialSource
+	fifo        bool
+}
+
+func NewSQSSink(cfg *config.SQSSinkConfig) (*SQSSink, error) {
+	if cfg == nil || cfg.QueueURL == "" {
+		return nil, fmt.Errorf("sqs sink requires sqs.queueURL")
+	}
+	queueURL, err := url.Parse(cfg.QueueURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sqs.queueURL: %w", err)
+	}
+	// https://sqs.<region>.amazonaws.com/<account>/<queue>
+	region := awsRegion(cfg.Region, strings.Split(queueURL.Host, "."), 1)
+	if region == "" {
+		return nil, fmt.Errorf("sqs sink requires sqs.region")
+	}
+	client := &http.Client{Timeout: httpTimeout(cfg.Timeout)}
+	return &SQSSink{
+		cfg:         *cfg,
+		client:      client,
+		credentials: &awsCredentialSource{region: region, client: client},
+		fifo:        strings.HasSuffix(queueURL.Path, ".fifo"),
+	}, nil
+}
+
+func (s *SQSSink) Send(ctx context.Context, ev event.Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	form := url.Values{
+		"Action":      {"SendMessage"},
+		"Version":     {"2012-11-05"},
+		"QueueUrl":    {s.cfg.QueueURL},
+		"MessageBody": {string(data)},
+	}
+	addAWSAttributes(form, "MessageAttribute.%d.", ev)
+	if s.fifo {
+		form.Set("MessageGroupId", messageGroup(ev))
+		form.Set("MessageDeduplicationId", sha256Hex(data))
+	}
+	return postAWSForm(ctx, s.client, s.credentials, "sqs", s.cfg.QueueURL, form)
+}
+
+func (s *SQSSink) Close() error {
+	return nil
+}
+
+// SNSSink publishes every event as a JSON message to an Amazon SNS topic.
+// FIFO topics are grouped by the object UID like FIFO queues.
+type SNSSink struct {
+	cfg         config.SNSSinkConfig
+	client      *http.Client
+	credentials *awsCredentialSource
+	fifo        bool
+}
+
+func NewSNSSink(cfg *config.SNSSinkConfig) (*SNSSink, error) {
+	if cfg == nil || cfg.TopicARN == "" {
+		return nil, fmt.Errorf("sns sink requires sns.topicARN")
+	}
+	// arn:aws:sns:<region>:<account>:<topic>
+	region := awsRegion(cfg.Region, strings.Split(cfg.TopicARN, ":"), 3)
+	if region == "" {
+		return nil, fmt.Errorf("sns sink requires sns.region")
+	}
+	client := &http.Client{Timeout: httpTimeout(cfg.Timeout)}
+	s := &SNSSink{
+		cfg:         *cfg,
+		client:      client,
+		credentials: &awsCredentialSource{region: region, client: client},
+		fifo:        strings.HasSuffix(cfg.TopicARN, ".fifo"),
+	}
+	if s.cfg.Endpoint == "" {
+		s.cfg.Endpoint = fmt.Sprintf("https://sns.%s.amazonaws.com/", region)
+	}
+	return s, nil
+}
+
+func (s *SNSSink) Send(ctx context.Context, ev event.Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	form := url.Values{
+		"Action":   {"Publish"},
+		"Version":  {"2010-03-31"},
+		"TopicArn": {s.cfg.TopicARN},
+		"Message":  {string(data)},
+	}
+	addAWSAttributes(form, "MessageAttributes.entry.%d.", ev)
+	if s.fifo {
+		form.Set("MessageGroupId", messageGroup(ev))
+		form.Set("MessageDeduplicationId", sha256Hex(data))
+	}
+	return postAWSForm(ctx, s.client, s.credentials, "sns", s.cfg.Endpoint, form)
+}
+
+func (s *SNSSink) Close() error {
+	return nil
+}
+
+// awsRegion returns the configured region, the one found at index of the
+// parts of the queue host or topic ARN, or AWS_REGION.
+func awsRegion(configured string, parts []string, index int) string {
+	if configured != "" {
+		return configured
+	}
+	if len(parts) > index && strings.Count(parts[index], "-") >= 2 {
+		return parts[index]
+	}
+	return os.Getenv("AWS_REGION")
+}
+
+// addAWSAttributes adds the message attributes of ev in the query API format
+// described by prefix. Empty values are not allowed and skipped.
+func addAWSAttributes(form url.Values, prefix string, ev event.Event) {
+	attributes := messageAttributes(ev)
+	names := make([]string, 0, len(attributes))
+	for name, value := range attributes {
+		if value != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		p := fmt.Sprintf(prefix, i+1)
+		form.Set(p+"Name", name)
+		form.Set(p+"Value.DataType", "String")
+		form.Set(p+"Value.StringValue", attributes[name])
+	}
+}
+
+// messageGroup keeps the events of one object in order, objects without a
+// UID (e.g. synthetic events) are grouped by name.
+func messageGroup(ev event.Event) string {
+	if ev.UID != "" {
+		return ev.UID
+	}
+	return objectPath(ev)
+}
@@ -0,0 +1,226 @@
+package sink
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+const (
+	defaultSyslogAppName = "k8s-resource-watcher"
+	// syslogSDID is the structured data element of the event attributes,
+	// 32473 is the private enterprise number reserved for examples.
+	syslogSDID = "k8s@32473"
+)
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+var syslogSeverities = map[string]int{
+	"emerg": 0, "alert": 1, "crit": 2, "err": 3, "warning": 4, "notice": 5, "info": 6, "debug": 7,
+}
+
+// defaultSyslogSeverities maps event types without a configured severity.
+var defaultSyslogSeverities = map[string]string{
+	"Delete":                      "notice",
+	event.TypeFlapping:            "warning",
+	event.TypeNodeNotReady:        "warning",
+	event.TypeCertificateExpiring: "warning",
+}
+
+// SyslogSink sends every event as an RFC 5424 message over UDP, TCP or TLS.
+// TCP and TLS use octet counting framing (RFC 6587) and reconnect when the
+// connection was lost.
+type SyslogSink struct {
+	cfg        config.SyslogSinkConfig
+	facility   int
+	severities map[string]int
+	hostname   string
+	tlsConfig  *tls.Config
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func NewSyslogSink(cfg *config.SyslogSinkConfig) (*SyslogSink, error) {
+	if cfg == nil || cfg.Address == "" {
+		return nil, fmt.Errorf("syslog sink requires syslog.address")
+	}
+	s := &SyslogSink{cfg: *cfg, severities: map[string]int{}}
+	switch s.cfg.Network {
+	case "":
+		s.cfg.Network = "udp"
+	case "udp", "tcp", "tls":
+	default:
+		return nil, fmt.Errorf("unknown syslog.network %q, expected udp, tcp or tls", s.cfg.Network)
+	}
+	if s.cfg.Facility == "" {
+		s.cfg.Facility = "daemon"
+	}
+	facility, ok := syslogFacilities[s.cfg.Facility]
+	if !ok {
+		return nil, fmt.Errorf("unknown syslog.facility %q", s.cfg.Facility)
+	}
+	s.facility = facility
+	for eventType, name := range s.cfg.Severities {
+		severity, ok := syslogSeverities[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown syslog severity %q for %s events", name, eventType)
+		}
+		s.severities[eventType] = severity
+	}
+	if s.cfg.AppName == "" {
+		s.cfg.AppName = defaultSyslogAppName
+	}
+	s.hostname = s.cfg.Hostname
+	if s.hostname == "" {
+		s.hostname, _ = os.Hostname()
+	}
+	if s.cfg.Network == "tls" {
+		s.tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		if s.cfg.CAFile != "" {
+			ca, err := os.ReadFile(s.cfg.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read syslog.caFile: %w", err)
+			}
+			s.tlsConfig.RootCAs = x509.NewCertPool()
+			if !s.tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+				return nil, fmt.Errorf("syslog.caFile contains no certificates")
+			}
+		}
+	}
+	return s, nil
+}
+
+func (s *SyslogSink) Send(ctx context.Context, ev event.Event) error {
+	msg, err := s.format(ev)
+	if err != nil {
+		return err
+	}
+	if s.cfg.Network != "udp" {
+		msg = append([]byte(fmt.Sprintf("%d ", len(msg))), msg...)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// A broken connection is only noticed when writing, the message is
+	// retried once on a new one.
+	for attempt := 0; ; attempt++ {
+		err := s.write(ctx, msg)
+		if err == nil || attempt > 0 {
+			return err
+		}
+		s.closeConn()
+	}
+}
+
+func (s *SyslogSink) write(ctx context.Context, msg []byte) error {
+	if s.conn == nil {
+		dialer := &net.Dialer{Timeout: httpTimeout(s.cfg.Timeout)}
+		var err error
+		if s.tlsConfig != nil {
+			s.conn, err = (&tls.Dialer{NetDialer: dialer, Config: s.tlsConfig}).DialContext(ctx, "tcp", s.cfg.Address)
+		} else {
+			s.conn, err = dialer.DialContext(ctx, s.cfg.Network, s.cfg.Address)
+		}
+		if err != nil {
+			s.conn = nil
+			return err
+		}
+	}
+	if err := s.conn.SetWriteDeadline(time.Now().Add(httpTimeout(s.cfg.Timeout))); err != nil {
+		return err
+	}
+	_, err := s.conn.Write(msg)
+	return err
+}
+
+// format renders ev as "<PRI>1 TIMESTAMP HOSTNAME APP-NAME - MSGID [SD] MSG"
+// with the event type as MSGID and the JSON event as MSG.
+func (s *SyslogSink) format(ev event.Event) ([]byte, error) {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return nil, err
+	}
+	severity, ok := s.severities[ev.Type]
+	if !ok {
+		severity = syslogSeverities["info"]
+		if name, found := defaultSyslogSeverities[ev.Type]; found {
+			severity = syslogSeverities[name]
+		}
+	}
+	attributes := messageAttributes(ev)
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var sd strings.Builder
+	sd.WriteString("[" + syslogSDID)
+	for _, key := range keys {
+		fmt.Fprintf(&sd, " %s=\"%s\"", key, escapeSDValue(attributes[key]))
+	}
+	sd.WriteString("]")
+	timestamp := ev.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	header := fmt.Sprintf("<%d>1 %s %s %s - %s %s ",
+		s.facility*8+severity,
+		timestamp.UTC().Format(time.RFC3339Nano),
+		syslogHeaderField(s.hostname, 255),
+		syslogHeaderField(s.cfg.AppName, 48),
+		syslogHeaderField(ev.Type, 32),
+		sd.String(),
+	)
+	return append([]byte(header), data...), nil
+}
+
+// syslogHeaderField returns "-" for empty values and cuts value to the
+// maximum length of its header field.
+func syslogHeaderField(value string, maxLen int) string {
+	value = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return -1
+		}
+		return r
+	}, value)
+	if value == "" {
+		return "-"
+	}
+	if len(value) > maxLen {
+		return value[:maxLen]
+	}
+	return value
+}
+
+func escapeSDValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
+
+func (s *SyslogSink) closeConn() {
+	if s.conn != nil {
+		s.conn.Close()
+		s.conn = nil
+	}
+}
+
+func (s *SyslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closeConn()
+	return nil
+}
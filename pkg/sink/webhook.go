@@ -0,0 +1,104 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+// WebhookSink posts events as JSON to an HTTP endpoint. Without batching every
+// request holds a single event, with batching a JSON array of events.
+type WebhookSink struct {
+	cfg     config.WebhookSinkConfig
+	client  *http.Client
+	batcher *batcher
+}
+
+func NewWebhookSink(cfg *config.WebhookSinkConfig, logger *slog.Logger) (*WebhookSink, error) {
+	if cfg == nil || cfg.URL == "" {
+		return nil, fmt.Errorf("webhook sink requires webhook.url")
+	}
+	if err := validateCompression(cfg.Compression); err != nil {
+		return nil, fmt.Errorf("invalid webhook.compression: %w", err)
+	}
+	s := &WebhookSink{cfg: *cfg, client: &http.Client{Timeout: httpTimeout(cfg.Timeout)}}
+	if cfg.Batch != (config.BatchConfig{}) {
+		s.batcher = newBatcher(cfg.Batch, logger.With("sink", "webhook"), s.sendBatch)
+	}
+	return s, nil
+}
+
+func (s *WebhookSink) Send(ctx context.Context, ev event.Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	if s.batcher == nil {
+		return s.post(ctx, data)
+	}
+	return s.batcher.Add(ctx, data)
+}
+
+func (s *WebhookSink) sendBatch(ctx context.Context, batch [][]byte) error {
+	var body bytes.Buffer
+	body.WriteByte('[')
+	for i, data := range batch {
+		if i > 0 {
+			body.WriteByte(',')
+		}
+		body.Write(data)
+	}
+	body.WriteByte(']')
+	return s.post(ctx, body.Bytes())
+}
+
+func (s *WebhookSink) post(ctx context.Context, body []byte) error {
+	body, encoding, err := compress(s.cfg.Compression, body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if encoding != "" {
+		req.Header.Set("Content-Encoding", encoding)
+	}
+	for key, value := range s.cfg.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s: %s", s.cfg.URL, resp.Status, bytes.TrimSpace(respBody))
+	}
+	return nil
+}
+
+// Flush sends the pending batch.
+func (s *WebhookSink) Flush(ctx context.Context) error {
+	if s.batcher == nil {
+		return nil
+	}
+	return s.batcher.Flush(ctx)
+}
+
+func (s *WebhookSink) Close() error {
+	if s.batcher != nil {
+		s.batcher.Close()
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+// Package sinkplugin defines the protocol between k8s-resource-watcher and
+// out-of-tree sink plugins. A plugin is a separate binary that calls Serve
+// with its Sink implementation:
+//
+//	func main() {
+//		sinkplugin.Serve(&mySink{})
+//	}
+//
+// and is configured as a sink of type "plugin" with the path to the binary.
+package sinkplugin
+
+import (
+	"encoding/json"
+	"net/rpc"
+
+	"github.com/hashicorp/go-plugin"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+// Handshake is shared by the watcher and its plugins. It is not a security
+// measure, it only makes sure a plugin binary is meant to be used as a sink.
+var Handshake = plugin.HandshakeConfig{
+	ProtocolVersion:  1,
+	MagicCookieKey:   "K8S_RESOURCE_WATCHER_PLUGIN",
+	MagicCookieValue: "sink",
+}
+
+// PluginName is the name the sink is dispensed under.
+const PluginName = "sink"
+
+// Event is the event passed to plugins, see the event package for the schema.
+type Event = event.Event
+
+// FieldChange describes a single changed field of an Update event.
+type FieldChange = event.FieldChange
+
+// Sink is implemented by plugins.
+type Sink interface {
+	// Configure receives the plugin specific settings from the watcher config.
+	Configure(settings map[string]string) error
+	Send(ev Event) error
+	Close() error
+}
+
+// SinkPlugin implements plugin.Plugin over net/rpc.
+type SinkPlugin struct {
+	Impl Sink
+}
+
+func (p *SinkPlugin) Server(*plugin.MuxBroker) (interface{}, error) {
+	return &rpcServer{impl: p.Impl}, nil
+}
+
+func (p *SinkPlugin) Client(_ *plugin.MuxBroker, client *rpc.Client) (interface{}, error) {
+	return &rpcClient{client: client}, nil
+}
+
+// Serve runs the plugin and blocks until the watcher disconnects.
+func Serve(impl Sink) {
+	plugin.Serve(&plugin.ServeConfig{
+		HandshakeConfig: Handshake,
+		Plugins:         map[string]plugin.Plugin{PluginName: &SinkPlugin{Impl: impl}},
+	})
+}
+
+type rpcClient struct {
+	client *rpc.Client
+}
+
+func (c *rpcClient) Configure(settings map[string]string) error {
+	return c.client.Call("Plugin.Configure", settings, new(interface{}))
+}
+
+// Send passes the event as JSON, gob can not encode arbitrary object contents.
+func (c *rpcClient) Send(ev Event) error {
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	return c.client.Call("Plugin.Send", payload, new(interface{}))
+}
+
+func (c *rpcClient) Close() error {
+	return c.client.Call("Plugin.Close", new(interface{}), new(interface{}))
+}
+
+type rpcServer struct {
+	impl Sink
+}
+
+func (s *rpcServer) Configure(settings map[string]string, _ *interface{}) error {
+	return s.impl.Configure(settings)
+}
+
+func (s *rpcServer) Send(payload []byte, _ *interface{}) error {
+	var ev Event
+	if err := json.Unmarshal(payload, &ev); err != nil {
+		return err
+	}
+	return s.impl.Send(ev)
+}
+
+func (s *rpcServer) Close(_ interface{}, _ *interface{}) error {
+	return s.impl.Close()
+}
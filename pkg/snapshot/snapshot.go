@@ -0,0 +1,166 @@
+// Package snapshot records the filtered state of the watched resources and
+// compares it against a baseline to report drift.
+package snapshot
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+	"github.com/fl64/k8s-resource-watcher/pkg/filter"
+)
+
+// Drift event types reported by Diff.
+const (
+	TypeDriftAdded   = "DriftAdded"
+	TypeDriftRemoved = "DriftRemoved"
+	TypeDriftChanged = "DriftChanged"
+)
+
+// Object is the filtered state of a single object.
+type Object struct {
+	GVR       event.GVR              `json:"gvr"`
+	Namespace string                 `json:"namespace,omitempty"`
+	Name      string                 `json:"name"`
+	UID       string                 `json:"uid,omitempty"`
+	Object    map[string]interface{} `json:"object"`
+}
+
+func (o Object) key() string {
+	return strings.Join([]string{o.GVR.Group, o.GVR.Version, o.GVR.Resource, o.Namespace, o.Name}, "/")
+}
+
+// Snapshot is the state of all watched objects at a point in time.
+type Snapshot struct {
+	Name    string    `json:"name"`
+	Cluster string    `json:"cluster,omitempty"`
+	TakenAt time.Time `json:"takenAt"`
+	Objects []Object  `json:"objects"`
+}
+
+// Collector builds a snapshot from a stream of events.
+type Collector struct {
+	mu      sync.Mutex
+	cluster string
+	objects map[string]Object
+}
+
+func NewCollector() *Collector {
+	return &Collector{objects: make(map[string]Object)}
+}
+
+// Add records the object of ev, Delete events remove it.
+func (c *Collector) Add(ev event.Event) {
+	// Summaries of the initial list hold no object.
+	if ev.Type == event.TypeSyncSummary {
+		return
+	}
+	obj := Object{GVR: ev.GVR, Namespace: ev.Namespace, Name: ev.Name, UID: ev.UID, Object: ev.Object}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cluster = ev.Cluster
+	if ev.Type == "Delete" {
+		delete(c.objects, obj.key())
+		return
+	}
+	c.objects[obj.key()] = obj
+}
+
+// Snapshot returns the collected objects sorted by resource, namespace and name.
+func (c *Collector) Snapshot(name string) *Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	snapshot := &Snapshot{Name: name, Cluster: c.cluster, TakenAt: time.Now().UTC(), Objects: make([]Object, 0, len(c.objects))}
+	for _, obj := range c.objects {
+		snapshot.Objects = append(snapshot.Objects, obj)
+	}
+	sort.Slice(snapshot.Objects, func(i, j int) bool {
+		return snapshot.Objects[i].key() < snapshot.Objects[j].key()
+	})
+	return snapshot
+}
+
+// NameFromPath derives the snapshot name from its file name.
+func NameFromPath(path string) string {
+	base := filepath.Base(path)
+	return strings.TrimSuffix(base, filepath.Ext(base))
+}
+
+// Save writes the snapshot to path as JSON.
+func (s *Snapshot) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Load reads a snapshot written by Save.
+func Load(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	snapshot := &Snapshot{}
+	if err := json.Unmarshal(data, snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// Diff reports the objects added, removed or changed in current since baseline
+// as drift events. Changed objects carry the field diff and the baseline state as OldObject.
+func Diff(baseline, current *Snapshot) []event.Event {
+	previous := make(map[string]Object, len(baseline.Objects))
+	for _, obj := range baseline.Objects {
+		previous[obj.key()] = obj
+	}
+	var events []event.Event
+	for _, obj := range current.Objects {
+		old, ok := previous[obj.key()]
+		delete(previous, obj.key())
+		if !ok {
+			events = append(events, driftEvent(TypeDriftAdded, current, obj))
+			continue
+		}
+		diff := filter.Diff(old.Object, obj.Object)
+		if len(diff) == 0 {
+			continue
+		}
+		ev := driftEvent(TypeDriftChanged, current, obj)
+		ev.OldObject = old.Object
+		ev.Diff = diff
+		events = append(events, ev)
+	}
+	removed := make([]Object, 0, len(previous))
+	for _, obj := range previous {
+		removed = append(removed, obj)
+	}
+	sort.Slice(removed, func(i, j int) bool { return removed[i].key() < removed[j].key() })
+	for _, obj := range removed {
+		ev := driftEvent(TypeDriftRemoved, current, obj)
+		ev.Object = nil
+		ev.OldObject = obj.Object
+		events = append(events, ev)
+	}
+	return events
+}
+
+func driftEvent(eventType string, snapshot *Snapshot, obj Object) event.Event {
+	return event.Event{
+		SchemaVersion: event.SchemaVersion,
+		Cluster:       snapshot.Cluster,
+		GVR:           obj.GVR,
+		Namespace:     obj.Namespace,
+		Name:          obj.Name,
+		UID:           obj.UID,
+		Type:          eventType,
+		Timestamp:     snapshot.TakenAt,
+		Object:        obj.Object,
+	}
+}
@@ -0,0 +1,251 @@
+package store
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+const (
+	// maxLineSize bounds a single stored event, large objects are rare but exist.
+	maxLineSize               = 16 << 20
+	defaultCompactionInterval = time.Hour
+)
+
+// FileStore keeps events as JSON lines in a single file.
+type FileStore struct {
+	path      string
+	retention config.RetentionConfig
+
+	mu   sync.Mutex
+	file *os.File
+	stop chan struct{}
+	done chan struct{}
+}
+
+func NewFileStore(cfg config.StoreConfig) (*FileStore, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("file store requires store.path")
+	}
+	s := &FileStore{path: cfg.Path, retention: cfg.Retention}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	r := cfg.Retention
+	if r.MaxAge > 0 || r.MaxSizeBytes > 0 || r.MaxEventsPerObject > 0 {
+		interval := r.CompactionInterval
+		if interval <= 0 {
+			interval = defaultCompactionInterval
+		}
+		s.stop = make(chan struct{})
+		s.done = make(chan struct{})
+		go s.compactLoop(interval)
+	}
+	return s, nil
+}
+
+func (s *FileStore) open() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	s.file = file
+	return nil
+}
+
+func (s *FileStore) Append(ev event.Event) error {
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+	// Longer lines could not be scanned by Read and Compact anymore.
+	if len(data) >= maxLineSize {
+		return fmt.Errorf("event of %d bytes exceeds the store limit of %d bytes", len(data), maxLineSize)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.file.Write(append(data, '\n'))
+	return err
+}
+
+func (s *FileStore) Read(ctx context.Context, from, to time.Time, fn func(event.Event) error) error {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	scanner := newScanner(file)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		var ev event.Event
+		if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+			return fmt.Errorf("corrupt event in %s: %w", s.path, err)
+		}
+		if !inRange(ev.Timestamp, from, to) {
+			continue
+		}
+		if err := fn(ev); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func (s *FileStore) compactLoop(interval time.Duration) {
+	defer close(s.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			// Errors leave the store as it was, the next run tries again.
+			_ = s.Compact()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// storedEvent holds what compaction needs to know about a line.
+type storedEvent struct {
+	Timestamp time.Time `json:"timestamp"`
+	GVR       event.GVR `json:"gvr"`
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+}
+
+func (e storedEvent) key() string {
+	return strings.Join([]string{e.GVR.Group, e.GVR.Resource, e.Namespace, e.Name}, "/")
+}
+
+// Compact rewrites the store without the events that exceed the retention limits.
+func (s *FileStore) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keep, err := s.retained()
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(s.path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	tmpPath := s.path + ".compact"
+	dst, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(dst)
+	scanner := newScanner(src)
+	for i := 0; scanner.Scan(); i++ {
+		if i < len(keep) && !keep[i] {
+			continue
+		}
+		writer.Write(scanner.Bytes())
+		writer.WriteByte('\n')
+	}
+	if err := scanner.Err(); err != nil {
+		dst.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		dst.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := os.Rename(tmpPath, s.path); err != nil {
+		return err
+	}
+	s.file.Close()
+	return s.open()
+}
+
+// retained decides for every line of the store whether it is kept.
+func (s *FileStore) retained() ([]bool, error) {
+	file, err := os.Open(s.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var events []storedEvent
+	var sizes []int64
+	scanner := newScanner(file)
+	for scanner.Scan() {
+		var ev storedEvent
+		// Corrupt lines are kept, Read reports them.
+		_ = json.Unmarshal(scanner.Bytes(), &ev)
+		events = append(events, ev)
+		sizes = append(sizes, int64(len(scanner.Bytes())+1))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	keep := make([]bool, len(events))
+	cutoff := time.Time{}
+	if s.retention.MaxAge > 0 {
+		cutoff = time.Now().Add(-s.retention.MaxAge)
+	}
+	perObject := make(map[string]int)
+	for i := len(events) - 1; i >= 0; i-- {
+		ev := events[i]
+		if !cutoff.IsZero() && !ev.Timestamp.IsZero() && ev.Timestamp.Before(cutoff) {
+			continue
+		}
+		if s.retention.MaxEventsPerObject > 0 {
+			perObject[ev.key()]++
+			if perObject[ev.key()] > s.retention.MaxEventsPerObject {
+				continue
+			}
+		}
+		keep[i] = true
+	}
+	if s.retention.MaxSizeBytes > 0 {
+		// Walk from the newest event and drop everything past the size limit.
+		var total int64
+		for i := len(events) - 1; i >= 0; i-- {
+			if !keep[i] {
+				continue
+			}
+			total += sizes[i]
+			if total > s.retention.MaxSizeBytes {
+				keep[i] = false
+			}
+		}
+	}
+	return keep, nil
+}
+
+func newScanner(file *os.File) *bufio.Scanner {
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64<<10), maxLineSize)
+	return scanner
+}
+
+func (s *FileStore) Close() error {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.file.Close()
+}
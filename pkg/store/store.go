@@ -0,0 +1,38 @@
+// Package store persists events so that they can be replayed later.
+package store
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+// Store is an append-only event log.
+type Store interface {
+	Append(ev event.Event) error
+	// Read calls fn for every stored event with from <= timestamp < to in the order
+	// they were appended. Zero times leave the range open.
+	Read(ctx context.Context, from, to time.Time, fn func(event.Event) error) error
+	Close() error
+}
+
+// New opens the store described by cfg, nil when no store is configured.
+func New(cfg config.StoreConfig) (Store, error) {
+	switch cfg.Type {
+	case "":
+		return nil, nil
+	case "file":
+		return NewFileStore(cfg)
+	}
+	return nil, fmt.Errorf("unknown store type %q", cfg.Type)
+}
+
+func inRange(ts, from, to time.Time) bool {
+	if !from.IsZero() && ts.Before(from) {
+		return false
+	}
+	return to.IsZero() || ts.Before(to)
+}
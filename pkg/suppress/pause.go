@@ -0,0 +1,137 @@
+package suppress
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+	"github.com/fl64/k8s-resource-watcher/pkg/metrics"
+)
+
+// Pauser holds back events while emission is paused at runtime, for all
+// resources or single ones. Depending on the action the events are dropped
+// or handed to release on resume.
+type Pauser struct {
+	action      string
+	maxBuffered int
+	release     func(event.Event)
+
+	mu        sync.Mutex
+	all       bool
+	resources map[string]bool
+	buffered  []event.Event
+	closed    bool
+}
+
+// PauseStatus lists what is paused. Resources are named like in suppression
+// windows, e.g. "pods" or "deployments.apps".
+type PauseStatus struct {
+	All       bool     `json:"all"`
+	Resources []string `json:"resources,omitempty"`
+	Buffered  int      `json:"buffered"`
+}
+
+func NewPauser(cfg config.PauseConfig, release func(event.Event)) (*Pauser, error) {
+	switch cfg.Action {
+	case "", config.SuppressionActionDrop, config.SuppressionActionBuffer:
+	default:
+		return nil, fmt.Errorf("unknown pause action %q, expected drop or buffer", cfg.Action)
+	}
+	p := &Pauser{action: cfg.Action, maxBuffered: cfg.MaxBuffered, release: release, resources: map[string]bool{}}
+	if p.maxBuffered <= 0 {
+		p.maxBuffered = defaultMaxBuffered
+	}
+	return p, nil
+}
+
+// Pause holds back the events of resource, or of all resources when it is empty.
+func (p *Pauser) Pause(resource string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if resource == "" {
+		p.all = true
+	} else {
+		p.resources[resource] = true
+	}
+}
+
+// Resume lets the events of resource pass again, or of all resources when it
+// is empty. Buffered events that are no longer paused are released.
+func (p *Pauser) Resume(resource string) {
+	p.mu.Lock()
+	if resource == "" {
+		p.all = false
+		p.resources = map[string]bool{}
+	} else {
+		delete(p.resources, resource)
+	}
+	var released, kept []event.Event
+	for _, ev := range p.buffered {
+		if p.paused(ev) {
+			kept = append(kept, ev)
+		} else {
+			released = append(released, ev)
+		}
+	}
+	p.buffered = kept
+	p.mu.Unlock()
+	for _, ev := range released {
+		p.release(ev)
+	}
+}
+
+func (p *Pauser) Status() PauseStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	status := PauseStatus{All: p.all, Buffered: len(p.buffered)}
+	for resource := range p.resources {
+		status.Resources = append(status.Resources, resource)
+	}
+	sort.Strings(status.Resources)
+	return status
+}
+
+// Allow reports whether ev passes, a nil pauser allows everything.
+func (p *Pauser) Allow(ev event.Event) bool {
+	if p == nil {
+		return true
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed || !p.paused(ev) {
+		return true
+	}
+	if p.action != config.SuppressionActionBuffer {
+		metrics.DroppedEventsTotal.WithLabelValues("pause", ev.GVR.Resource, "paused").Inc()
+		return false
+	}
+	if len(p.buffered) >= p.maxBuffered {
+		metrics.DroppedEventsTotal.WithLabelValues("pause", ev.GVR.Resource, "buffer_full").Inc()
+		return false
+	}
+	p.buffered = append(p.buffered, ev)
+	return false
+}
+
+// paused must be called with p.mu held.
+func (p *Pauser) paused(ev event.Event) bool {
+	return p.all || p.resources[ev.GVR.Resource] || p.resources[ev.GVR.Resource+"."+ev.GVR.Group]
+}
+
+// Close releases the buffered events and lets all later events pass, so that
+// nothing is held back on shutdown.
+func (p *Pauser) Close() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	p.closed = true
+	buffered := p.buffered
+	p.buffered = nil
+	p.mu.Unlock()
+	for _, ev := range buffered {
+		p.release(ev)
+	}
+}
@@ -0,0 +1,172 @@
+// Package suppress holds back events during maintenance windows.
+package suppress
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+	"github.com/fl64/k8s-resource-watcher/pkg/metrics"
+)
+
+const defaultMaxBuffered = 10000
+
+type window struct {
+	cfg        config.SuppressionWindowConfig
+	schedule   cron.Schedule
+	namespaces map[string]bool
+	resources  map[string]bool
+}
+
+// Suppressor drops or buffers events that fall into a suppression window.
+// Buffered events are handed to release when their window ends.
+type Suppressor struct {
+	windows     []*window
+	maxBuffered int
+	release     func(event.Event)
+
+	mu       sync.Mutex
+	buffered []event.Event
+	timer    *time.Timer
+	closed   bool
+}
+
+// New returns nil when no windows are configured.
+func New(cfg config.SuppressionConfig, release func(event.Event)) (*Suppressor, error) {
+	if len(cfg.Windows) == 0 {
+		return nil, nil
+	}
+	s := &Suppressor{maxBuffered: cfg.MaxBuffered, release: release}
+	if s.maxBuffered <= 0 {
+		s.maxBuffered = defaultMaxBuffered
+	}
+	for _, windowCfg := range cfg.Windows {
+		w := &window{cfg: windowCfg, namespaces: set(windowCfg.Namespaces), resources: set(windowCfg.Resources)}
+		switch {
+		case windowCfg.Schedule != "":
+			schedule, err := cron.ParseStandard(windowCfg.Schedule)
+			if err != nil {
+				return nil, fmt.Errorf("suppression window %q: %w", windowCfg.Name, err)
+			}
+			if windowCfg.Duration <= 0 {
+				return nil, fmt.Errorf("suppression window %q: schedule requires a duration", windowCfg.Name)
+			}
+			w.schedule = schedule
+		case !windowCfg.From.IsZero() && windowCfg.To.After(windowCfg.From):
+		default:
+			return nil, fmt.Errorf("suppression window %q: either schedule and duration or from and to are required", windowCfg.Name)
+		}
+		switch windowCfg.Action {
+		case "", config.SuppressionActionDrop, config.SuppressionActionBuffer:
+		default:
+			return nil, fmt.Errorf("suppression window %q: unknown action %q", windowCfg.Name, windowCfg.Action)
+		}
+		s.windows = append(s.windows, w)
+	}
+	return s, nil
+}
+
+// Allow reports whether ev passes. Suppressed events are dropped or kept
+// until the window ends, depending on the window action. A nil suppressor allows everything.
+func (s *Suppressor) Allow(ev event.Event) bool {
+	if s == nil {
+		return true
+	}
+	s.mu.Lock()
+	closed := s.closed
+	s.mu.Unlock()
+	if closed {
+		return true
+	}
+	now := time.Now()
+	for _, w := range s.windows {
+		end, active := w.activeUntil(now)
+		if !active || !w.matches(ev) {
+			continue
+		}
+		if w.cfg.Action != config.SuppressionActionBuffer {
+			metrics.DroppedEventsTotal.WithLabelValues("suppression", w.cfg.Name, "maintenance").Inc()
+			return false
+		}
+		s.buffer(ev, w.cfg.Name, end)
+		return false
+	}
+	return true
+}
+
+func (s *Suppressor) buffer(ev event.Event, name string, end time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.buffered) >= s.maxBuffered {
+		metrics.DroppedEventsTotal.WithLabelValues("suppression", name, "buffer_full").Inc()
+		return
+	}
+	s.buffered = append(s.buffered, ev)
+	// Released events are checked again, overlapping windows hold them back further.
+	if s.timer == nil {
+		s.timer = time.AfterFunc(time.Until(end), s.Flush)
+	}
+}
+
+// Flush releases all buffered events.
+func (s *Suppressor) Flush() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	buffered := s.buffered
+	s.buffered = nil
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.mu.Unlock()
+	for _, ev := range buffered {
+		s.release(ev)
+	}
+}
+
+// Close releases the buffered events and lets all later events pass, so that
+// nothing is held back on shutdown.
+func (s *Suppressor) Close() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.closed = true
+	s.mu.Unlock()
+	s.Flush()
+}
+
+// activeUntil reports whether the window is active at now and when it ends.
+func (w *window) activeUntil(now time.Time) (time.Time, bool) {
+	if w.schedule == nil {
+		return w.cfg.To, !now.Before(w.cfg.From) && now.Before(w.cfg.To)
+	}
+	// The window is active when it started within the last duration.
+	start := w.schedule.Next(now.Add(-w.cfg.Duration))
+	end := start.Add(w.cfg.Duration)
+	return end, !start.After(now) && now.Before(end)
+}
+
+func (w *window) matches(ev event.Event) bool {
+	if len(w.namespaces) > 0 && !w.namespaces[ev.Namespace] {
+		return false
+	}
+	if len(w.resources) > 0 && !w.resources[ev.GVR.Resource] && !w.resources[ev.GVR.Resource+"."+ev.GVR.Group] {
+		return false
+	}
+	return true
+}
+
+func set(values []string) map[string]bool {
+	result := make(map[string]bool, len(values))
+	for _, value := range values {
+		result[value] = true
+	}
+	return result
+}
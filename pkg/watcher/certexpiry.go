@@ -0,0 +1,113 @@
+package watcher
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const defaultExpiryInterval = time.Hour
+
+// expiryChecker remembers the certificates it warned about, so that every
+// certificate is reported once until it is renewed.
+type expiryChecker struct {
+	window   time.Duration
+	interval time.Duration
+
+	mu     sync.Mutex
+	warned map[string]time.Time
+}
+
+func newExpiryChecker(window, interval time.Duration) *expiryChecker {
+	if window <= 0 {
+		return nil
+	}
+	if interval <= 0 {
+		interval = defaultExpiryInterval
+	}
+	return &expiryChecker{window: window, interval: interval, warned: make(map[string]time.Time)}
+}
+
+// Check reports whether the certificate of the object key expiring at
+// notAfter is to be warned about now.
+func (c *expiryChecker) Check(key string, notAfter, now time.Time) bool {
+	if notAfter.Sub(now) > c.window {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if warned, ok := c.warned[key]; ok && warned.Equal(notAfter) {
+		return false
+	}
+	c.warned[key] = notAfter
+	return true
+}
+
+func (c *expiryChecker) Forget(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.warned, key)
+}
+
+// certificate is the expiry and subject of the certificate held by a
+// kubernetes.io/tls Secret or reported by a cert-manager Certificate.
+type certificate struct {
+	notAfter time.Time
+	subject  string
+}
+
+func certificateOf(obj *unstructured.Unstructured) (certificate, bool) {
+	if obj.Object["type"] == "kubernetes.io/tls" {
+		return tlsCertificate(obj)
+	}
+	value, found, _ := unstructured.NestedString(obj.Object, "status", "notAfter")
+	if !found {
+		return certificate{}, false
+	}
+	notAfter, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return certificate{}, false
+	}
+	subject, _, _ := unstructured.NestedString(obj.Object, "spec", "commonName")
+	if names, _, _ := unstructured.NestedStringSlice(obj.Object, "spec", "dnsNames"); subject == "" && len(names) > 0 {
+		subject = names[0]
+	}
+	return certificate{notAfter: notAfter, subject: subject}, true
+}
+
+// tlsCertificate parses the leaf certificate of a Secret, which comes first
+// in tls.crt followed by the chain.
+func tlsCertificate(obj *unstructured.Unstructured) (certificate, bool) {
+	encoded, _, _ := unstructured.NestedString(obj.Object, "data", "tls.crt")
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return certificate{}, false
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return certificate{}, false
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return certificate{}, false
+	}
+	return certificate{notAfter: cert.NotAfter, subject: cert.Subject.String()}, true
+}
+
+// annotate adds the expiry of cert to annotations, expiresIn is negative once
+// the certificate expired.
+func (cert certificate) annotate(annotations map[string]string, now time.Time) map[string]string {
+	if annotations == nil {
+		annotations = make(map[string]string)
+	}
+	annotations["certificate.notAfter"] = cert.notAfter.UTC().Format(time.RFC3339)
+	annotations["certificate.expiresIn"] = cert.notAfter.Sub(now).Round(time.Minute).String()
+	if cert.subject != "" {
+		annotations["certificate.subject"] = cert.subject
+	}
+	return annotations
+}
@@ -0,0 +1,88 @@
+package watcher
+
+import (
+	"reflect"
+	"slices"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+// changedBy makes a best-effort guess at who performed an update from the
+// managedFields of both object versions: the manager whose entry was added or
+// changed with the newest timestamp wins, the most recent entry is used otherwise.
+func changedBy(oldObj, newObj metav1.Object) *event.ChangedBy {
+	var changed, latest *metav1.ManagedFieldsEntry
+	for _, entry := range changedEntries(oldObj, newObj) {
+		if entry.Time != nil && (changed == nil || changed.Time.Before(entry.Time)) {
+			changed = entry
+		}
+	}
+	entries := newObj.GetManagedFields()
+	for i := range entries {
+		entry := &entries[i]
+		if entry.Time != nil && (latest == nil || latest.Time.Before(entry.Time)) {
+			latest = entry
+		}
+	}
+	if changed == nil {
+		changed = latest
+	}
+	if changed == nil {
+		return nil
+	}
+	return &event.ChangedBy{
+		Manager:     changed.Manager,
+		Operation:   string(changed.Operation),
+		Subresource: changed.Subresource,
+		Time:        changed.Time.UTC(),
+	}
+}
+
+// changedEntries returns the managedFields entries of newObj that were added
+// or changed since oldObj, i.e. the managers taking part in the update.
+func changedEntries(oldObj, newObj metav1.Object) []*metav1.ManagedFieldsEntry {
+	previous := make(map[string]metav1.ManagedFieldsEntry)
+	for _, entry := range oldObj.GetManagedFields() {
+		previous[managedFieldsKey(entry)] = entry
+	}
+	var changed []*metav1.ManagedFieldsEntry
+	entries := newObj.GetManagedFields()
+	for i := range entries {
+		entry := &entries[i]
+		prev, ok := previous[managedFieldsKey(*entry)]
+		if ok && !newer(entry.Time, prev.Time) && reflect.DeepEqual(entry.FieldsV1, prev.FieldsV1) {
+			continue
+		}
+		changed = append(changed, entry)
+	}
+	return changed
+}
+
+// onlyManagers reports whether all managers taking part in the update are in
+// managers. Updates without changed entries, e.g. with stripped managedFields,
+// never match.
+func onlyManagers(oldObj, newObj metav1.Object, managers []string) bool {
+	changed := changedEntries(oldObj, newObj)
+	if len(changed) == 0 {
+		return false
+	}
+	for _, entry := range changed {
+		if !slices.Contains(managers, entry.Manager) {
+			return false
+		}
+	}
+	return true
+}
+
+func newer(t, than *metav1.Time) bool {
+	if t == nil {
+		return false
+	}
+	return than == nil || than.Before(t)
+}
+
+func managedFieldsKey(entry metav1.ManagedFieldsEntry) string {
+	return entry.Manager + "/" + string(entry.Operation) + "/" + entry.Subresource
+}
@@ -0,0 +1,135 @@
+package watcher
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+)
+
+const defaultUserAgent = "k8s-resource-watcher"
+
+// LoadRestConfig builds the client config for the given auth mode, see
+// config.AuthModeAuto, config.AuthModeKubeconfig and config.AuthModeInCluster.
+func LoadRestConfig(mode string) (*rest.Config, error) {
+	switch mode {
+	case config.AuthModeInCluster:
+		restConfig, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("in-cluster config: %w", err)
+		}
+		return restConfig, nil
+	case config.AuthModeKubeconfig:
+		path, ok := kubeconfigPath()
+		if !ok {
+			return nil, fmt.Errorf("kubeconfig: neither $KUBECONFIG is set nor %s exists", path)
+		}
+		return loadKubeconfig(path)
+	case "", config.AuthModeAuto:
+		// A kubeconfig that is present but broken is an error rather than a
+		// reason to silently fall back to the service account.
+		if path, ok := kubeconfigPath(); ok {
+			return loadKubeconfig(path)
+		}
+		restConfig, err := rest.InClusterConfig()
+		if err != nil {
+			return nil, fmt.Errorf("no kubeconfig found ($KUBECONFIG is unset, %s does not exist) and in-cluster config failed: %w",
+				defaultKubeconfigPath(), err)
+		}
+		return restConfig, nil
+	}
+	return nil, fmt.Errorf("unknown auth mode %q, expected auto, kubeconfig or in-cluster", mode)
+}
+
+// kubeconfigPath returns $KUBECONFIG or ~/.kube/config and whether it is usable.
+func kubeconfigPath() (string, bool) {
+	if path := os.Getenv("KUBECONFIG"); path != "" {
+		return path, true
+	}
+	path := defaultKubeconfigPath()
+	_, err := os.Stat(path)
+	return path, err == nil
+}
+
+func defaultKubeconfigPath() string {
+	homeDir, _ := os.UserHomeDir()
+	return filepath.Join(homeDir, ".kube", "config")
+}
+
+func loadKubeconfig(path string) (*rest.Config, error) {
+	restConfig, err := clientcmd.BuildConfigFromFlags("", path)
+	if err != nil {
+		return nil, fmt.Errorf("kubeconfig %s: %w", path, err)
+	}
+	return restConfig, nil
+}
+
+// applyClientConfig returns a copy of restConfig with the configured client settings.
+func applyClientConfig(restConfig *rest.Config, cfg config.ClientConfig) (*rest.Config, error) {
+	restConfig = rest.CopyConfig(restConfig)
+	if cfg.QPS > 0 {
+		restConfig.QPS = cfg.QPS
+	}
+	if cfg.Burst > 0 {
+		restConfig.Burst = cfg.Burst
+	}
+	if cfg.Timeout > 0 {
+		restConfig.Timeout = cfg.Timeout
+	}
+	if cfg.Impersonate.User != "" || len(cfg.Impersonate.Groups) > 0 {
+		restConfig.Impersonate = rest.ImpersonationConfig{
+			UserName: cfg.Impersonate.User,
+			UID:      cfg.Impersonate.UID,
+			Groups:   cfg.Impersonate.Groups,
+			Extra:    cfg.Impersonate.Extra,
+		}
+	}
+	restConfig.UserAgent = cfg.UserAgent
+	if restConfig.UserAgent == "" {
+		restConfig.UserAgent = defaultUserAgent
+	}
+	applyTLSConfig(&restConfig.TLSClientConfig, cfg.TLS)
+	if cfg.ProxyURL != "" {
+		proxyURL, err := url.Parse(cfg.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		restConfig.Proxy = http.ProxyURL(proxyURL)
+	}
+	return restConfig, nil
+}
+
+// enableWatchList makes reflectors stream their initial objects. client-go
+// only offers the environment variable to turn it on for shared informers,
+// so it applies to all informers of the process.
+func enableWatchList() error {
+	return os.Setenv("ENABLE_CLIENT_GO_WATCH_LIST_ALPHA", "true")
+}
+
+func applyTLSConfig(tlsConfig *rest.TLSClientConfig, cfg config.ClientTLSConfig) {
+	if cfg.CAFile != "" {
+		tlsConfig.CAFile = cfg.CAFile
+		tlsConfig.CAData = nil
+	}
+	if cfg.CertFile != "" || cfg.KeyFile != "" {
+		tlsConfig.CertFile = cfg.CertFile
+		tlsConfig.KeyFile = cfg.KeyFile
+		tlsConfig.CertData = nil
+		tlsConfig.KeyData = nil
+	}
+	if cfg.ServerName != "" {
+		tlsConfig.ServerName = cfg.ServerName
+	}
+	if cfg.InsecureSkipVerify {
+		// client-go rejects a CA together with the insecure flag.
+		tlsConfig.Insecure = true
+		tlsConfig.CAFile = ""
+		tlsConfig.CAData = nil
+	}
+}
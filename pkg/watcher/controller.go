@@ -0,0 +1,887 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slog"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+	"github.com/fl64/k8s-resource-watcher/pkg/filter"
+	"github.com/fl64/k8s-resource-watcher/pkg/logging"
+	"github.com/fl64/k8s-resource-watcher/pkg/metrics"
+	"github.com/fl64/k8s-resource-watcher/pkg/ratelimit"
+)
+
+type ResourceControllerInterface interface {
+	GetGVR() schema.GroupVersionResource
+	IsMetadataOnly() bool
+	IsTyped() bool
+	ResyncPeriod() time.Duration
+	Transform(interface{}) (interface{}, error)
+	// AddFunc is told whether obj is part of the initial list.
+	AddFunc(interface{}, bool)
+	UpdateFunc(interface{}, interface{})
+	DeleteFunc(interface{})
+	// Synced is called with the cached objects after the initial list.
+	Synced([]interface{})
+	Flush()
+	// Release frees the resources shared with other controllers once the
+	// resource is no longer watched.
+	Release()
+}
+
+type ResourceController struct {
+	GVR                schema.GroupVersionResource
+	Logger             *slog.Logger
+	filter             *filter.Filter
+	conditions         *filter.Conditions
+	tenant             string
+	namespaces         []string
+	scope              string
+	namespaceSelector  labels.Selector
+	namespaceLabels    *namespaceLabels
+	includeAnnotations []string
+	excludeAnnotations []string
+	ignoreManagers     []string
+	excludeOwnerKinds  []string
+	metadataOnly       bool
+	typed              bool
+	stripManagedFields bool
+	stripLastApplied   bool
+	includeOldObject   bool
+	owners             *ownerResolver
+	impact             *impactResolver
+	groups             []config.GroupConfig
+	changedBy          bool
+	debouncer          *debouncer
+	recreates          *recreateTracker
+	initialSummary     bool
+	flapping           *flapDetector
+	limiter            *ratelimit.Limiter
+	queue              *EventQueue
+	runner             *resourceRunner
+	changes            string
+	transformer        *filter.Transformer
+	script             *filter.Script
+	webhook            *filter.Webhook
+	resync             time.Duration
+	logObjects         bool
+	textDiff           bool
+	maxPayloadSize     int
+	oversize           string
+	dedupEvents        bool
+	correlateEvents    bool
+	imageChanges       bool
+	scaleChanges       bool
+	nodeLifecycle      bool
+	expiry             *expiryChecker
+	gauges             []objectGauge
+	// ctx is cancelled when the watcher stops, so that rate limit waits and
+	// transform webhook calls do not hold up the shutdown.
+	ctx context.Context
+	// started bounds the Add events whose lag is observed.
+	started time.Time
+}
+
+// ResourceControllerOptions holds the per-resource settings of a controller.
+type ResourceControllerOptions struct {
+	Filter *filter.Filter
+	// Conditions select events by field values, nil disables them.
+	Conditions *filter.Conditions
+	// Tenant is set on the events, the namespace filters are the tenant's.
+	Tenant     string
+	Namespaces []string
+	// Scope "namespaced" drops cluster-scoped objects, "cluster" namespaced ones.
+	Scope string
+	// NamespaceSelector adds the namespaces whose labels, looked up in
+	// NamespaceLabels, match. Nil only watches Namespaces.
+	NamespaceSelector labels.Selector
+	NamespaceLabels   *namespaceLabels
+	// IncludeAnnotations and ExcludeAnnotations select objects by "key=value"
+	// or "key" annotations.
+	IncludeAnnotations []string
+	ExcludeAnnotations []string
+	// IgnoreManagers skips updates made only by these field managers.
+	IgnoreManagers []string
+	// ExcludeOwnerKinds skips objects with an owner of these kinds.
+	ExcludeOwnerKinds []string
+	MetadataOnly      bool
+	// Typed objects are delivered by a typed informer.
+	Typed              bool
+	StripManagedFields bool
+	StripLastApplied   bool
+	// IncludeOldObject adds the filtered previous object to Update events.
+	IncludeOldObject bool
+	// Owners resolves the root owner of every event, nil disables it.
+	Owners *ownerResolver
+	// Impact adds the workloads using a ConfigMap or Secret, nil disables it.
+	Impact *impactResolver
+	// Groups correlates events by label or owner.
+	Groups []config.GroupConfig
+	// ChangedBy attributes Update events to a field manager.
+	ChangedBy bool
+	Debounce  time.Duration
+	Flapping  config.FlappingConfig
+	RateLimit config.RateLimitConfig
+	Queue     *EventQueue
+	// RecreateWindow reports a Delete followed by an Add of the same object
+	// within it as one Recreated event, zero disables it.
+	RecreateWindow time.Duration
+	// Context bounds rate limit waits and transform webhook calls, nil
+	// never cancels them.
+	Context context.Context
+	// InitialSync replaces the Add events of the initial list with a summary
+	// when set to config.InitialSyncSummary.
+	InitialSync string
+	// Runner handles informer notifications on the shared workers, nil
+	// handles them on the informer goroutine.
+	Runner *resourceRunner
+	// Changes restricts Update events to spec or status changes, see config.ChangesSpec and config.ChangesStatus.
+	Changes string
+	// Transformer reshapes the filtered payload before it is queued.
+	Transformer *filter.Transformer
+	// Script can drop, modify or annotate events before the transform.
+	Script *filter.Script
+	// Webhook lets an external endpoint mutate or drop events after the script.
+	Webhook *filter.Webhook
+	// Resync emits a Resync event for every object with this period, zero disables it.
+	Resync time.Duration
+	// TextDiff adds a unified diff of the filtered objects to Update events.
+	TextDiff bool
+	// LogObjects adds the payload to the debug log of queued events.
+	LogObjects bool
+	// MaxPayloadSize truncates or summarizes larger events, see filter.LimitSize.
+	MaxPayloadSize int
+	Oversize       string
+	// DedupEvents drops updates of Kubernetes Events that only count another occurrence.
+	DedupEvents bool
+	// CorrelateEvents adds the involved object of Kubernetes Events.
+	CorrelateEvents bool
+	// ImageChanges emits ImageChanged events for updates of container images.
+	ImageChanges bool
+	// ScaleChanges emits Scaled events for updates of the replica count.
+	// Other events are dropped when either is set.
+	ScaleChanges bool
+	// NodeLifecycle replaces the updates of Nodes with lifecycle events.
+	NodeLifecycle bool
+	// CertificateExpiry warns about certificates that expire soon.
+	CertificateExpiry config.CertificateExpiryConfig
+	// Gauges export fields of the objects as Prometheus gauges.
+	Gauges []objectGauge
+}
+
+func NewResourceController(
+	group, version, resource string,
+	logger *slog.Logger,
+	opts ResourceControllerOptions,
+) *ResourceController {
+	rc := &ResourceController{
+		GVR:                schema.GroupVersionResource{Group: group, Version: version, Resource: resource},
+		Logger:             logger.With("group", group).With("version", version, "kind", resource),
+		filter:             opts.Filter,
+		conditions:         opts.Conditions,
+		tenant:             opts.Tenant,
+		namespaces:         opts.Namespaces,
+		scope:              opts.Scope,
+		namespaceSelector:  opts.NamespaceSelector,
+		namespaceLabels:    opts.NamespaceLabels,
+		includeAnnotations: opts.IncludeAnnotations,
+		excludeAnnotations: opts.ExcludeAnnotations,
+		ignoreManagers:     opts.IgnoreManagers,
+		excludeOwnerKinds:  opts.ExcludeOwnerKinds,
+		metadataOnly:       opts.MetadataOnly,
+		typed:              opts.Typed,
+		stripManagedFields: opts.StripManagedFields,
+		stripLastApplied:   opts.StripLastApplied,
+		includeOldObject:   opts.IncludeOldObject,
+		owners:             opts.Owners,
+		impact:             opts.Impact,
+		groups:             opts.Groups,
+		changedBy:          opts.ChangedBy,
+		limiter:            ratelimit.New(opts.RateLimit),
+		queue:              opts.Queue,
+		runner:             opts.Runner,
+		changes:            opts.Changes,
+		transformer:        opts.Transformer,
+		script:             opts.Script,
+		webhook:            opts.Webhook,
+		resync:             opts.Resync,
+		logObjects:         opts.LogObjects,
+		textDiff:           opts.TextDiff,
+		maxPayloadSize:     opts.MaxPayloadSize,
+		oversize:           opts.Oversize,
+		dedupEvents:        opts.DedupEvents,
+		correlateEvents:    opts.CorrelateEvents,
+		imageChanges:       opts.ImageChanges,
+		scaleChanges:       opts.ScaleChanges,
+		nodeLifecycle:      opts.NodeLifecycle,
+		gauges:             opts.Gauges,
+		initialSummary:     opts.InitialSync == config.InitialSyncSummary,
+		ctx:                opts.Context,
+		started:            time.Now(),
+	}
+	if rc.ctx == nil {
+		rc.ctx = context.Background()
+	}
+	rc.flapping = newFlapDetector(opts.Flapping.Threshold, opts.Flapping.Window)
+	rc.expiry = newExpiryChecker(opts.CertificateExpiry.Window, opts.CertificateExpiry.Interval)
+	if opts.Debounce > 0 {
+		rc.debouncer = newDebouncer(opts.Debounce, rc.runner.Run, rc.emitUpdate)
+	}
+	if opts.RecreateWindow > 0 {
+		rc.recreates = newRecreateTracker(opts.RecreateWindow, rc.runner.Run, func(obj *unstructured.Unstructured) {
+			rc.handleEvent("Delete", nil, obj)
+		})
+	}
+	return rc
+}
+
+// NamespaceMatches applies the scope and the namespace filters. Namespace
+// filters do not apply to cluster-scoped objects, which are in no namespace.
+func (rc *ResourceController) NamespaceMatches(unstructuredObj *unstructured.Unstructured) bool {
+	namespaced := unstructuredObj.GetNamespace() != ""
+	if !inScope(rc.scope, namespaced) {
+		return false
+	}
+	if !namespaced {
+		return true
+	}
+	if len(rc.namespaces) == 0 && rc.namespaceSelector == nil {
+		return true
+	}
+	for _, ns := range rc.namespaces {
+		if unstructuredObj.GetNamespace() == ns {
+			return true
+		}
+	}
+	return rc.namespaceSelector != nil && rc.namespaceLabels.Matches(rc.namespaceSelector, unstructuredObj.GetNamespace())
+}
+
+// matches applies the object level filters.
+func (rc *ResourceController) matches(obj *unstructured.Unstructured) bool {
+	return rc.NamespaceMatches(obj) && rc.AnnotationsMatch(obj) && rc.OwnerMatches(obj)
+}
+
+// OwnerMatches reports whether obj has no owner of an excluded kind.
+func (rc *ResourceController) OwnerMatches(obj *unstructured.Unstructured) bool {
+	for _, ref := range obj.GetOwnerReferences() {
+		if slices.Contains(rc.excludeOwnerKinds, ref.Kind) {
+			return false
+		}
+	}
+	return true
+}
+
+// AnnotationsMatch reports whether obj has all included and none of the
+// excluded annotations.
+func (rc *ResourceController) AnnotationsMatch(obj *unstructured.Unstructured) bool {
+	annotations := obj.GetAnnotations()
+	for _, selector := range rc.includeAnnotations {
+		if !annotationMatches(annotations, selector) {
+			return false
+		}
+	}
+	for _, selector := range rc.excludeAnnotations {
+		if annotationMatches(annotations, selector) {
+			return false
+		}
+	}
+	return true
+}
+
+// annotationMatches matches "key=value" or "key" against annotations.
+func annotationMatches(annotations map[string]string, selector string) bool {
+	key, value, hasValue := strings.Cut(selector, "=")
+	actual, ok := annotations[key]
+	return ok && (!hasValue || actual == value)
+}
+
+// ResourceController methods
+
+func (rc *ResourceController) GetGVR() schema.GroupVersionResource {
+	return rc.GVR
+}
+
+func (rc *ResourceController) IsMetadataOnly() bool {
+	return rc.metadataOnly
+}
+
+func (rc *ResourceController) IsTyped() bool {
+	return rc.typed
+}
+
+// ResyncPeriod is the resync period, or the interval of certificate expiry
+// checks when no resync is set.
+func (rc *ResourceController) ResyncPeriod() time.Duration {
+	if rc.resync == 0 && rc.expiry != nil {
+		return rc.expiry.interval
+	}
+	return rc.resync
+}
+
+// Transform strips heavy metadata from objects before they enter the informer cache.
+func (rc *ResourceController) Transform(obj interface{}) (interface{}, error) {
+	if !rc.stripManagedFields && !rc.stripLastApplied {
+		return obj, nil
+	}
+	metaObj, err := meta.Accessor(obj)
+	if err != nil {
+		// Not an object (e.g. a tombstone), keep it as is.
+		return obj, nil
+	}
+	if rc.stripManagedFields {
+		metaObj.SetManagedFields(nil)
+	}
+	if rc.stripLastApplied {
+		if annotations := metaObj.GetAnnotations(); annotations != nil {
+			if _, ok := annotations[corev1.LastAppliedConfigAnnotation]; ok {
+				delete(annotations, corev1.LastAppliedConfigAnnotation)
+				metaObj.SetAnnotations(annotations)
+			}
+		}
+	}
+	return obj, nil
+}
+
+// toUnstructured converts objects delivered by the dynamic, metadata or typed
+// informer into the unstructured form used by the filters.
+func (rc *ResourceController) toUnstructured(obj interface{}) *unstructured.Unstructured {
+	switch o := obj.(type) {
+	case *unstructured.Unstructured:
+		return o
+	case *metav1.PartialObjectMetadata:
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(o)
+		if err != nil {
+			rc.Logger.Error("Failed to convert object metadata", "error", err)
+			return nil
+		}
+		return &unstructured.Unstructured{Object: content}
+	case runtime.Object:
+		if rc.typed {
+			content, err := typedToUnstructured(o, rc.GVR)
+			if err != nil {
+				rc.Logger.Error("Failed to convert object", "error", err)
+				return nil
+			}
+			return &unstructured.Unstructured{Object: content}
+		}
+	}
+	rc.Logger.Error("Unexpected object type", "type", reflect.TypeOf(obj).String())
+	return nil
+}
+
+func (rc *ResourceController) AddFunc(obj interface{}, isInInitialList bool) {
+	rc.runner.Run(obj, func() { rc.add(obj, isInInitialList) })
+}
+
+func (rc *ResourceController) add(obj interface{}, isInInitialList bool) {
+	objUnstructured := rc.toUnstructured(obj)
+	if objUnstructured == nil {
+		return
+	}
+	if !rc.matches(objUnstructured) {
+		return
+	}
+	rc.observeGauges(objUnstructured)
+	// The initial list is reported by Synced instead.
+	if !rc.detectsChanges() && !(isInInitialList && rc.initialSummary) {
+		if deleted, ok := rc.recreated(objUnstructured); ok {
+			rc.handleEvent(event.TypeRecreated, deleted, objUnstructured)
+		} else {
+			rc.handleEvent("Add", nil, objUnstructured)
+		}
+	}
+	rc.checkExpiry(objUnstructured)
+}
+
+func (rc *ResourceController) UpdateFunc(oldObj, newObj interface{}) {
+	rc.runner.Run(newObj, func() { rc.update(oldObj, newObj) })
+}
+
+func (rc *ResourceController) update(oldObj, newObj interface{}) {
+	// Typed objects are only converted for real updates, resyncs and relists
+	// that deliver the same objects again are skipped first.
+	if rc.typed && rc.ResyncPeriod() == 0 && sameResourceVersion(oldObj, newObj) {
+		return
+	}
+	oldUnstructured := rc.toUnstructured(oldObj)
+	newUnstructured := rc.toUnstructured(newObj)
+	if oldUnstructured == nil || newUnstructured == nil {
+		return
+	}
+	// Objects that drop their opt-in annotation are no longer reported.
+	if !rc.matches(newUnstructured) {
+		rc.forgetGauges(newUnstructured)
+		return
+	}
+	rc.observeGauges(newUnstructured)
+	rc.checkExpiry(newUnstructured)
+	// Resyncs and relists deliver the same object again, only real updates count.
+	if oldUnstructured.GetResourceVersion() == newUnstructured.GetResourceVersion() {
+		if rc.resync > 0 {
+			rc.handleEvent(event.TypeResync, nil, newUnstructured)
+		}
+		return
+	}
+	// A relist after a missed deletion delivers the object created again
+	// under the same name as an update of the deleted one.
+	if isRecreation(oldUnstructured, newUnstructured) {
+		if rc.debouncer != nil {
+			rc.debouncer.Flush(objectKey(oldUnstructured))
+		}
+		if !rc.detectsChanges() {
+			rc.handleEvent(event.TypeRecreated, oldUnstructured, newUnstructured)
+		}
+		return
+	}
+	if rc.dedupEvents && isRecurrence(oldUnstructured, newUnstructured) {
+		return
+	}
+	if len(rc.ignoreManagers) > 0 && onlyManagers(oldUnstructured, newUnstructured, rc.ignoreManagers) {
+		return
+	}
+	if rc.flapping != nil {
+		rc.observeFlapping(oldUnstructured, newUnstructured)
+	}
+	if rc.debouncer != nil {
+		rc.debouncer.Add(objectKey(newUnstructured), oldUnstructured, newUnstructured)
+		return
+	}
+	rc.emitUpdate(oldUnstructured, newUnstructured)
+}
+
+// isRecreation reports whether newObj replaced oldObj under the same name,
+// i.e. has another UID.
+func isRecreation(oldObj, newObj *unstructured.Unstructured) bool {
+	return oldObj.GetUID() != "" && newObj.GetUID() != "" && oldObj.GetUID() != newObj.GetUID()
+}
+
+func sameResourceVersion(oldObj, newObj interface{}) bool {
+	oldMeta, err := meta.Accessor(oldObj)
+	if err != nil {
+		return false
+	}
+	newMeta, err := meta.Accessor(newObj)
+	if err != nil {
+		return false
+	}
+	return oldMeta.GetResourceVersion() == newMeta.GetResourceVersion()
+}
+
+func (rc *ResourceController) observeFlapping(oldObj, newObj *unstructured.Unstructured) {
+	updates, managers, started := rc.flapping.Observe(objectKey(newObj), updateManager(oldObj, newObj))
+	if !started {
+		return
+	}
+	metrics.FlappingEventsTotal.WithLabelValues(rc.GVR.Group, rc.GVR.Version, rc.GVR.Resource).Inc()
+	rc.Logger.Warn("Object is flapping", "name", newObj.GetName(), "namespace", newObj.GetNamespace(), "updates", updates, "managers", managers)
+	rc.queue.Push(event.Event{
+		SchemaVersion: event.SchemaVersion,
+		Tenant:        rc.tenant,
+		GVR:           event.NewGVR(rc.GVR),
+		Namespace:     newObj.GetNamespace(),
+		Name:          newObj.GetName(),
+		UID:           string(newObj.GetUID()),
+		Type:          event.TypeFlapping,
+		Timestamp:     time.Now().UTC(),
+		Object:        rc.filterObject(newObj).Object,
+		Annotations: map[string]string{
+			"flapping.updates":  strconv.Itoa(updates),
+			"flapping.window":   rc.flapping.window.String(),
+			"flapping.managers": joinManagers(managers),
+		},
+	})
+}
+
+func (rc *ResourceController) emitUpdate(oldObj, newObj *unstructured.Unstructured) {
+	if !rc.changesMatch(oldObj, newObj) {
+		return
+	}
+	if rc.nodeLifecycle {
+		for _, change := range nodeChanges(oldObj, newObj) {
+			rc.handleEvent(change.eventType, oldObj, newObj)
+		}
+		return
+	}
+	// The images and replicas may be filtered out of the payload.
+	if rc.detectsChanges() {
+		if rc.imageChanges && len(imageChanges(oldObj, newObj)) > 0 {
+			rc.handleEvent(event.TypeImageChanged, oldObj, newObj)
+		}
+		if rc.scaleChanges && scaleChange(oldObj, newObj) != nil {
+			rc.handleEvent(event.TypeScaled, oldObj, newObj)
+		}
+		return
+	}
+	if !reflect.DeepEqual(rc.filterObject(oldObj), rc.filterObject(newObj)) {
+		rc.handleEvent("Update", oldObj, newObj)
+	}
+}
+
+// detectsChanges reports whether only the events of detected image or scale
+// changes are emitted.
+func (rc *ResourceController) detectsChanges() bool {
+	return rc.imageChanges || rc.scaleChanges
+}
+
+// checkExpiry emits a CertificateExpiring event when the certificate of obj
+// expires within the configured window.
+func (rc *ResourceController) checkExpiry(obj *unstructured.Unstructured) {
+	if rc.expiry == nil {
+		return
+	}
+	if cert, ok := certificateOf(obj); ok && rc.expiry.Check(objectKey(obj), cert.notAfter, time.Now()) {
+		rc.handleEvent(event.TypeCertificateExpiring, nil, obj)
+	}
+}
+
+func (rc *ResourceController) DeleteFunc(obj interface{}) {
+	rc.runner.Run(obj, func() { rc.delete(obj) })
+}
+
+func (rc *ResourceController) delete(obj interface{}) {
+	// The informer missed the deletion, use the last known state from the tombstone.
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	objUnstructured := rc.toUnstructured(obj)
+	if objUnstructured == nil {
+		return
+	}
+	if rc.expiry != nil {
+		rc.expiry.Forget(objectKey(objUnstructured))
+	}
+	rc.forgetGauges(objUnstructured)
+	if rc.matches(objUnstructured) && !rc.detectsChanges() {
+		if rc.debouncer != nil {
+			// Emit the coalesced update before the object goes away.
+			rc.debouncer.Flush(objectKey(objUnstructured))
+		}
+		if rc.recreates != nil {
+			rc.recreates.Delete(objectKey(objUnstructured), objUnstructured)
+			return
+		}
+		rc.handleEvent("Delete", nil, objUnstructured)
+	}
+}
+
+// recreated returns the deleted object of obj when obj was deleted within the
+// recreate window.
+func (rc *ResourceController) recreated(obj *unstructured.Unstructured) (*unstructured.Unstructured, bool) {
+	if rc.recreates == nil {
+		return nil, false
+	}
+	return rc.recreates.Add(objectKey(obj))
+}
+
+// changesMatch reports whether an update is a desired state (spec) or observed
+// state (status) change, as requested by the changes option. Desired state
+// changes are detected by an increased metadata.generation; resources that do
+// not track a generation fall back to comparing spec.
+func (rc *ResourceController) changesMatch(oldObj, newObj *unstructured.Unstructured) bool {
+	specChanged := newObj.GetGeneration() > oldObj.GetGeneration()
+	if oldObj.GetGeneration() == 0 && newObj.GetGeneration() == 0 {
+		specChanged = !reflect.DeepEqual(oldObj.Object["spec"], newObj.Object["spec"])
+	}
+	switch rc.changes {
+	case config.ChangesSpec:
+		return specChanged
+	case config.ChangesStatus:
+		return !reflect.DeepEqual(oldObj.Object["status"], newObj.Object["status"])
+	}
+	return true
+}
+
+// Flush emits all updates still held back by the debouncer and the
+// deletions held back for the recreate window.
+func (rc *ResourceController) Flush() {
+	if rc.debouncer != nil {
+		rc.debouncer.FlushAll()
+	}
+	if rc.recreates != nil {
+		rc.recreates.FlushAll()
+	}
+}
+
+// Release removes the resource from the handler scheduler.
+func (rc *ResourceController) Release() {
+	rc.runner.Release()
+}
+
+func (rc *ResourceController) filterObject(obj *unstructured.Unstructured) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: rc.filter.Apply(obj.Object)}
+}
+
+func (rc *ResourceController) handleEvent(eventType string, oldObj, unstructuredObj *unstructured.Unstructured) {
+	ctx := context.Background()
+	var oldRaw map[string]interface{}
+	if oldObj != nil {
+		oldRaw = oldObj.Object
+	}
+	if !rc.conditions.Match(eventType, oldRaw, unstructuredObj.Object) {
+		return
+	}
+	filteredObj := rc.filterObject(unstructuredObj)
+	ev := event.Event{
+		SchemaVersion: event.SchemaVersion,
+		Tenant:        rc.tenant,
+		GVR:           event.NewGVR(rc.GVR),
+		Namespace:     unstructuredObj.GetNamespace(),
+		Name:          unstructuredObj.GetName(),
+		UID:           string(unstructuredObj.GetUID()),
+		Type:          eventType,
+		Timestamp:     time.Now().UTC(),
+		Object:        filteredObj.Object,
+	}
+	if eventType == event.TypeRecreated {
+		ev.PreviousUID = string(oldObj.GetUID())
+	}
+	var filteredOld map[string]interface{}
+	if oldObj != nil {
+		filteredOld = rc.filterObject(oldObj).Object
+		ev.Diff = filter.Diff(filteredOld, filteredObj.Object)
+		if rc.textDiff {
+			textDiff, err := filter.TextDiff(objectKey(unstructuredObj), filteredOld, filteredObj.Object)
+			if err != nil {
+				rc.Logger.Error("Failed to render text diff", "name", ev.Name, "error", err)
+			}
+			ev.TextDiff = textDiff
+		}
+	}
+	switch {
+	case eventType == event.TypeImageChanged:
+		ev.Images = imageChanges(oldObj, unstructuredObj)
+	case eventType == event.TypeScaled:
+		ev.Scale = scaleChange(oldObj, unstructuredObj)
+	case rc.nodeLifecycle && oldObj != nil:
+		// The diff is cut down to the reported change.
+		for _, change := range nodeChanges(oldObj, unstructuredObj) {
+			if change.eventType == eventType {
+				ev.Diff = []event.FieldChange{change.change}
+			}
+		}
+	}
+	result, err := rc.script.Run(eventType, filteredOld, filteredObj.Object)
+	if err != nil {
+		rc.Logger.Error("Failed to run script", "eventType", eventType, "name", ev.Name, "error", err)
+		return
+	}
+	if result.Drop {
+		rc.Logger.Debug("Script dropped event", "eventType", eventType, "name", ev.Name, "namespace", ev.Namespace)
+		return
+	}
+	if result.Object != nil {
+		filteredObj.Object = result.Object
+	}
+	ev.Annotations = result.Annotations
+	ev.Object = filteredObj.Object
+	response, err := rc.webhook.Call(rc.ctx, ev, filteredOld)
+	if err != nil {
+		rc.Logger.Error("Transform webhook failed", "eventType", eventType, "name", ev.Name, "error", err)
+		if rc.webhook.DropOnFailure() {
+			return
+		}
+		response = &filter.WebhookResponse{}
+	}
+	if response.Drop {
+		rc.Logger.Debug("Transform webhook dropped event", "eventType", eventType, "name", ev.Name, "namespace", ev.Namespace)
+		return
+	}
+	if response.Object != nil {
+		filteredObj.Object = response.Object
+	}
+	if response.Annotations != nil {
+		ev.Annotations = response.Annotations
+	}
+	if cert, ok := certificateOf(unstructuredObj); ok && eventType == event.TypeCertificateExpiring {
+		ev.Annotations = cert.annotate(ev.Annotations, ev.Timestamp)
+	}
+	payload, err := rc.transformer.Transform(eventType, filteredObj.Object)
+	if err != nil {
+		rc.Logger.Error("Failed to transform event", "eventType", eventType, "name", ev.Name, "error", err)
+		return
+	}
+	if payload == nil {
+		return
+	}
+	ev.Object = payload
+	if rc.includeOldObject {
+		ev.OldObject = filteredOld
+	}
+	if rc.owners != nil {
+		ev.Owner = rc.owners.Resolve(ctx, unstructuredObj)
+	}
+	if rc.impact != nil && rc.GVR.Group == "" {
+		ev.Impacted = rc.impact.Impacted(rc.GVR.Resource, unstructuredObj)
+	}
+	ev.Groups = groupsOf(rc.groups, unstructuredObj, rc.GVR.Resource, ev.Owner)
+	// Scaled events name who scaled, e.g. an HPA through the scale subresource.
+	if (rc.changedBy || eventType == event.TypeScaled) && oldObj != nil {
+		ev.ChangedBy = changedBy(oldObj, unstructuredObj)
+	}
+	if rc.correlateEvents {
+		ev.InvolvedObject = involvedObject(unstructuredObj)
+	}
+	if filter.LimitSize(&ev, rc.maxPayloadSize, rc.oversize) {
+		metrics.TruncatedEventsTotal.WithLabelValues(rc.GVR.Group, rc.GVR.Version, rc.GVR.Resource).Inc()
+	}
+	// Events dropped by the conditions, scripts, webhooks or transforms do
+	// not use up the rate limit.
+	if !rc.limiter.Allow(rc.ctx) {
+		metrics.DroppedEventsTotal.WithLabelValues("resource", rc.GVR.String(), "rate_limit").Inc()
+		return
+	}
+	if rc.Logger.Enabled(ctx, slog.LevelDebug) {
+		args := []any{"eventType", eventType, "name", ev.Name, "namespace", ev.Namespace}
+		if rc.logObjects {
+			args = append(args, "object", ev.Object)
+		}
+		rc.Logger.Debug("Queued event", args...)
+	}
+	rc.observeLag(eventType, oldObj, unstructuredObj, ev.Timestamp)
+	rc.queue.Push(ev)
+}
+
+func objectKey(obj *unstructured.Unstructured) string {
+	if obj.GetNamespace() == "" {
+		return obj.GetName()
+	}
+	return obj.GetNamespace() + "/" + obj.GetName()
+}
+
+// newControllerFromConfig creates a controller for gvr with the common settings
+// merged into the resource entry.
+func newControllerFromConfig(
+	ctx context.Context,
+	cfg *config.Config,
+	resConfig config.ResourceConfig,
+	gvr schema.GroupVersionResource,
+	logger *slog.Logger,
+	queue *EventQueue,
+	owners *ownerResolver,
+	impact *impactResolver,
+	namespaces *namespaceLabels,
+	sched *scheduler,
+) (*ResourceController, error) {
+	resolved := config.Resolve(cfg, resConfig)
+	if resConfig.Log.Level != "" {
+		level, err := logging.ParseLevel(resConfig.Log.Level)
+		if err != nil {
+			return nil, fmt.Errorf("invalid log level: %w", err)
+		}
+		logger = logging.WithLevel(logger, level)
+	}
+	f, err := filter.New(resolved.IncludePaths, resolved.ExcludePaths)
+	if err != nil {
+		return nil, err
+	}
+	conditions, err := filter.NewConditions(resolved.FieldEquals, resolved.FieldChangedTo)
+	if err != nil {
+		return nil, err
+	}
+	transformer, err := filter.NewTransformer(resConfig.Transform)
+	if err != nil {
+		return nil, err
+	}
+	script, err := filter.NewScript(resConfig.Script)
+	if err != nil {
+		return nil, err
+	}
+	if !resolved.ResolveOwners {
+		owners = nil
+	}
+	if !resolved.Impact {
+		impact = nil
+	}
+	namespaceSelector, err := labelSelector(resolved.NamespaceSelector)
+	if err != nil {
+		return nil, fmt.Errorf("invalid namespaceSelector: %w", err)
+	}
+	gauges, err := newObjectGauges(resConfig.Gauges)
+	if err != nil {
+		return nil, err
+	}
+	if resConfig.CertificateExpiry.Window > 0 && resConfig.MetadataOnly {
+		return nil, fmt.Errorf("certificateExpiry reads the certificates, it does not work with metadataOnly")
+	}
+	// gvr is empty when only the settings are validated.
+	if resConfig.Typed && !gvr.Empty() {
+		if err := checkTyped(gvr); err != nil {
+			return nil, err
+		}
+	}
+	return NewResourceController(
+		gvr.Group,
+		gvr.Version,
+		gvr.Resource,
+		logger,
+		ResourceControllerOptions{
+			Filter:             f,
+			Conditions:         conditions,
+			Tenant:             resConfig.Tenant,
+			Namespaces:         resolved.Namespaces,
+			Scope:              resolved.Scope,
+			NamespaceSelector:  namespaceSelector,
+			NamespaceLabels:    namespaces,
+			IncludeAnnotations: resolved.IncludeAnnotations,
+			ExcludeAnnotations: resolved.ExcludeAnnotations,
+			IgnoreManagers:     resolved.IgnoreManagers,
+			ExcludeOwnerKinds:  resolved.ExcludeOwnerKinds,
+			MetadataOnly:       resConfig.MetadataOnly,
+			Typed:              resConfig.Typed,
+			StripManagedFields: resolved.StripManagedFields,
+			StripLastApplied:   resolved.StripLastAppliedAnnotation,
+			IncludeOldObject:   resolved.IncludeOldObject,
+			Owners:             owners,
+			Impact:             impact,
+			Groups:             cfg.Groups,
+			ChangedBy:          resolved.ChangedBy,
+			Debounce:           resolved.Debounce,
+			RecreateWindow:     resolved.RecreateWindow,
+			InitialSync:        resolved.InitialSync,
+			Context:            ctx,
+			Flapping:           resolved.Flapping,
+			RateLimit:          resolved.RateLimit,
+			Queue:              queue,
+			Runner:             sched.runner(gvr, resolved.Workers),
+			Changes:            resolved.Changes,
+			Transformer:        transformer,
+			Script:             script,
+			Webhook:            filter.NewWebhook(resConfig.TransformWebhook),
+			Resync:             durationValue(resolved.Resync),
+			LogObjects:         !resConfig.Log.OmitObjects,
+			TextDiff:           resolved.TextDiff,
+			MaxPayloadSize:     resolved.MaxSizeBytes,
+			Oversize:           resolved.Oversize,
+			DedupEvents:        resConfig.KubernetesEvents.Dedup,
+			CorrelateEvents:    resConfig.KubernetesEvents.Correlate,
+			ImageChanges:       resConfig.ImageChanges,
+			ScaleChanges:       resConfig.ScaleChanges,
+			NodeLifecycle:      resConfig.NodeLifecycle,
+			CertificateExpiry:  resConfig.CertificateExpiry,
+			Gauges:             gauges,
+		},
+	), nil
+}
+
+func durationValue(d *time.Duration) time.Duration {
+	if d == nil {
+		return 0
+	}
+	return *d
+}
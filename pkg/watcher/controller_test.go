@@ -0,0 +1,48 @@
+package watcher
+
+import (
+	"io"
+	"testing"
+
+	"golang.org/x/exp/slog"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+)
+
+func newTestController(opts ResourceControllerOptions) (*ResourceController, *EventQueue) {
+	queue := NewEventQueue(config.QueueConfig{})
+	opts.Queue = queue
+	return NewResourceController("", "v1", "configmaps", slog.New(slog.NewTextHandler(io.Discard, nil)), opts), queue
+}
+
+func TestDeleteTombstone(t *testing.T) {
+	tests := []struct {
+		name       string
+		namespaces []string
+		want       int
+	}{
+		{name: "matching", want: 1},
+		{name: "filtered namespace", namespaces: []string{"other"}, want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc, queue := newTestController(ResourceControllerOptions{Namespaces: tt.namespaces})
+			obj := testObject("default", "web")
+			obj.SetAPIVersion("v1")
+			obj.SetKind("ConfigMap")
+			// The informer missed the deletion and only knows the last state.
+			rc.DeleteFunc(cache.DeletedFinalStateUnknown{Key: "default/web", Obj: obj})
+			if queue.Len() != tt.want {
+				t.Fatalf("%d events queued, want %d", queue.Len(), tt.want)
+			}
+			if tt.want == 0 {
+				return
+			}
+			ev, _ := queue.Pop()
+			if ev.Type != "Delete" || ev.Namespace != "default" || ev.Name != "web" {
+				t.Errorf("got %s event of %s/%s, want the Delete of default/web", ev.Type, ev.Namespace, ev.Name)
+			}
+		})
+	}
+}
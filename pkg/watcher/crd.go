@@ -0,0 +1,206 @@
+package watcher
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/exp/slog"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+)
+
+var crdGVR = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+
+type crdInformer struct {
+	gvr        schema.GroupVersionResource
+	controller ResourceControllerInterface
+	cancel     context.CancelFunc
+}
+
+// CRDWatcher starts informers for newly established CRDs and stops them when
+// the CRD is removed.
+type CRDWatcher struct {
+	ctx           context.Context
+	cfg           config.CRDAutoWatchConfig
+	logger        *slog.Logger
+	wg            *sync.WaitGroup
+	static        map[schema.GroupVersionResource]bool
+	newController func(gvr schema.GroupVersionResource) (ResourceControllerInterface, error)
+	newInformer   func(controller ResourceControllerInterface) (cache.SharedIndexInformer, error)
+
+	mu      sync.Mutex
+	running map[string]*crdInformer
+}
+
+func NewCRDWatcher(
+	ctx context.Context,
+	cfg config.CRDAutoWatchConfig,
+	logger *slog.Logger,
+	wg *sync.WaitGroup,
+	static []schema.GroupVersionResource,
+	newController func(gvr schema.GroupVersionResource) (ResourceControllerInterface, error),
+	newInformer func(controller ResourceControllerInterface) (cache.SharedIndexInformer, error),
+) *CRDWatcher {
+	w := &CRDWatcher{
+		ctx:           ctx,
+		cfg:           cfg,
+		logger:        logger.With("component", "crd-watcher"),
+		wg:            wg,
+		static:        make(map[schema.GroupVersionResource]bool, len(static)),
+		newController: newController,
+		newInformer:   newInformer,
+		running:       make(map[string]*crdInformer),
+	}
+	for _, gvr := range static {
+		w.static[gvr] = true
+	}
+	return w
+}
+
+func (w *CRDWatcher) AddFunc(obj interface{}) {
+	w.sync(obj)
+}
+
+func (w *CRDWatcher) UpdateFunc(_, newObj interface{}) {
+	w.sync(newObj)
+}
+
+func (w *CRDWatcher) DeleteFunc(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	crd, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stop(crd.GetName())
+}
+
+// Flush flushes the controllers of all running custom resource informers.
+func (w *CRDWatcher) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, running := range w.running {
+		running.controller.Flush()
+	}
+}
+
+func (w *CRDWatcher) sync(obj interface{}) {
+	crd, ok := obj.(*unstructured.Unstructured)
+	if !ok {
+		return
+	}
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	if len(w.cfg.Groups) > 0 && !matchesAnyPattern(w.cfg.Groups, group) {
+		return
+	}
+	scope, _, _ := unstructured.NestedString(crd.Object, "spec", "scope")
+	if !inScope(w.cfg.Resource.Scope, scope == "Namespaced") {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	gvr, ok := crdServedGVR(crd)
+	if !ok || !crdEstablished(crd) {
+		w.stop(crd.GetName())
+		return
+	}
+	if running, ok := w.running[crd.GetName()]; ok {
+		if running.gvr == gvr {
+			return
+		}
+		// The served version changed, restart the informer.
+		w.stop(crd.GetName())
+	}
+	if w.static[gvr] {
+		return
+	}
+	controller, err := w.newController(gvr)
+	if err != nil {
+		w.logger.Error("Failed to create controller for custom resource", "crd", crd.GetName(), "error", err)
+		return
+	}
+	informer, err := w.newInformer(controller)
+	if err != nil {
+		w.logger.Error("Failed to create informer for custom resource", "crd", crd.GetName(), "error", err)
+		return
+	}
+	ctx, cancel := context.WithCancel(w.ctx)
+	w.running[crd.GetName()] = &crdInformer{gvr: gvr, controller: controller, cancel: cancel}
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		informer.Run(ctx.Done())
+	}()
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		if cache.WaitForCacheSync(ctx.Done(), informer.HasSynced) {
+			controller.Synced(informer.GetStore().List())
+		}
+	}()
+	w.logger.Info("Started watching custom resource", "group", gvr.Group, "version", gvr.Version, "kind", gvr.Resource)
+}
+
+// stop must be called with w.mu held.
+func (w *CRDWatcher) stop(name string) {
+	running, ok := w.running[name]
+	if !ok {
+		return
+	}
+	running.cancel()
+	running.controller.Flush()
+	running.controller.Release()
+	delete(w.running, name)
+	w.logger.Info("Stopped watching custom resource", "group", running.gvr.Group, "version", running.gvr.Version, "kind", running.gvr.Resource)
+}
+
+// crdServedGVR returns the resource of the CRD's storage version, falling back
+// to the first served version.
+func crdServedGVR(crd *unstructured.Unstructured) (schema.GroupVersionResource, bool) {
+	group, _, _ := unstructured.NestedString(crd.Object, "spec", "group")
+	plural, _, _ := unstructured.NestedString(crd.Object, "spec", "names", "plural")
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	var version string
+	for _, v := range versions {
+		entry, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _, _ := unstructured.NestedString(entry, "name")
+		served, _, _ := unstructured.NestedBool(entry, "served")
+		storage, _, _ := unstructured.NestedBool(entry, "storage")
+		if !served {
+			continue
+		}
+		if version == "" || storage {
+			version = name
+		}
+		if storage {
+			break
+		}
+	}
+	if plural == "" || version == "" {
+		return schema.GroupVersionResource{}, false
+	}
+	return schema.GroupVersionResource{Group: group, Version: version, Resource: plural}, true
+}
+
+func crdEstablished(crd *unstructured.Unstructured) bool {
+	conditions, _, _ := unstructured.NestedSlice(crd.Object, "status", "conditions")
+	for _, c := range conditions {
+		condition, ok := c.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if condition["type"] == "Established" && condition["status"] == "True" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package watcher
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+type pendingUpdate struct {
+	oldObj *unstructured.Unstructured
+	newObj *unstructured.Unstructured
+	timer  *time.Timer
+}
+
+// debouncer coalesces updates to the same object that arrive within a window
+// into a single update carrying the first old and the last new state. Expired
+// windows are flushed through run, e.g. on the shard of the object, so that
+// they stay in order with the other events of the object.
+type debouncer struct {
+	window  time.Duration
+	run     func(obj interface{}, fn func())
+	flush   func(oldObj, newObj *unstructured.Unstructured)
+	mu      sync.Mutex
+	pending map[string]*pendingUpdate
+}
+
+func newDebouncer(window time.Duration, run func(obj interface{}, fn func()), flush func(oldObj, newObj *unstructured.Unstructured)) *debouncer {
+	return &debouncer{
+		window:  window,
+		run:     run,
+		flush:   flush,
+		pending: make(map[string]*pendingUpdate),
+	}
+}
+
+func (d *debouncer) Add(key string, oldObj, newObj *unstructured.Unstructured) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if p, ok := d.pending[key]; ok {
+		p.newObj = newObj
+		return
+	}
+	p := &pendingUpdate{oldObj: oldObj, newObj: newObj}
+	p.timer = time.AfterFunc(d.window, func() {
+		d.run(newObj, func() { d.expire(key, p) })
+	})
+	d.pending[key] = p
+}
+
+func (d *debouncer) expire(key string, p *pendingUpdate) {
+	d.mu.Lock()
+	// The update may have been flushed early and a new window started.
+	ok := d.pending[key] == p
+	if ok {
+		delete(d.pending, key)
+	}
+	d.mu.Unlock()
+	if ok {
+		d.flush(p.oldObj, p.newObj)
+	}
+}
+
+// Flush emits the pending update for key, if any.
+func (d *debouncer) Flush(key string) {
+	d.mu.Lock()
+	p, ok := d.pending[key]
+	if ok {
+		p.timer.Stop()
+		delete(d.pending, key)
+	}
+	d.mu.Unlock()
+	if ok {
+		d.flush(p.oldObj, p.newObj)
+	}
+}
+
+// FlushAll emits all pending updates.
+func (d *debouncer) FlushAll() {
+	d.mu.Lock()
+	keys := make([]string, 0, len(d.pending))
+	for key := range d.pending {
+		keys = append(keys, key)
+	}
+	d.mu.Unlock()
+	for _, key := range keys {
+		d.Flush(key)
+	}
+}
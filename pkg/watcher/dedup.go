@@ -0,0 +1,156 @@
+package watcher
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+	"github.com/fl64/k8s-resource-watcher/pkg/metrics"
+)
+
+type dedupEntry struct {
+	Hash string    `json:"hash"`
+	Time time.Time `json:"time"`
+}
+
+const defaultDedupSaveInterval = 30 * time.Second
+
+// deduplicator skips events whose payload equals the last payload emitted for
+// the same object within the window. The state can be kept in a file or a
+// ConfigMap or Lease so that the Add events after a restart are deduplicated
+// as well.
+type deduplicator struct {
+	window       time.Duration
+	state        stateStore
+	saveInterval time.Duration
+
+	mu        sync.Mutex
+	entries   map[string]dedupEntry
+	lastSweep time.Time
+	// dirty is set when entries changed since the last save.
+	dirty bool
+}
+
+func newDeduplicator(cfg config.DedupConfig, state stateStore) (*deduplicator, error) {
+	if cfg.Window <= 0 {
+		return nil, nil
+	}
+	d := &deduplicator{window: cfg.Window, state: state, saveInterval: cfg.SaveInterval, entries: make(map[string]dedupEntry), lastSweep: time.Now()}
+	if d.saveInterval <= 0 {
+		d.saveInterval = defaultDedupSaveInterval
+	}
+	if d.state == nil {
+		return d, nil
+	}
+	data, err := d.state.Load(context.Background())
+	if err != nil || data == nil {
+		return d, err
+	}
+	if err := json.Unmarshal(data, &d.entries); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Allow reports whether ev is not a duplicate. Events other than Add, Update
+// and Resync always pass.
+func (d *deduplicator) Allow(ev event.Event) bool {
+	if d == nil {
+		return true
+	}
+	// Tenants watching the same object get their own events.
+	key := ev.Tenant + ":" + ev.GVR.Resource + "." + ev.GVR.Group + "/" + ev.Namespace + "/" + ev.Name
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch ev.Type {
+	case "Delete":
+		if _, ok := d.entries[key]; ok {
+			delete(d.entries, key)
+			d.dirty = true
+		}
+		return true
+	case "Add", "Update", event.TypeResync:
+	default:
+		// Synthetic and detected events, e.g. Flapping or Scaled, report a
+		// change even when the payload stays the same.
+		return true
+	}
+	payload, err := json.Marshal(ev.Object)
+	if err != nil {
+		return true
+	}
+	sum := sha256.Sum256(payload)
+	hash := hex.EncodeToString(sum[:])
+	now := time.Now()
+	d.sweep(now)
+	if entry, ok := d.entries[key]; ok && entry.Hash == hash && now.Sub(entry.Time) < d.window {
+		metrics.DroppedEventsTotal.WithLabelValues("dedup", ev.GVR.Resource, "duplicate").Inc()
+		return false
+	}
+	d.entries[key] = dedupEntry{Hash: hash, Time: now}
+	d.dirty = true
+	return true
+}
+
+func (d *deduplicator) sweep(now time.Time) {
+	if now.Sub(d.lastSweep) < d.window {
+		return
+	}
+	d.lastSweep = now
+	for key, entry := range d.entries {
+		if now.Sub(entry.Time) >= d.window {
+			delete(d.entries, key)
+		}
+	}
+}
+
+// Save writes the state, if a store is configured.
+func (d *deduplicator) Save(ctx context.Context) error {
+	if d == nil || d.state == nil {
+		return nil
+	}
+	d.mu.Lock()
+	// Only entries still within the window are worth keeping.
+	d.lastSweep = time.Time{}
+	d.sweep(time.Now())
+	data, err := json.Marshal(d.entries)
+	d.dirty = false
+	d.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return d.state.Save(ctx, data)
+}
+
+// saveLoop saves the state at most every saveInterval while it changes,
+// until ctx is done.
+func (d *deduplicator) saveLoop(ctx context.Context, logger *slog.Logger) {
+	if d == nil || d.state == nil {
+		return
+	}
+	ticker := time.NewTicker(d.saveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			d.mu.Lock()
+			dirty := d.dirty
+			d.mu.Unlock()
+			if !dirty {
+				continue
+			}
+			if err := d.Save(ctx); err != nil {
+				logger.Error("Failed to save dedup state", "error", err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package watcher
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"golang.org/x/exp/slog"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/restmapper"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+)
+
+const maxSuggestionDistance = 3
+
+// newRESTMapper returns a discovery based mapper that also expands short names
+// like "deploy" or "pvc".
+func newRESTMapper(client discovery.CachedDiscoveryInterface, logger *slog.Logger) meta.RESTMapper {
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(client)
+	return restmapper.NewShortcutExpander(mapper, client, func(msg string) {
+		logger.Warn(msg)
+	})
+}
+
+// resolveGVR resolves a resource entry given by kind, short name or without a
+// version into a full GroupVersionResource. When the version is omitted the
+// server's preferred version is used.
+func resolveGVR(mapper meta.RESTMapper, cfg config.ResourceConfig) (schema.GroupVersionResource, error) {
+	configured := schema.GroupVersionResource{Group: cfg.Group, Version: cfg.Version, Resource: cfg.Resource}
+	if cfg.Kind != "" {
+		var versions []string
+		if cfg.Version != "" {
+			versions = append(versions, cfg.Version)
+		}
+		mapping, err := mapper.RESTMapping(schema.GroupKind{Group: cfg.Group, Kind: cfg.Kind}, versions...)
+		if err != nil {
+			return configured, err
+		}
+		return mapping.Resource, nil
+	}
+	gvr, err := mapper.ResourceFor(configured)
+	if err != nil {
+		return configured, err
+	}
+	return gvr, nil
+}
+
+// validateGVRs checks the configured resources against the API server's
+// discovery info and returns an error for every resource it does not serve.
+func validateGVRs(client discovery.DiscoveryInterface, gvrs []schema.GroupVersionResource) ([]error, error) {
+	_, resourceLists, err := client.ServerGroupsAndResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, err
+	}
+	served := make(map[string][]metav1.APIResource, len(resourceLists))
+	for _, list := range resourceLists {
+		served[list.GroupVersion] = list.APIResources
+	}
+
+	var errs []error
+	for _, gvr := range gvrs {
+		groupVersion := gvr.GroupVersion().String()
+		resources, ok := served[groupVersion]
+		if !ok {
+			candidates := make([]string, 0, len(served))
+			for gv := range served {
+				candidates = append(candidates, gv)
+			}
+			errs = append(errs, fmt.Errorf("group version %q is not served by the cluster%s",
+				groupVersion, formatSuggestions(suggest(groupVersion, candidates))))
+			continue
+		}
+		if findAPIResource(resources, gvr.Resource) != nil {
+			continue
+		}
+		var candidates []string
+		for _, r := range resources {
+			if strings.Contains(r.Name, "/") {
+				continue
+			}
+			// Exact matches on kind, singular or short names are the best suggestion.
+			if strings.EqualFold(r.Kind, gvr.Resource) || r.SingularName == gvr.Resource || contains(r.ShortNames, gvr.Resource) {
+				candidates = []string{r.Name}
+				break
+			}
+			if levenshtein(r.Name, gvr.Resource) <= maxSuggestionDistance {
+				candidates = append(candidates, r.Name)
+			}
+		}
+		sort.Strings(candidates)
+		errs = append(errs, fmt.Errorf("resource %q is not served in %q%s",
+			gvr.Resource, groupVersion, formatSuggestions(candidates)))
+	}
+	return errs, nil
+}
+
+func findAPIResource(resources []metav1.APIResource, name string) *metav1.APIResource {
+	for i := range resources {
+		if resources[i].Name == name {
+			return &resources[i]
+		}
+	}
+	return nil
+}
+
+func suggest(value string, candidates []string) []string {
+	var suggestions []string
+	for _, c := range candidates {
+		if levenshtein(c, value) <= maxSuggestionDistance {
+			suggestions = append(suggestions, c)
+		}
+	}
+	sort.Strings(suggestions)
+	return suggestions
+}
+
+func formatSuggestions(suggestions []string) string {
+	if len(suggestions) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", did you mean %s?", strings.Join(suggestions, ", "))
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// levenshtein returns the edit distance between a and b.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+const wildcard = "*"
+
+func isWildcard(cfg config.ResourceConfig) bool {
+	return cfg.Group == wildcard || cfg.Resource == wildcard
+}
+
+// matchesAnyPattern reports whether value matches one of the glob patterns.
+func matchesAnyPattern(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, value); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// groupAllowed applies the wildcard entry's group allow and deny lists.
+func groupAllowed(cfg config.ResourceConfig, group string) bool {
+	if cfg.Group != wildcard && cfg.Group != group {
+		return false
+	}
+	if len(cfg.IncludeGroups) > 0 && !matchesAnyPattern(cfg.IncludeGroups, group) {
+		return false
+	}
+	return !matchesAnyPattern(cfg.ExcludeGroups, group)
+}
+
+// isWatchable reports whether the resource is a top-level resource supporting list and watch.
+func isWatchable(r metav1.APIResource) bool {
+	return !strings.Contains(r.Name, "/") && contains(r.Verbs, "list") && contains(r.Verbs, "watch")
+}
+
+// scopeOf is the scope of a resource entry, which replaces the common one.
+func scopeOf(common config.CommonConfig, cfg config.ResourceConfig) string {
+	if cfg.Scope != "" {
+		return cfg.Scope
+	}
+	return common.Scope
+}
+
+// inScope reports whether a namespaced or cluster-scoped resource is watched
+// with scope.
+func inScope(scope string, namespaced bool) bool {
+	switch scope {
+	case config.ScopeCluster:
+		return !namespaced
+	case config.ScopeNamespaced:
+		return namespaced
+	}
+	return true
+}
+
+// isNamespaced looks up the scope of gvr, ok is false for unknown resources.
+func isNamespaced(mapper meta.RESTMapper, gvr schema.GroupVersionResource) (namespaced, ok bool) {
+	gvk, err := mapper.KindFor(gvr)
+	if err != nil {
+		return false, false
+	}
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return false, false
+	}
+	return mapping.Scope.Name() == meta.RESTScopeNameNamespace, true
+}
+
+// expandWildcard turns a wildcard entry into one entry per watchable resource
+// of scope in the preferred version of every allowed group.
+func expandWildcard(client discovery.DiscoveryInterface, cfg config.ResourceConfig, scope string) ([]config.ResourceConfig, error) {
+	resourceLists, err := client.ServerPreferredResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, err
+	}
+	var expanded []config.ResourceConfig
+	for _, list := range resourceLists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil || !groupAllowed(cfg, gv.Group) {
+			continue
+		}
+		for _, r := range list.APIResources {
+			if !isWatchable(r) || !inScope(scope, r.Namespaced) {
+				continue
+			}
+			if cfg.Resource != wildcard && cfg.Resource != "" && cfg.Resource != r.Name {
+				continue
+			}
+			entry := cfg
+			entry.Group = gv.Group
+			entry.Version = gv.Version
+			entry.Resource = r.Name
+			entry.Kind = ""
+			expanded = append(expanded, entry)
+		}
+	}
+	return expanded, nil
+}
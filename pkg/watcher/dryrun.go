@@ -0,0 +1,141 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"golang.org/x/exp/slog"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+// Fixture is an object state fed to DryRun. Type is Add, Update or Delete;
+// empty picks Add for objects not seen before and Update otherwise. GVR is
+// guessed from the kind of the object when empty.
+type Fixture struct {
+	Type   string
+	GVR    schema.GroupVersionResource
+	Object map[string]interface{}
+}
+
+type dryRunObject struct {
+	controllers []*ResourceController
+	last        *unstructured.Unstructured
+}
+
+// DryRun feeds fixtures through the filters of the configured resources like
+// informers would, without a cluster, and returns the events they emit.
+// Wildcard entries are not expanded and owners are not resolved. Suppression
+// windows and pauses do not apply.
+func DryRun(cfg *config.Config, fixtures []Fixture, logger *slog.Logger) ([]event.Event, error) {
+	queue := NewEventQueue(config.QueueConfig{})
+	dedup, err := newDeduplicator(config.DedupConfig{Window: cfg.Dedup.Window}, nil)
+	if err != nil {
+		return nil, err
+	}
+	var events []event.Event
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		queue.Run(func(ev event.Event) {
+			if !dedup.Allow(ev) {
+				return
+			}
+			ev.Cluster = cfg.Cluster
+			ev.ClusterMetadata = cfg.ClusterMetadata.Labels
+			events = append(events, ev)
+		})
+	}()
+
+	for _, resConfig := range cfg.Resources {
+		if isWildcard(resConfig) {
+			logger.Warn("Wildcard resource entries are skipped in dry-run", "group", resConfig.Group, "resource", resConfig.Resource)
+		}
+	}
+	controllers := map[string]*ResourceController{}
+	objects := map[string]*dryRunObject{}
+	var errs []error
+	for i, fixture := range fixtures {
+		obj := &unstructured.Unstructured{Object: fixture.Object}
+		gvk := obj.GroupVersionKind()
+		gvr := fixture.GVR
+		if gvr.Resource == "" {
+			if gvk.Kind == "" || gvk.Version == "" {
+				errs = append(errs, fmt.Errorf("fixture %d: apiVersion and kind are required", i+1))
+				continue
+			}
+			gvr, _ = meta.UnsafeGuessKindToResource(gvk)
+		}
+		key := gvr.String() + "/" + objectKey(obj)
+		state, ok := objects[key]
+		if !ok {
+			state = &dryRunObject{}
+			for index, resConfig := range cfg.Resources {
+				if isWildcard(resConfig) || !dryRunMatches(resConfig, gvk, gvr) {
+					continue
+				}
+				controllerKey := strconv.Itoa(index) + "/" + gvr.String()
+				controller, ok := controllers[controllerKey]
+				if !ok {
+					if controller, err = newControllerFromConfig(context.Background(), cfg, resConfig, gvr, logger, queue, nil, nil, nil, nil); err != nil {
+						return nil, fmt.Errorf("invalid resource config for %s: %w", gvr.String(), err)
+					}
+					controllers[controllerKey] = controller
+				}
+				state.controllers = append(state.controllers, controller)
+			}
+			objects[key] = state
+		}
+		if len(state.controllers) == 0 {
+			logger.Debug("Fixture does not match a resource", "kind", gvk.Kind, "name", obj.GetName())
+			continue
+		}
+		// Updates are told apart by their resourceVersion.
+		if obj.GetResourceVersion() == "" {
+			obj.SetResourceVersion(strconv.Itoa(i + 1))
+		}
+		eventType := fixture.Type
+		if eventType == "" || (eventType == "Update" && state.last == nil) {
+			eventType = "Add"
+			if state.last != nil {
+				eventType = "Update"
+			}
+		}
+		for _, controller := range state.controllers {
+			switch eventType {
+			case "Add":
+				controller.AddFunc(obj, false)
+			case "Update":
+				controller.UpdateFunc(state.last, obj)
+			case "Delete":
+				controller.DeleteFunc(obj)
+			}
+		}
+		state.last = obj
+		if eventType == "Delete" {
+			state.last = nil
+		}
+	}
+	for _, controller := range controllers {
+		controller.Flush()
+	}
+	queue.Close()
+	<-done
+	if len(errs) > 0 {
+		return events, fmt.Errorf("%d invalid fixtures: %w", len(errs), errs[0])
+	}
+	return events, nil
+}
+
+// dryRunMatches reports whether resConfig watches objects of gvk and gvr.
+func dryRunMatches(resConfig config.ResourceConfig, gvk schema.GroupVersionKind, gvr schema.GroupVersionResource) bool {
+	if resConfig.Group != gvr.Group || (resConfig.Version != "" && resConfig.Version != gvr.Version) {
+		return false
+	}
+	return (gvk.Kind != "" && resConfig.Kind == gvk.Kind) || resConfig.Resource == gvr.Resource
+}
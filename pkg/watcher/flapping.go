@@ -0,0 +1,108 @@
+package watcher
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// flapDetector counts updates per object in a sliding window and reports when
+// an object starts flapping. An object is reported again only after it calmed down.
+type flapDetector struct {
+	threshold int
+	window    time.Duration
+
+	mu        sync.Mutex
+	objects   map[string]*flapState
+	lastSweep time.Time
+}
+
+type flapState struct {
+	updates  []time.Time
+	managers []string
+	flapping bool
+}
+
+func newFlapDetector(threshold int, window time.Duration) *flapDetector {
+	if threshold <= 0 || window <= 0 {
+		return nil
+	}
+	return &flapDetector{threshold: threshold, window: window, objects: make(map[string]*flapState), lastSweep: time.Now()}
+}
+
+// Observe records an update of obj made by manager. It returns the number of
+// updates in the window and the managers involved when obj just started flapping.
+func (d *flapDetector) Observe(key, manager string) (int, []string, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	now := time.Now()
+	d.sweep(now)
+	state := d.objects[key]
+	if state == nil {
+		state = &flapState{}
+		d.objects[key] = state
+	}
+	state.prune(now.Add(-d.window))
+	state.updates = append(state.updates, now)
+	state.managers = append(state.managers, manager)
+	if len(state.updates) <= d.threshold {
+		state.flapping = false
+		return len(state.updates), nil, false
+	}
+	if state.flapping {
+		return len(state.updates), nil, false
+	}
+	state.flapping = true
+	return len(state.updates), uniqueManagers(state.managers), true
+}
+
+func (s *flapState) prune(cutoff time.Time) {
+	i := 0
+	for i < len(s.updates) && s.updates[i].Before(cutoff) {
+		i++
+	}
+	s.updates = s.updates[i:]
+	s.managers = s.managers[i:]
+}
+
+// sweep forgets objects without updates in the last window.
+func (d *flapDetector) sweep(now time.Time) {
+	if now.Sub(d.lastSweep) < d.window {
+		return
+	}
+	d.lastSweep = now
+	cutoff := now.Add(-d.window)
+	for key, state := range d.objects {
+		if len(state.updates) == 0 || state.updates[len(state.updates)-1].Before(cutoff) {
+			delete(d.objects, key)
+		}
+	}
+}
+
+func uniqueManagers(managers []string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, manager := range managers {
+		if manager != "" && !seen[manager] {
+			seen[manager] = true
+			unique = append(unique, manager)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
+
+// updateManager returns the field manager of an update, see changedBy.
+func updateManager(oldObj, newObj *unstructured.Unstructured) string {
+	if by := changedBy(oldObj, newObj); by != nil {
+		return by.Manager
+	}
+	return ""
+}
+
+func joinManagers(managers []string) string {
+	return strings.Join(managers, ",")
+}
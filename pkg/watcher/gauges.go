@@ -0,0 +1,94 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/filter"
+)
+
+// objectGauge exports a field of the watched objects, a series per object.
+type objectGauge struct {
+	path filter.FieldPath
+	vec  *prometheus.GaugeVec
+}
+
+// newObjectGauges registers the gauges of cfgs. Entries sharing a metric
+// name and help share the gauge, e.g. the versions of a custom resource.
+func newObjectGauges(cfgs []config.GaugeConfig) ([]objectGauge, error) {
+	var gauges []objectGauge
+	for _, cfg := range cfgs {
+		path, err := filter.ParseFieldPath(cfg.Path)
+		if err != nil {
+			return nil, fmt.Errorf("gauge %s: %w", cfg.Name, err)
+		}
+		help := cfg.Help
+		if help == "" {
+			help = "Value of " + cfg.Path + " per object."
+		}
+		vec := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: cfg.Name, Help: help}, []string{"namespace", "name"})
+		if err := prometheus.Register(vec); err != nil {
+			var registered prometheus.AlreadyRegisteredError
+			if !errors.As(err, &registered) {
+				return nil, fmt.Errorf("gauge %s: %w", cfg.Name, err)
+			}
+			if vec, _ = registered.ExistingCollector.(*prometheus.GaugeVec); vec == nil {
+				return nil, fmt.Errorf("gauge %s: metric is already registered", cfg.Name)
+			}
+		}
+		gauges = append(gauges, objectGauge{path: path, vec: vec})
+	}
+	return gauges, nil
+}
+
+// observeGauges sets the series of obj, fields that are missing or not
+// numeric remove them.
+func (rc *ResourceController) observeGauges(obj *unstructured.Unstructured) {
+	for _, gauge := range rc.gauges {
+		value, ok := gaugeValue(gauge.path.Values(obj.Object))
+		if !ok {
+			gauge.vec.DeleteLabelValues(obj.GetNamespace(), obj.GetName())
+			continue
+		}
+		gauge.vec.WithLabelValues(obj.GetNamespace(), obj.GetName()).Set(value)
+	}
+}
+
+func (rc *ResourceController) forgetGauges(obj *unstructured.Unstructured) {
+	for _, gauge := range rc.gauges {
+		gauge.vec.DeleteLabelValues(obj.GetNamespace(), obj.GetName())
+	}
+}
+
+// gaugeValue converts the first value of a field: numbers, bools as 0 or 1
+// and numeric strings or quantities such as "500m" or "2Gi".
+func gaugeValue(values []interface{}) (float64, bool) {
+	if len(values) == 0 {
+		return 0, false
+	}
+	switch v := values[0].(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	case bool:
+		if v {
+			return 1, true
+		}
+		return 0, true
+	case string:
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f, true
+		}
+		if quantity, err := resource.ParseQuantity(v); err == nil {
+			return quantity.AsApproximateFloat64(), true
+		}
+	}
+	return 0, false
+}
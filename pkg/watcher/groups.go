@@ -0,0 +1,43 @@
+package watcher
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+// groupsOf returns the groups obj belongs to. Label groups are keyed by the
+// namespace and label value, owner groups by the root owner or, for objects
+// without one, by obj itself. kind is used for objects of metadata-only
+// resources, which do not carry their own kind.
+func groupsOf(groups []config.GroupConfig, obj *unstructured.Unstructured, kind string, owner *event.Owner) []event.Group {
+	var result []event.Group
+	for _, group := range groups {
+		var key string
+		switch {
+		case group.Label != "":
+			value, ok := obj.GetLabels()[group.Label]
+			if !ok {
+				continue
+			}
+			key = value
+		case group.Owner:
+			if owner != nil {
+				key = owner.Kind + "/" + owner.Name
+			} else {
+				if obj.GetKind() != "" && obj.GetKind() != "PartialObjectMetadata" {
+					kind = obj.GetKind()
+				}
+				key = kind + "/" + obj.GetName()
+			}
+		default:
+			continue
+		}
+		if namespace := obj.GetNamespace(); namespace != "" {
+			key = namespace + "/" + key
+		}
+		result = append(result, event.Group{Name: group.Name, Key: key})
+	}
+	return result
+}
@@ -0,0 +1,68 @@
+package watcher
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+// podSpecPaths are the locations of the pod spec in Pods, workloads with a
+// pod template and CronJobs.
+var podSpecPaths = [][]string{
+	{"spec", "jobTemplate", "spec", "template", "spec"},
+	{"spec", "template", "spec"},
+	{"spec"},
+}
+
+// imageChanges compares the container images of two versions of an object,
+// init containers included.
+func imageChanges(oldObj, newObj *unstructured.Unstructured) []event.ImageChange {
+	oldImages, oldNames := containerImages(oldObj)
+	newImages, newNames := containerImages(newObj)
+	var changes []event.ImageChange
+	for _, name := range newNames {
+		if image := newImages[name]; oldImages[name] != image {
+			changes = append(changes, event.ImageChange{Container: name, Old: oldImages[name], New: image})
+		}
+	}
+	for _, name := range oldNames {
+		if _, found := newImages[name]; !found {
+			changes = append(changes, event.ImageChange{Container: name, Old: oldImages[name]})
+		}
+	}
+	return changes
+}
+
+// containerImages returns the images of the containers of obj by name and the
+// names in spec order.
+func containerImages(obj *unstructured.Unstructured) (map[string]string, []string) {
+	spec := podSpec(obj)
+	if spec == nil {
+		return nil, nil
+	}
+	images := make(map[string]string)
+	var names []string
+	for _, kind := range []string{"initContainers", "containers", "ephemeralContainers"} {
+		for _, container := range nestedMaps(spec, kind) {
+			name, _ := container["name"].(string)
+			image, _ := container["image"].(string)
+			images[name] = image
+			names = append(names, name)
+		}
+	}
+	return images, names
+}
+
+// podSpec returns the pod spec of obj without copying it, nil for objects
+// without one.
+func podSpec(obj *unstructured.Unstructured) map[string]interface{} {
+	for _, path := range podSpecPaths {
+		value, found, _ := unstructured.NestedFieldNoCopy(obj.Object, path...)
+		if spec, ok := value.(map[string]interface{}); found && ok {
+			if _, ok := spec["containers"]; ok {
+				return spec
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,154 @@
+package watcher
+
+import (
+	"slices"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+// impactResolver finds the watched workloads that reference a ConfigMap or
+// Secret, in the informer caches of their resources.
+type impactResolver struct {
+	mu      sync.RWMutex
+	sources []impactSource
+}
+
+type impactSource struct {
+	gvr     schema.GroupVersionResource
+	indexer cache.Indexer
+}
+
+func newImpactResolver() *impactResolver {
+	return &impactResolver{}
+}
+
+// Add makes the objects cached by informer available for lookups. Metadata
+// only resources are skipped, they lack the pod spec.
+func (r *impactResolver) Add(controller ResourceControllerInterface, informer cache.SharedIndexInformer) {
+	if controller.IsMetadataOnly() {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sources = append(r.sources, impactSource{gvr: controller.GetGVR(), indexer: informer.GetIndexer()})
+}
+
+// Impacted returns the workloads in the namespace of obj, a ConfigMap or
+// Secret, that mount it or take environment variables from it. Objects whose
+// controller is impacted as well are left out, e.g. the Pods of an impacted
+// Deployment.
+func (r *impactResolver) Impacted(gvr string, obj *unstructured.Unstructured) []event.ObjectReference {
+	var kind string
+	switch gvr {
+	case "configmaps":
+		kind = "configMap"
+	case "secrets":
+		kind = "secret"
+	default:
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var impacted []*unstructured.Unstructured
+	uids := make(map[types.UID]bool)
+	for _, source := range r.sources {
+		items, err := source.indexer.ByIndex(cache.NamespaceIndex, obj.GetNamespace())
+		if err != nil {
+			items = source.indexer.List()
+		}
+		for _, item := range items {
+			workload, ok := item.(*unstructured.Unstructured)
+			if typed, isTyped := item.(runtime.Object); !ok && isTyped {
+				content, err := typedToUnstructured(typed, source.gvr)
+				workload, ok = &unstructured.Unstructured{Object: content}, err == nil
+			}
+			if !ok || workload.GetNamespace() != obj.GetNamespace() {
+				continue
+			}
+			if spec := podSpec(workload); spec != nil && slices.Contains(podSpecReferences(spec, kind), obj.GetName()) {
+				impacted = append(impacted, workload)
+				uids[workload.GetUID()] = true
+			}
+		}
+	}
+	var refs []event.ObjectReference
+	for _, workload := range impacted {
+		if owner := controllerRef(workload.GetOwnerReferences()); owner != nil && uids[owner.UID] {
+			continue
+		}
+		refs = append(refs, event.ObjectReference{
+			APIVersion: workload.GetAPIVersion(),
+			Kind:       workload.GetKind(),
+			Namespace:  workload.GetNamespace(),
+			Name:       workload.GetName(),
+			UID:        string(workload.GetUID()),
+		})
+	}
+	slices.SortFunc(refs, func(a, b event.ObjectReference) int {
+		if c := strings.Compare(a.Kind, b.Kind); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Name, b.Name)
+	})
+	return refs
+}
+
+// podSpecReferences returns the names of the ConfigMaps (kind configMap) or
+// Secrets (kind secret) a pod spec uses in volumes, envFrom, env or as image
+// pull secrets.
+func podSpecReferences(spec map[string]interface{}, kind string) []string {
+	var names []string
+	add := func(obj map[string]interface{}, fields ...string) {
+		if name, _, _ := unstructured.NestedString(obj, fields...); name != "" {
+			names = append(names, name)
+		}
+	}
+	// Secret volumes name the secret secretName, projected sources name.
+	volumeField := "name"
+	if kind == "secret" {
+		volumeField = "secretName"
+	}
+	for _, volume := range nestedMaps(spec, "volumes") {
+		add(volume, kind, volumeField)
+		for _, source := range nestedMaps(volume, "projected", "sources") {
+			add(source, kind, "name")
+		}
+	}
+	for _, containers := range []string{"initContainers", "containers", "ephemeralContainers"} {
+		for _, container := range nestedMaps(spec, containers) {
+			for _, envFrom := range nestedMaps(container, "envFrom") {
+				add(envFrom, kind+"Ref", "name")
+			}
+			for _, env := range nestedMaps(container, "env") {
+				add(env, "valueFrom", kind+"KeyRef", "name")
+			}
+		}
+	}
+	if kind == "secret" {
+		for _, pullSecret := range nestedMaps(spec, "imagePullSecrets") {
+			add(pullSecret, "name")
+		}
+	}
+	return names
+}
+
+// nestedMaps returns the maps of the list at fields without copying them.
+func nestedMaps(obj map[string]interface{}, fields ...string) []map[string]interface{} {
+	value, _, _ := unstructured.NestedFieldNoCopy(obj, fields...)
+	items, _ := value.([]interface{})
+	var maps []map[string]interface{}
+	for _, item := range items {
+		if m, ok := item.(map[string]interface{}); ok {
+			maps = append(maps, m)
+		}
+	}
+	return maps
+}
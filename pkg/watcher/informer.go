@@ -0,0 +1,105 @@
+package watcher
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/metadata/metadatainformer"
+	"k8s.io/client-go/tools/cache"
+)
+
+// isPermanentWatchError reports whether a watch error will not go away by retrying,
+// e.g. a misspelled resource or missing RBAC permissions.
+func isPermanentWatchError(err error) bool {
+	return apierrors.IsNotFound(err) ||
+		apierrors.IsForbidden(err) ||
+		apierrors.IsUnauthorized(err) ||
+		apierrors.IsMethodNotSupported(err)
+}
+
+// pagedListOptions makes the paginated list requests of reflectors fetch
+// pageSize objects per page. Reflectors list with resourceVersion "0" first,
+// which the API server answers from its watch cache in one response ignoring
+// the limit, so that is replaced with a consistent read from etcd. Watches
+// and unpaginated lists, e.g. the fallback after an expired continue token,
+// are left alone. It returns nil when pageSize is 0.
+func pagedListOptions(pageSize int64) func(*metav1.ListOptions) {
+	if pageSize <= 0 {
+		return nil
+	}
+	return func(options *metav1.ListOptions) {
+		if options.Watch || options.Limit == 0 {
+			return
+		}
+		options.Limit = pageSize
+		if options.ResourceVersion == "0" {
+			options.ResourceVersion = ""
+		}
+	}
+}
+
+// isExpired reports whether a watch ended because its resource version is
+// no longer available, the reflector then relists from the latest state.
+func isExpired(err error) bool {
+	return apierrors.IsResourceExpired(err) || apierrors.IsGone(err)
+}
+
+func newInformer(
+	client dynamic.Interface,
+	metadataClient metadata.Interface,
+	typedClient kubernetes.Interface,
+	controller ResourceControllerInterface,
+	listOptions func(*metav1.ListOptions),
+	watchErrorHandler func(gvr schema.GroupVersionResource, err error),
+) (cache.SharedIndexInformer, cache.InformerSynced, error) {
+	var informer cache.SharedIndexInformer
+	if controller.IsTyped() {
+		var err error
+		if informer, err = newTypedInformer(typedClient, controller, listOptions); err != nil {
+			return nil, nil, err
+		}
+	} else if controller.IsMetadataOnly() {
+		// Metadata informers only keep PartialObjectMetadata in the cache.
+		informer = metadatainformer.NewFilteredSharedInformerFactory(metadataClient, controller.ResyncPeriod(), corev1.NamespaceAll, listOptions).
+			ForResource(controller.GetGVR()).Informer()
+	} else {
+		informer = dynamicinformer.NewFilteredDynamicSharedInformerFactory(client, controller.ResyncPeriod(), corev1.NamespaceAll, listOptions).
+			ForResource(controller.GetGVR()).Informer()
+	}
+	if err := informer.SetTransform(controller.Transform); err != nil {
+		return nil, nil, err
+	}
+	gvr := controller.GetGVR()
+	if err := informer.SetWatchErrorHandler(func(_ *cache.Reflector, err error) {
+		watchErrorHandler(gvr, err)
+	}); err != nil {
+		return nil, nil, err
+	}
+	registration, err := informer.AddEventHandler(cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc:    controller.AddFunc,
+		UpdateFunc: controller.UpdateFunc,
+		DeleteFunc: controller.DeleteFunc,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	// The registration is synced once the handler has seen every object of the
+	// initial list, not just when the cache is filled.
+	return informer, registration.HasSynced, nil
+}
+
+func informersSyncedCallback(informers []cache.SharedIndexInformer) cache.InformerSynced {
+	return func() bool {
+		for _, informer := range informers {
+			if !informer.HasSynced() {
+				return false
+			}
+		}
+		return true
+	}
+}
@@ -0,0 +1,51 @@
+package watcher
+
+import (
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+// recurrenceFields are the fields of core/v1 and events.k8s.io/v1 Events that
+// change when an Event occurs again.
+var recurrenceFields = [][]string{
+	{"count"},
+	{"lastTimestamp"},
+	{"series"},
+	{"deprecatedCount"},
+	{"deprecatedLastTimestamp"},
+	{"metadata", "resourceVersion"},
+	{"metadata", "managedFields"},
+}
+
+// isRecurrence reports whether an update of an Event only counts another
+// occurrence of it.
+func isRecurrence(oldObj, newObj *unstructured.Unstructured) bool {
+	oldCopy, newCopy := oldObj.DeepCopy(), newObj.DeepCopy()
+	for _, fields := range recurrenceFields {
+		unstructured.RemoveNestedField(oldCopy.Object, fields...)
+		unstructured.RemoveNestedField(newCopy.Object, fields...)
+	}
+	return reflect.DeepEqual(oldCopy.Object, newCopy.Object)
+}
+
+// involvedObject returns the object an Event is about, taken from
+// involvedObject of core/v1 or regarding of events.k8s.io/v1 Events.
+func involvedObject(obj *unstructured.Unstructured) *event.ObjectReference {
+	ref, found, _ := unstructured.NestedStringMap(obj.Object, "involvedObject")
+	if !found {
+		ref, found, _ = unstructured.NestedStringMap(obj.Object, "regarding")
+	}
+	if !found || ref["name"] == "" {
+		return nil
+	}
+	return &event.ObjectReference{
+		APIVersion: ref["apiVersion"],
+		Kind:       ref["kind"],
+		Namespace:  ref["namespace"],
+		Name:       ref["name"],
+		UID:        ref["uid"],
+	}
+}
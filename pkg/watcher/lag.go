@@ -0,0 +1,39 @@
+package watcher
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/metrics"
+)
+
+// changeTime returns when the change behind an event happened in the
+// cluster: the creation of objects added after the controller started, or
+// the managedFields time of the manager behind an update. Other events and
+// updates without managedFields have no change time.
+func (rc *ResourceController) changeTime(eventType string, oldObj, newObj *unstructured.Unstructured) (time.Time, bool) {
+	if oldObj != nil {
+		if changed := changedBy(oldObj, newObj); changed != nil {
+			return changed.Time, true
+		}
+		return time.Time{}, false
+	}
+	// Objects of the initial list were created long before.
+	created := newObj.GetCreationTimestamp().Time
+	if eventType == "Add" && !created.Before(rc.started) {
+		return created, true
+	}
+	return time.Time{}, false
+}
+
+// observeLag records the seconds between the change of an object and its
+// event. The API server stores these times in seconds, the lag is
+// overestimated by up to a second.
+func (rc *ResourceController) observeLag(eventType string, oldObj, newObj *unstructured.Unstructured, emitted time.Time) {
+	changed, ok := rc.changeTime(eventType, oldObj, newObj)
+	if !ok {
+		return
+	}
+	metrics.EventLagSeconds.WithLabelValues(rc.GVR.Group, rc.GVR.Version, rc.GVR.Resource).Observe(max(emitted.Sub(changed).Seconds(), 0))
+}
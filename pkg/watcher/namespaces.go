@@ -0,0 +1,86 @@
+package watcher
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+)
+
+// namespaceLabels looks up the labels of namespaces in the cache of a
+// Namespace informer, which all resources with a namespaceSelector share.
+// Namespaces that are created, relabeled or deleted apply to the next event
+// of every object in them.
+type namespaceLabels struct {
+	informer cache.SharedIndexInformer
+}
+
+func newNamespaceLabels(client kubernetes.Interface) (*namespaceLabels, error) {
+	informer := informers.NewSharedInformerFactory(client, 0).Core().V1().Namespaces().Informer()
+	// Only the labels are needed, the rest of the namespace is not cached.
+	if err := informer.SetTransform(func(obj interface{}) (interface{}, error) {
+		if namespace, ok := obj.(*corev1.Namespace); ok {
+			return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{
+				Name:            namespace.Name,
+				ResourceVersion: namespace.ResourceVersion,
+				Labels:          namespace.Labels,
+			}}, nil
+		}
+		return obj, nil
+	}); err != nil {
+		return nil, err
+	}
+	return &namespaceLabels{informer: informer}, nil
+}
+
+// Matches reports whether the labels of namespace match selector. Unknown
+// namespaces never match, a nil namespaceLabels, e.g. in dry-runs, matches
+// every namespace.
+func (n *namespaceLabels) Matches(selector labels.Selector, namespace string) bool {
+	if n == nil {
+		return true
+	}
+	obj, exists, err := n.informer.GetIndexer().GetByKey(namespace)
+	if err != nil || !exists {
+		return false
+	}
+	ns, ok := obj.(*corev1.Namespace)
+	return ok && selector.Matches(labels.Set(ns.Labels))
+}
+
+// usesNamespaceSelector reports whether any resource of cfg selects
+// namespaces by label.
+func usesNamespaceSelector(cfg *config.Config) bool {
+	if cfg.Common.NamespaceSelector != nil {
+		return true
+	}
+	if cfg.CRDAutoWatch.Enabled && cfg.CRDAutoWatch.Resource.NamespaceSelector != nil {
+		return true
+	}
+	for _, resConfig := range cfg.Resources {
+		if resConfig.NamespaceSelector != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// labelSelector converts the selector of the config, nil stays nil.
+func labelSelector(selector *config.LabelSelector) (labels.Selector, error) {
+	if selector == nil {
+		return nil, nil
+	}
+	converted := &metav1.LabelSelector{MatchLabels: selector.MatchLabels}
+	for _, requirement := range selector.MatchExpressions {
+		converted.MatchExpressions = append(converted.MatchExpressions, metav1.LabelSelectorRequirement{
+			Key:      requirement.Key,
+			Operator: metav1.LabelSelectorOperator(requirement.Operator),
+			Values:   requirement.Values,
+		})
+	}
+	return metav1.LabelSelectorAsSelector(converted)
+}
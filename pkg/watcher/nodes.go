@@ -0,0 +1,101 @@
+package watcher
+
+import (
+	"reflect"
+	"slices"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+// derivedTaints are set by the node lifecycle controller from the conditions
+// and the cordoning of a Node, these are reported by their own events.
+var derivedTaints = []string{
+	"node.kubernetes.io/not-ready",
+	"node.kubernetes.io/unreachable",
+	"node.kubernetes.io/unschedulable",
+	"node.kubernetes.io/memory-pressure",
+	"node.kubernetes.io/disk-pressure",
+	"node.kubernetes.io/pid-pressure",
+	"node.kubernetes.io/network-unavailable",
+}
+
+// nodeChange is a lifecycle event of a Node and the change it reports.
+type nodeChange struct {
+	eventType string
+	change    event.FieldChange
+}
+
+// nodeChanges condenses an update of a Node into lifecycle events, heartbeats
+// and other status updates yield none.
+func nodeChanges(oldObj, newObj *unstructured.Unstructured) []nodeChange {
+	var changes []nodeChange
+	oldReady, newReady := readyCondition(oldObj), readyCondition(newObj)
+	if isReady(oldReady) != isReady(newReady) {
+		eventType := event.TypeNodeNotReady
+		if isReady(newReady) {
+			eventType = event.TypeNodeReady
+		}
+		changes = append(changes, nodeChange{eventType, event.FieldChange{Path: "status.conditions[Ready]", Old: oldReady, New: newReady}})
+	}
+	oldCordoned, _, _ := unstructured.NestedBool(oldObj.Object, "spec", "unschedulable")
+	newCordoned, _, _ := unstructured.NestedBool(newObj.Object, "spec", "unschedulable")
+	if oldCordoned != newCordoned {
+		eventType := event.TypeNodeUncordoned
+		if newCordoned {
+			eventType = event.TypeNodeCordoned
+		}
+		changes = append(changes, nodeChange{eventType, event.FieldChange{Path: "spec.unschedulable", Old: oldCordoned, New: newCordoned}})
+	}
+	if oldTaints, newTaints := taints(oldObj), taints(newObj); !reflect.DeepEqual(oldTaints, newTaints) {
+		changes = append(changes, nodeChange{event.TypeNodeTaintsChanged, event.FieldChange{Path: "spec.taints", Old: oldTaints, New: newTaints}})
+	}
+	// The version is empty until the kubelet posted its first status.
+	oldVersion, _, _ := unstructured.NestedString(oldObj.Object, "status", "nodeInfo", "kubeletVersion")
+	newVersion, _, _ := unstructured.NestedString(newObj.Object, "status", "nodeInfo", "kubeletVersion")
+	if oldVersion != "" && oldVersion != newVersion {
+		changes = append(changes, nodeChange{event.TypeNodeKubeletChanged, event.FieldChange{Path: "status.nodeInfo.kubeletVersion", Old: oldVersion, New: newVersion}})
+	}
+	return changes
+}
+
+// readyCondition returns the status, reason and message of the Ready
+// condition, without its heartbeat and transition times.
+func readyCondition(obj *unstructured.Unstructured) map[string]interface{} {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, condition := range conditions {
+		condition, ok := condition.(map[string]interface{})
+		if !ok || condition["type"] != "Ready" {
+			continue
+		}
+		ready := make(map[string]interface{})
+		for _, key := range []string{"status", "reason", "message"} {
+			if value, ok := condition[key]; ok {
+				ready[key] = value
+			}
+		}
+		return ready
+	}
+	return nil
+}
+
+func isReady(condition map[string]interface{}) bool {
+	return condition["status"] == "True"
+}
+
+// taints returns the taints of obj that are not derived by the node
+// lifecycle controller.
+func taints(obj *unstructured.Unstructured) []interface{} {
+	all, _, _ := unstructured.NestedSlice(obj.Object, "spec", "taints")
+	var taints []interface{}
+	for _, taint := range all {
+		if taint, ok := taint.(map[string]interface{}); ok {
+			if key, _ := taint["key"].(string); slices.Contains(derivedTaints, key) {
+				continue
+			}
+		}
+		taints = append(taints, taint)
+	}
+	return taints
+}
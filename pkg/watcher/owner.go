@@ -0,0 +1,133 @@
+package watcher
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/metadata"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+const (
+	// maxOwnerDepth bounds the owner walk in case of reference cycles.
+	maxOwnerDepth = 10
+	ownerCacheTTL = 5 * time.Minute
+	ownerTimeout  = 5 * time.Second
+)
+
+type cachedOwner struct {
+	owner   event.Owner
+	expires time.Time
+}
+
+// ownerResolver walks ownerReferences up to the top-level controller of an object,
+// e.g. Pod -> ReplicaSet -> Deployment. Resolved owners are cached by the UID of the
+// direct owner, so pods of the same ReplicaSet cost a single lookup.
+type ownerResolver struct {
+	client metadata.Interface
+	mapper meta.RESTMapper
+
+	mu    sync.Mutex
+	cache map[types.UID]cachedOwner
+}
+
+func newOwnerResolver(client metadata.Interface, mapper meta.RESTMapper) *ownerResolver {
+	return &ownerResolver{client: client, mapper: mapper, cache: make(map[types.UID]cachedOwner)}
+}
+
+// Resolve returns the root owner of obj, or nil when obj has no owner.
+// Owners that can not be fetched end the walk, the last known owner is returned.
+func (r *ownerResolver) Resolve(ctx context.Context, obj metav1.Object) *event.Owner {
+	ref := controllerRef(obj.GetOwnerReferences())
+	if ref == nil {
+		return nil
+	}
+	if owner, ok := r.cached(ref.UID); ok {
+		return &owner
+	}
+	ctx, cancel := context.WithTimeout(ctx, ownerTimeout)
+	defer cancel()
+
+	direct := ref.UID
+	owner := ownerFromRef(*ref)
+	namespace := obj.GetNamespace()
+	for depth := 0; depth < maxOwnerDepth; depth++ {
+		parent, err := r.get(ctx, *ref, namespace)
+		if err != nil {
+			break
+		}
+		ref = controllerRef(parent.GetOwnerReferences())
+		if ref == nil {
+			break
+		}
+		owner = ownerFromRef(*ref)
+		namespace = parent.GetNamespace()
+	}
+	r.store(direct, owner)
+	return &owner
+}
+
+func (r *ownerResolver) get(ctx context.Context, ref metav1.OwnerReference, namespace string) (*metav1.PartialObjectMetadata, error) {
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return nil, err
+	}
+	mapping, err := r.mapper.RESTMapping(gv.WithKind(ref.Kind).GroupKind(), gv.Version)
+	if err != nil {
+		return nil, err
+	}
+	if mapping.Scope.Name() == meta.RESTScopeNameRoot {
+		return r.client.Resource(mapping.Resource).Get(ctx, ref.Name, metav1.GetOptions{})
+	}
+	return r.client.Resource(mapping.Resource).Namespace(namespace).Get(ctx, ref.Name, metav1.GetOptions{})
+}
+
+func (r *ownerResolver) cached(uid types.UID) (event.Owner, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.cache[uid]
+	if !ok {
+		return event.Owner{}, false
+	}
+	if time.Now().After(entry.expires) {
+		delete(r.cache, uid)
+		return event.Owner{}, false
+	}
+	return entry.owner, true
+}
+
+func (r *ownerResolver) store(uid types.UID, owner event.Owner) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	for key, entry := range r.cache {
+		if now.After(entry.expires) {
+			delete(r.cache, key)
+		}
+	}
+	r.cache[uid] = cachedOwner{owner: owner, expires: now.Add(ownerCacheTTL)}
+}
+
+// controllerRef returns the managing controller reference, or the first
+// reference when none is marked as controller.
+func controllerRef(refs []metav1.OwnerReference) *metav1.OwnerReference {
+	if len(refs) == 0 {
+		return nil
+	}
+	for i := range refs {
+		if refs[i].Controller != nil && *refs[i].Controller {
+			return &refs[i]
+		}
+	}
+	return &refs[0]
+}
+
+func ownerFromRef(ref metav1.OwnerReference) event.Owner {
+	return event.Owner{APIVersion: ref.APIVersion, Kind: ref.Kind, Name: ref.Name, UID: string(ref.UID)}
+}
@@ -0,0 +1,63 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+)
+
+// informerVerbs are the verbs an informer needs on its resource.
+var informerVerbs = []string{"list", "watch"}
+
+// checkPermissions asks the API server with SelfSubjectAccessReviews whether
+// the informers of gvrs may list and watch in all namespaces, and returns an
+// error for every missing permission. Subjects allowed everything, e.g.
+// cluster admins, are recognized with a first review of all resources.
+func checkPermissions(ctx context.Context, client kubernetes.Interface, gvrs []schema.GroupVersionResource) ([]error, error) {
+	allowed, _, err := accessAllowed(ctx, client, schema.GroupVersionResource{Group: "*", Resource: "*"}, "*")
+	if err != nil || allowed {
+		return nil, err
+	}
+	var errs []error
+	for _, gvr := range gvrs {
+		for _, verb := range informerVerbs {
+			allowed, reason, err := accessAllowed(ctx, client, gvr, verb)
+			if err != nil {
+				return nil, err
+			}
+			if !allowed {
+				errs = append(errs, permissionError(gvr, verb, reason))
+			}
+		}
+	}
+	return errs, nil
+}
+
+func accessAllowed(ctx context.Context, client kubernetes.Interface, gvr schema.GroupVersionResource, verb string) (bool, string, error) {
+	review, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+		Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+			// An empty namespace asks for all namespaces.
+			ResourceAttributes: &authorizationv1.ResourceAttributes{Verb: verb, Group: gvr.Group, Version: gvr.Version, Resource: gvr.Resource},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return false, "", fmt.Errorf("failed to review access to %s: %w", gvr.String(), err)
+	}
+	return review.Status.Allowed, review.Status.Reason, nil
+}
+
+func permissionError(gvr schema.GroupVersionResource, verb, reason string) error {
+	resource := gvr.Resource
+	if gvr.Group != "" {
+		resource += "." + gvr.Group
+	}
+	err := fmt.Errorf("missing permission to %s %s cluster-wide", verb, resource)
+	if reason != "" {
+		err = fmt.Errorf("%w: %s", err, reason)
+	}
+	return err
+}
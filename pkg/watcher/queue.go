@@ -0,0 +1,113 @@
+package watcher
+
+import (
+	"sync"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+	"github.com/fl64/k8s-resource-watcher/pkg/metrics"
+)
+
+const defaultQueueCapacity = 1024
+
+// EventQueue is a bounded FIFO decoupling informer handlers from the sinks.
+type EventQueue struct {
+	mu       sync.Mutex
+	notEmpty *sync.Cond
+	notFull  *sync.Cond
+	items    []event.Event
+	capacity int
+	policy   string
+	closed   bool
+}
+
+func NewEventQueue(cfg config.QueueConfig) *EventQueue {
+	capacity := cfg.Capacity
+	if capacity <= 0 {
+		capacity = defaultQueueCapacity
+	}
+	policy := cfg.OverflowPolicy
+	if policy == "" {
+		policy = config.OverflowPolicyBlock
+	}
+	q := &EventQueue{
+		items:    make([]event.Event, 0, capacity),
+		capacity: capacity,
+		policy:   policy,
+	}
+	q.notEmpty = sync.NewCond(&q.mu)
+	q.notFull = sync.NewCond(&q.mu)
+	metrics.QueueCapacity.Set(float64(capacity))
+	return q
+}
+
+// Push enqueues an event, applying the overflow policy when the queue is full.
+func (q *EventQueue) Push(ev event.Event) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.items) >= q.capacity && !q.closed {
+		switch q.policy {
+		case config.OverflowPolicyDropNewest:
+			metrics.DroppedEventsTotal.WithLabelValues("queue", "events", "overflow").Inc()
+			return
+		case config.OverflowPolicyDropOldest:
+			metrics.DroppedEventsTotal.WithLabelValues("queue", "events", "overflow").Inc()
+			q.items[0] = event.Event{}
+			q.items = q.items[1:]
+		default:
+			q.notFull.Wait()
+		}
+	}
+	if q.closed {
+		metrics.DroppedEventsTotal.WithLabelValues("queue", "events", "closed").Inc()
+		return
+	}
+	q.items = append(q.items, ev)
+	metrics.QueueDepth.Set(float64(len(q.items)))
+	q.notEmpty.Signal()
+}
+
+// Pop returns the next event, blocking until one is available. It returns
+// false once the queue is closed and empty.
+func (q *EventQueue) Pop() (event.Event, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for len(q.items) == 0 && !q.closed {
+		q.notEmpty.Wait()
+	}
+	if len(q.items) == 0 {
+		return event.Event{}, false
+	}
+	ev := q.items[0]
+	q.items[0] = event.Event{}
+	q.items = q.items[1:]
+	metrics.QueueDepth.Set(float64(len(q.items)))
+	q.notFull.Signal()
+	return ev, true
+}
+
+func (q *EventQueue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// Close stops accepting new events; already queued events can still be popped.
+func (q *EventQueue) Close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.notEmpty.Broadcast()
+	q.notFull.Broadcast()
+}
+
+// Run passes queued events to handler until the queue is closed and drained.
+func (q *EventQueue) Run(handler func(event.Event)) {
+	for {
+		ev, ok := q.Pop()
+		if !ok {
+			return
+		}
+		handler(ev)
+	}
+}
@@ -0,0 +1,89 @@
+package watcher
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+func queuedNames(q *EventQueue) []string {
+	var names []string
+	for q.Len() > 0 {
+		ev, _ := q.Pop()
+		names = append(names, ev.Name)
+	}
+	return names
+}
+
+func TestEventQueueOverflow(t *testing.T) {
+	tests := []struct {
+		policy string
+		want   []string
+	}{
+		{policy: config.OverflowPolicyDropNewest, want: []string{"a", "b"}},
+		{policy: config.OverflowPolicyDropOldest, want: []string{"c", "d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			q := NewEventQueue(config.QueueConfig{Capacity: 2, OverflowPolicy: tt.policy})
+			for _, name := range []string{"a", "b", "c", "d"} {
+				q.Push(event.Event{Name: name})
+			}
+			if got := queuedNames(q); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("queued %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEventQueueBlock(t *testing.T) {
+	q := NewEventQueue(config.QueueConfig{Capacity: 1})
+	q.Push(event.Event{Name: "a"})
+	pushed := make(chan struct{})
+	go func() {
+		q.Push(event.Event{Name: "b"})
+		close(pushed)
+	}()
+	select {
+	case <-pushed:
+		t.Fatal("Push did not block on a full queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if ev, _ := q.Pop(); ev.Name != "a" {
+		t.Fatalf("Pop() = %q, want a", ev.Name)
+	}
+	select {
+	case <-pushed:
+	case <-time.After(time.Second):
+		t.Fatal("Push still blocked after Pop")
+	}
+	if ev, _ := q.Pop(); ev.Name != "b" {
+		t.Fatalf("Pop() = %q, want b", ev.Name)
+	}
+}
+
+func TestEventQueueClose(t *testing.T) {
+	q := NewEventQueue(config.QueueConfig{Capacity: 1})
+	q.Push(event.Event{Name: "a"})
+	blocked := make(chan struct{})
+	go func() {
+		q.Push(event.Event{Name: "b"})
+		close(blocked)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	q.Close()
+	select {
+	case <-blocked:
+	case <-time.After(time.Second):
+		t.Fatal("Close did not release a blocked Push")
+	}
+	q.Push(event.Event{Name: "c"})
+	var names []string
+	q.Run(func(ev event.Event) { names = append(names, ev.Name) })
+	if want := []string{"a"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("drained %v, want %v", names, want)
+	}
+}
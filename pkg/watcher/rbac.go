@@ -0,0 +1,165 @@
+package watcher
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"golang.org/x/exp/slog"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/rest"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+)
+
+// RBAC holds the permissions the watcher needs for a config.
+type RBAC struct {
+	// ClusterRules are granted cluster-wide. Informers list and watch in
+	// all namespaces and filter by namespace themselves, so resources are
+	// listed here even when only some namespaces are watched.
+	ClusterRules []rbacv1.PolicyRule
+	// NamespaceRules are granted in a single namespace, e.g. for the dedup
+	// state object.
+	NamespaceRules map[string][]rbacv1.PolicyRule
+	// Notes name permissions that can not be derived from the config.
+	Notes []string
+}
+
+// RBACRules returns the permissions needed to watch the resources of cfg.
+// Entries given by kind, short name or without a version and wildcard
+// entries are resolved with discovery, restConfig may be nil when every
+// entry names its group, version and resource. stateNamespace is used for a
+// dedup state object without a namespace.
+func RBACRules(cfg *config.Config, restConfig *rest.Config, stateNamespace string) (*RBAC, error) {
+	var client discovery.CachedDiscoveryInterface
+	var mapper meta.RESTMapper
+	if restConfig != nil {
+		restConfig, err := applyClientConfig(restConfig, cfg.Client)
+		if err != nil {
+			return nil, err
+		}
+		baseDiscoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create discovery client: %w", err)
+		}
+		client = memory.NewMemCacheClient(baseDiscoveryClient)
+		mapper = newRESTMapper(client, slog.Default())
+	}
+
+	watched := make(map[string][]string)
+	watch := func(group, resource string) {
+		if !slices.Contains(watched[group], resource) {
+			watched[group] = append(watched[group], resource)
+		}
+	}
+	for i, resConfig := range cfg.Resources {
+		scope := scopeOf(cfg.Common, resConfig)
+		if isWildcard(resConfig) {
+			if client == nil {
+				return nil, fmt.Errorf("resources[%d] (%s): wildcard entries need discovery", i, entryName(resConfig))
+			}
+			expanded, err := expandWildcard(client, resConfig, scope)
+			if err != nil {
+				return nil, fmt.Errorf("resources[%d] (%s): %w", i, entryName(resConfig), err)
+			}
+			for _, entry := range expanded {
+				watch(entry.Group, entry.Resource)
+			}
+			continue
+		}
+		if mapper == nil {
+			if resConfig.Kind != "" || resConfig.Version == "" {
+				return nil, fmt.Errorf("resources[%d] (%s): entries without group, version and resource need discovery", i, entryName(resConfig))
+			}
+			watch(resConfig.Group, resConfig.Resource)
+			continue
+		}
+		gvr, err := resolveGVR(mapper, resConfig)
+		if err != nil {
+			return nil, fmt.Errorf("resources[%d] (%s): %w", i, entryName(resConfig), err)
+		}
+		if namespaced, ok := isNamespaced(mapper, gvr); ok && !inScope(scope, namespaced) {
+			continue
+		}
+		watch(gvr.Group, gvr.Resource)
+	}
+
+	rbac := &RBAC{NamespaceRules: make(map[string][]rbacv1.PolicyRule)}
+	if usesNamespaceSelector(cfg) {
+		watch("", "namespaces")
+	}
+	if cfg.CRDAutoWatch.Enabled {
+		watch(crdGVR.Group, crdGVR.Resource)
+		for _, group := range cfg.CRDAutoWatch.Groups {
+			if isPattern(group) {
+				rbac.Notes = append(rbac.Notes, fmt.Sprintf("crdAutoWatch group %q is a pattern, grant list and watch on the custom resources of the matching groups", group))
+				continue
+			}
+			watch(group, "*")
+		}
+		if len(cfg.CRDAutoWatch.Groups) == 0 {
+			rbac.Notes = append(rbac.Notes, "crdAutoWatch watches the custom resources of all groups, grant list and watch on them")
+		}
+	}
+	rbac.ClusterRules = listWatchRules(watched)
+
+	if resolvesOwners(cfg) {
+		rbac.Notes = append(rbac.Notes, "resolveOwners gets the owners of objects, grant get on their resources, e.g. replicasets.apps and deployments.apps")
+	}
+	if user := cfg.Client.Impersonate.User; user != "" {
+		rbac.Notes = append(rbac.Notes, fmt.Sprintf("client.impersonate is set, the rules are needed by %q and the service account needs impersonate on it", user))
+	}
+	if stateObject := cfg.Dedup.StateObject; stateObject.Kind != "" {
+		namespace := stateObject.Namespace
+		if namespace == "" {
+			namespace = stateNamespace
+		}
+		group, resource := "", "configmaps"
+		if stateObject.Kind == config.StateObjectLease {
+			group, resource = "coordination.k8s.io", "leases"
+		}
+		// Create can not be restricted by name.
+		rbac.NamespaceRules[namespace] = []rbacv1.PolicyRule{
+			{APIGroups: []string{group}, Resources: []string{resource}, ResourceNames: []string{stateObject.Name}, Verbs: []string{"get", "update"}},
+			{APIGroups: []string{group}, Resources: []string{resource}, Verbs: []string{"create"}},
+		}
+	}
+	return rbac, nil
+}
+
+// listWatchRules returns one list and watch rule per API group of watched,
+// which maps groups to resources.
+func listWatchRules(watched map[string][]string) []rbacv1.PolicyRule {
+	groups := make([]string, 0, len(watched))
+	for group := range watched {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	rules := make([]rbacv1.PolicyRule, 0, len(groups))
+	for _, group := range groups {
+		resources := watched[group]
+		// A wildcard covers the other resources of its group.
+		if slices.Contains(resources, "*") {
+			resources = []string{"*"}
+		}
+		sort.Strings(resources)
+		rules = append(rules, rbacv1.PolicyRule{APIGroups: []string{group}, Resources: resources, Verbs: []string{"list", "watch"}})
+	}
+	return rules
+}
+
+func resolvesOwners(cfg *config.Config) bool {
+	if cfg.Common.ResolveOwners || cfg.CRDAutoWatch.Enabled && cfg.CRDAutoWatch.Resource.ResolveOwners {
+		return true
+	}
+	return slices.ContainsFunc(cfg.Resources, func(rc config.ResourceConfig) bool { return rc.ResolveOwners })
+}
+
+// isPattern reports whether a glob pattern matches more than itself.
+func isPattern(pattern string) bool {
+	return strings.ContainsAny(pattern, `*?[\`)
+}
@@ -0,0 +1,59 @@
+package watcher
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Recorder receives the objects delivered by the informers before they are
+// filtered, e.g. to capture traffic for DryRun. eventType is Add, Update or
+// Delete. It is called concurrently for different resources.
+type Recorder func(gvr schema.GroupVersionResource, eventType string, obj map[string]interface{})
+
+// addRecorder registers w.recorder as an additional handler of informer.
+func (w *Watcher) addRecorder(informer cache.SharedIndexInformer, controller ResourceControllerInterface) error {
+	if w.recorder == nil {
+		return nil
+	}
+	gvr := controller.GetGVR()
+	record := func(eventType string, obj interface{}) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		content, err := recordedObject(obj, gvr)
+		if err != nil {
+			w.logger.Error("Failed to record object", "gvr", gvr.String(), "error", err)
+			return
+		}
+		w.recorder(gvr, eventType, content)
+	}
+	_, err := informer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { record("Add", obj) },
+		UpdateFunc: func(_, newObj interface{}) { record("Update", newObj) },
+		DeleteFunc: func(obj interface{}) { record("Delete", obj) },
+	})
+	return err
+}
+
+// recordedObject converts the cached objects of all informer kinds. Metadata
+// only objects of resources without a typed informer have no kind.
+func recordedObject(obj interface{}, gvr schema.GroupVersionResource) (map[string]interface{}, error) {
+	switch o := obj.(type) {
+	case *unstructured.Unstructured:
+		return o.Object, nil
+	case runtime.Object:
+		content, err := typedToUnstructured(o, gvr)
+		if err != nil {
+			return nil, err
+		}
+		if content["kind"] == "" {
+			delete(content, "kind")
+		}
+		return content, nil
+	}
+	return nil, fmt.Errorf("unexpected object type %T", obj)
+}
@@ -0,0 +1,100 @@
+package watcher
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+type pendingDelete struct {
+	obj   *unstructured.Unstructured
+	timer *time.Timer
+}
+
+// recreateTracker holds deletions back for a window, so an object that is
+// added again under the same name within it, e.g. by a rolling replacement,
+// is reported once as recreated instead of deleted and added. Like for the
+// debouncer, expired deletions are emitted through run.
+type recreateTracker struct {
+	window  time.Duration
+	run     func(obj interface{}, fn func())
+	flush   func(obj *unstructured.Unstructured)
+	mu      sync.Mutex
+	pending map[string]*pendingDelete
+}
+
+func newRecreateTracker(window time.Duration, run func(obj interface{}, fn func()), flush func(obj *unstructured.Unstructured)) *recreateTracker {
+	return &recreateTracker{
+		window:  window,
+		run:     run,
+		flush:   flush,
+		pending: make(map[string]*pendingDelete),
+	}
+}
+
+// Delete holds the deletion of obj back until the window passed.
+func (t *recreateTracker) Delete(key string, obj *unstructured.Unstructured) {
+	// A deletion that is still held back is emitted before the next one.
+	t.Flush(key)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p := &pendingDelete{obj: obj}
+	p.timer = time.AfterFunc(t.window, func() {
+		t.run(obj, func() { t.expire(key, p) })
+	})
+	t.pending[key] = p
+}
+
+// Add returns the deleted object held back for key and forgets its deletion.
+func (t *recreateTracker) Add(key string) (*unstructured.Unstructured, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.pending[key]
+	if !ok {
+		return nil, false
+	}
+	p.timer.Stop()
+	delete(t.pending, key)
+	return p.obj, true
+}
+
+func (t *recreateTracker) expire(key string, p *pendingDelete) {
+	t.mu.Lock()
+	// The deletion may have been taken by an Add in the meantime.
+	ok := t.pending[key] == p
+	if ok {
+		delete(t.pending, key)
+	}
+	t.mu.Unlock()
+	if ok {
+		t.flush(p.obj)
+	}
+}
+
+// Flush emits the deletion held back for key, if any.
+func (t *recreateTracker) Flush(key string) {
+	t.mu.Lock()
+	p, ok := t.pending[key]
+	if ok {
+		p.timer.Stop()
+		delete(t.pending, key)
+	}
+	t.mu.Unlock()
+	if ok {
+		t.flush(p.obj)
+	}
+}
+
+// FlushAll emits all deletions held back.
+func (t *recreateTracker) FlushAll() {
+	t.mu.Lock()
+	keys := make([]string, 0, len(t.pending))
+	for key := range t.pending {
+		keys = append(keys, key)
+	}
+	t.mu.Unlock()
+	for _, key := range keys {
+		t.Flush(key)
+	}
+}
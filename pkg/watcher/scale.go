@@ -0,0 +1,28 @@
+package watcher
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+// scaleChange returns the change of the replica count of an update, nil when
+// it did not change.
+func scaleChange(oldObj, newObj *unstructured.Unstructured) *event.ScaleChange {
+	from, oldFound := replicas(oldObj)
+	to, newFound := replicas(newObj)
+	if !oldFound || !newFound || from == to {
+		return nil
+	}
+	return &event.ScaleChange{From: from, To: to}
+}
+
+// replicas returns spec.replicas of workloads or, for HorizontalPodAutoscalers,
+// the desired replicas of their status.
+func replicas(obj *unstructured.Unstructured) (int64, bool) {
+	if count, found, err := unstructured.NestedInt64(obj.Object, "spec", "replicas"); found && err == nil {
+		return count, true
+	}
+	count, found, err := unstructured.NestedInt64(obj.Object, "status", "desiredReplicas")
+	return count, found && err == nil
+}
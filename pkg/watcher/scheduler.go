@@ -0,0 +1,203 @@
+package watcher
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/metrics"
+)
+
+// scheduler runs the event handlers of all resources on a shared pool of
+// workers. Every resource has a shard per allowed concurrent handler, the
+// events of an object always go to the same shard and are handled in order.
+// Workers take the resources round-robin, so a busy resource such as Pods can
+// not starve the others.
+type scheduler struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	resources []*resourceQueue
+	next      int
+	// pending counts queued and running tasks.
+	pending int
+	closed  bool
+	wg      sync.WaitGroup
+}
+
+type resourceQueue struct {
+	gvr     schema.GroupVersionResource
+	shards  []*shard
+	next    int
+	backlog int
+	// released is set when the resource is no longer watched, the queue is
+	// removed once its tasks are handled.
+	released bool
+}
+
+type shard struct {
+	tasks   []func()
+	running bool
+}
+
+// newScheduler returns nil when workers is 0, handlers then run on the
+// informer goroutines.
+func newScheduler(workers int) *scheduler {
+	if workers <= 0 {
+		return nil
+	}
+	s := &scheduler{}
+	s.cond = sync.NewCond(&s.mu)
+	s.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go s.work()
+	}
+	return s
+}
+
+// runner registers a resource handling at most concurrency events at once.
+func (s *scheduler) runner(gvr schema.GroupVersionResource, concurrency int) *resourceRunner {
+	if s == nil {
+		return nil
+	}
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	q := &resourceQueue{gvr: gvr, shards: make([]*shard, concurrency)}
+	for i := range q.shards {
+		q.shards[i] = &shard{}
+	}
+	s.mu.Lock()
+	s.resources = append(s.resources, q)
+	s.mu.Unlock()
+	return &resourceRunner{scheduler: s, queue: q}
+}
+
+func (s *scheduler) work() {
+	defer s.wg.Done()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for {
+		q, sh := s.pick()
+		if sh == nil {
+			if s.closed && s.pending == 0 {
+				return
+			}
+			s.cond.Wait()
+			continue
+		}
+		task := sh.tasks[0]
+		sh.tasks = sh.tasks[1:]
+		sh.running = true
+		q.backlog--
+		metrics.HandlerBacklog.WithLabelValues(q.gvr.Group, q.gvr.Version, q.gvr.Resource).Set(float64(q.backlog))
+		s.mu.Unlock()
+		task()
+		s.mu.Lock()
+		sh.running = false
+		s.pending--
+		if q.released {
+			s.remove(q)
+		}
+		s.cond.Broadcast()
+	}
+}
+
+// pick returns the next shard with a task that is not running, starting
+// after the resource picked last. It must be called with s.mu held.
+func (s *scheduler) pick() (*resourceQueue, *shard) {
+	for i := range s.resources {
+		index := (s.next + i) % len(s.resources)
+		q := s.resources[index]
+		for j := range q.shards {
+			shardIndex := (q.next + j) % len(q.shards)
+			sh := q.shards[shardIndex]
+			if len(sh.tasks) > 0 && !sh.running {
+				s.next = index + 1
+				q.next = shardIndex + 1
+				return q, sh
+			}
+		}
+	}
+	return nil, nil
+}
+
+// remove drops the released queue q once it has no queued or running tasks.
+// It must be called with s.mu held.
+func (s *scheduler) remove(q *resourceQueue) {
+	for _, sh := range q.shards {
+		if len(sh.tasks) > 0 || sh.running {
+			return
+		}
+	}
+	for i, queue := range s.resources {
+		if queue != q {
+			continue
+		}
+		s.resources = append(s.resources[:i], s.resources[i+1:]...)
+		if i < s.next {
+			s.next--
+		}
+		metrics.HandlerBacklog.DeleteLabelValues(q.gvr.Group, q.gvr.Version, q.gvr.Resource)
+		return
+	}
+}
+
+// Close handles the queued tasks and stops the workers.
+func (s *scheduler) Close() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.closed = true
+	s.cond.Broadcast()
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// resourceRunner queues the handlers of one resource on the scheduler.
+type resourceRunner struct {
+	scheduler *scheduler
+	queue     *resourceQueue
+}
+
+// Run queues fn on the shard of obj, a nil runner calls fn right away.
+func (r *resourceRunner) Run(obj interface{}, fn func()) {
+	if r == nil {
+		fn()
+		return
+	}
+	key, _ := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(key))
+	s, q := r.scheduler, r.queue
+	s.mu.Lock()
+	if s.closed || q.released {
+		// Events of informers still running at shutdown or after the
+		// resource was released are handled inline.
+		s.mu.Unlock()
+		fn()
+		return
+	}
+	sh := q.shards[hash.Sum32()%uint32(len(q.shards))]
+	sh.tasks = append(sh.tasks, fn)
+	q.backlog++
+	s.pending++
+	metrics.HandlerBacklog.WithLabelValues(q.gvr.Group, q.gvr.Version, q.gvr.Resource).Set(float64(q.backlog))
+	s.cond.Signal()
+	s.mu.Unlock()
+}
+
+// Release removes the resource from the scheduler once its queued handlers
+// ran, later events are handled inline. A nil runner does nothing.
+func (r *resourceRunner) Release() {
+	if r == nil {
+		return
+	}
+	s := r.scheduler
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r.queue.released = true
+	s.remove(r.queue)
+}
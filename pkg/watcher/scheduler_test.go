@@ -0,0 +1,126 @@
+package watcher
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func testObject(namespace, name string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	return obj
+}
+
+func TestSchedulerObjectOrder(t *testing.T) {
+	s := newScheduler(4)
+	r := s.runner(schema.GroupVersionResource{Resource: "pods"}, 4)
+	var mu sync.Mutex
+	handled := make(map[string][]int)
+	for i := 0; i < 50; i++ {
+		for _, name := range []string{"a", "b", "c"} {
+			name, i := name, i
+			r.Run(testObject("default", name), func() {
+				// Delay a few tasks so that out of order handling would show.
+				if i%7 == 0 {
+					time.Sleep(time.Millisecond)
+				}
+				mu.Lock()
+				handled[name] = append(handled[name], i)
+				mu.Unlock()
+			})
+		}
+	}
+	s.Close()
+	for _, name := range []string{"a", "b", "c"} {
+		for i, got := range handled[name] {
+			if got != i {
+				t.Fatalf("events of %s handled in order %v", name, handled[name])
+			}
+		}
+		if len(handled[name]) != 50 {
+			t.Fatalf("%d events of %s handled, want 50", len(handled[name]), name)
+		}
+	}
+}
+
+func TestSchedulerFairness(t *testing.T) {
+	s := newScheduler(1)
+	busy := s.runner(schema.GroupVersionResource{Resource: "pods"}, 1)
+	quiet := s.runner(schema.GroupVersionResource{Resource: "nodes"}, 1)
+	var mu sync.Mutex
+	var order []string
+	record := func(resource string) func() {
+		return func() {
+			mu.Lock()
+			order = append(order, resource)
+			mu.Unlock()
+		}
+	}
+	// The only worker is held until all tasks are queued.
+	release := make(chan struct{})
+	busy.Run(testObject("default", "first"), func() { <-release })
+	for i := 0; i < 10; i++ {
+		busy.Run(testObject("default", fmt.Sprintf("pod-%d", i)), record("pods"))
+	}
+	for i := 0; i < 2; i++ {
+		quiet.Run(testObject("", fmt.Sprintf("node-%d", i)), record("nodes"))
+	}
+	close(release)
+	s.Close()
+	want := []string{"nodes", "pods", "nodes", "pods"}
+	if !reflect.DeepEqual(order[:4], want) {
+		t.Errorf("handled %v, want the resources to alternate", order)
+	}
+}
+
+func TestSchedulerRelease(t *testing.T) {
+	s := newScheduler(1)
+	released := s.runner(schema.GroupVersionResource{Resource: "widgets"}, 1)
+	other := s.runner(schema.GroupVersionResource{Resource: "pods"}, 1)
+	block := make(chan struct{})
+	ran := make(chan struct{})
+	released.Run(testObject("default", "a"), func() { <-block })
+	released.Run(testObject("default", "a"), func() { close(ran) })
+	released.Release()
+	s.mu.Lock()
+	resources := len(s.resources)
+	s.mu.Unlock()
+	if resources != 2 {
+		t.Fatalf("%d resources with queued tasks, want 2", resources)
+	}
+	close(block)
+	<-ran
+	// The queue is removed after the last task, wait for the worker.
+	done := make(chan struct{})
+	other.Run(testObject("default", "b"), func() { close(done) })
+	<-done
+	s.mu.Lock()
+	resources = len(s.resources)
+	s.mu.Unlock()
+	if resources != 1 {
+		t.Fatalf("%d resources after release, want 1", resources)
+	}
+	inline := false
+	released.Run(testObject("default", "a"), func() { inline = true })
+	if !inline {
+		t.Error("task of a released resource was not run inline")
+	}
+	s.Close()
+}
+
+func TestNilRunner(t *testing.T) {
+	var r *resourceRunner
+	ran := false
+	r.Run(testObject("default", "a"), func() { ran = true })
+	if !ran {
+		t.Error("nil runner did not run the task")
+	}
+	r.Release()
+}
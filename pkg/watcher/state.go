@@ -0,0 +1,158 @@
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	coordinationv1 "k8s.io/api/coordination/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+)
+
+const (
+	// stateKey is the ConfigMap data key and Lease annotation of the state.
+	stateKey           = "k8s-resource-watcher/dedup-state"
+	stateConfigMapKey  = "dedup.json"
+	stateTimeout       = 10 * time.Second
+	serviceAccountPath = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+	// The API server rejects ConfigMaps above 1 MiB and annotations above
+	// 256 KiB in total, some room is left for the metadata.
+	maxConfigMapStateSize = 1<<20 - 16<<10
+	maxLeaseStateSize     = 256<<10 - 16<<10
+)
+
+// stateStore persists a blob of state, Load returns nil when there is none.
+type stateStore interface {
+	Load(ctx context.Context) ([]byte, error)
+	Save(ctx context.Context, data []byte) error
+}
+
+// newStateStore returns the store configured by cfg, or nil.
+func newStateStore(cfg config.DedupConfig, client kubernetes.Interface) (stateStore, error) {
+	if cfg.StateObject.Kind == "" {
+		if cfg.StateFile == "" {
+			return nil, nil
+		}
+		return fileState(cfg.StateFile), nil
+	}
+	if client == nil {
+		return nil, errors.New("state objects require a cluster")
+	}
+	namespace := cfg.StateObject.Namespace
+	if namespace == "" {
+		data, err := os.ReadFile(serviceAccountPath)
+		if err != nil {
+			return nil, fmt.Errorf("dedup.stateObject.namespace is required outside of a cluster: %w", err)
+		}
+		namespace = strings.TrimSpace(string(data))
+	}
+	return &objectState{client: client, kind: cfg.StateObject.Kind, namespace: namespace, name: cfg.StateObject.Name}, nil
+}
+
+type fileState string
+
+func (f fileState) Load(context.Context) ([]byte, error) {
+	data, err := os.ReadFile(string(f))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return data, err
+}
+
+func (f fileState) Save(_ context.Context, data []byte) error {
+	tmp := string(f) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, string(f))
+}
+
+// objectState keeps the state in the data of a ConfigMap or an annotation
+// of a Lease, which is created on the first save.
+type objectState struct {
+	client    kubernetes.Interface
+	kind      string
+	namespace string
+	name      string
+}
+
+func (o *objectState) Load(ctx context.Context) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, stateTimeout)
+	defer cancel()
+	var data string
+	var err error
+	if o.kind == config.StateObjectLease {
+		var lease *coordinationv1.Lease
+		if lease, err = o.client.CoordinationV1().Leases(o.namespace).Get(ctx, o.name, metav1.GetOptions{}); err == nil {
+			data = lease.Annotations[stateKey]
+		}
+	} else {
+		var configMap *corev1.ConfigMap
+		if configMap, err = o.client.CoreV1().ConfigMaps(o.namespace).Get(ctx, o.name, metav1.GetOptions{}); err == nil {
+			data = configMap.Data[stateConfigMapKey]
+		}
+	}
+	if apierrors.IsNotFound(err) || data == "" {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []byte(data), nil
+}
+
+func (o *objectState) Save(ctx context.Context, data []byte) error {
+	limit := maxConfigMapStateSize
+	if o.kind == config.StateObjectLease {
+		limit = maxLeaseStateSize
+	}
+	if len(data) > limit {
+		return fmt.Errorf("state of %d bytes exceeds the %s limit of %d bytes, use a shorter dedup window or a state file", len(data), o.kind, limit)
+	}
+	ctx, cancel := context.WithTimeout(ctx, stateTimeout)
+	defer cancel()
+	if o.kind == config.StateObjectLease {
+		leases := o.client.CoordinationV1().Leases(o.namespace)
+		lease, err := leases.Get(ctx, o.name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			lease = &coordinationv1.Lease{ObjectMeta: metav1.ObjectMeta{Name: o.name, Namespace: o.namespace}}
+			lease.Annotations = map[string]string{stateKey: string(data)}
+			_, err = leases.Create(ctx, lease, metav1.CreateOptions{})
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		if lease.Annotations == nil {
+			lease.Annotations = map[string]string{}
+		}
+		lease.Annotations[stateKey] = string(data)
+		_, err = leases.Update(ctx, lease, metav1.UpdateOptions{})
+		return err
+	}
+	configMaps := o.client.CoreV1().ConfigMaps(o.namespace)
+	configMap, err := configMaps.Get(ctx, o.name, metav1.GetOptions{})
+	if apierrors.IsNotFound(err) {
+		configMap = &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: o.name, Namespace: o.namespace}}
+		configMap.Data = map[string]string{stateConfigMapKey: string(data)}
+		_, err = configMaps.Create(ctx, configMap, metav1.CreateOptions{})
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if configMap.Data == nil {
+		configMap.Data = map[string]string{}
+	}
+	configMap.Data[stateConfigMapKey] = string(data)
+	_, err = configMaps.Update(ctx, configMap, metav1.UpdateOptions{})
+	return err
+}
@@ -0,0 +1,54 @@
+package watcher
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+)
+
+// Synced is called with the objects of the informer cache once the initial
+// list was received. With initialSync summary it emits a SyncSummary event
+// for the objects that pass the filters.
+func (rc *ResourceController) Synced(objs []interface{}) {
+	if !rc.initialSummary {
+		return
+	}
+	lines := make([]string, 0, len(objs))
+	namespaces := make(map[string]bool)
+	for _, obj := range objs {
+		objUnstructured := rc.toUnstructured(obj)
+		if objUnstructured == nil || !rc.matches(objUnstructured) {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%s %s\n", objectKey(objUnstructured), objUnstructured.GetResourceVersion()))
+		if namespace := objUnstructured.GetNamespace(); namespace != "" {
+			namespaces[namespace] = true
+		}
+	}
+	sort.Strings(lines)
+	hash := sha256.New()
+	for _, line := range lines {
+		hash.Write([]byte(line))
+	}
+	summary := &event.SyncSummary{
+		Objects:  int64(len(lines)),
+		Checksum: hex.EncodeToString(hash.Sum(nil)),
+	}
+	for namespace := range namespaces {
+		summary.Namespaces = append(summary.Namespaces, namespace)
+	}
+	sort.Strings(summary.Namespaces)
+	rc.Logger.Info("Initial list synced", "objects", summary.Objects, "namespaces", len(summary.Namespaces), "checksum", summary.Checksum)
+	rc.queue.Push(event.Event{
+		SchemaVersion: event.SchemaVersion,
+		Tenant:        rc.tenant,
+		GVR:           event.NewGVR(rc.GVR),
+		Type:          event.TypeSyncSummary,
+		Timestamp:     time.Now().UTC(),
+		Summary:       summary,
+	})
+}
@@ -0,0 +1,79 @@
+package watcher
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+// typedKinds lists the resources that can be watched with typed informers,
+// by the kind of their objects.
+var typedKinds = map[schema.GroupVersionResource]string{
+	{Version: "v1", Resource: "configmaps"}:                  "ConfigMap",
+	{Version: "v1", Resource: "endpoints"}:                   "Endpoints",
+	{Version: "v1", Resource: "events"}:                      "Event",
+	{Version: "v1", Resource: "namespaces"}:                  "Namespace",
+	{Version: "v1", Resource: "nodes"}:                       "Node",
+	{Version: "v1", Resource: "persistentvolumeclaims"}:      "PersistentVolumeClaim",
+	{Version: "v1", Resource: "persistentvolumes"}:           "PersistentVolume",
+	{Version: "v1", Resource: "pods"}:                        "Pod",
+	{Version: "v1", Resource: "secrets"}:                     "Secret",
+	{Version: "v1", Resource: "serviceaccounts"}:             "ServiceAccount",
+	{Version: "v1", Resource: "services"}:                    "Service",
+	{Group: "apps", Version: "v1", Resource: "daemonsets"}:   "DaemonSet",
+	{Group: "apps", Version: "v1", Resource: "deployments"}:  "Deployment",
+	{Group: "apps", Version: "v1", Resource: "replicasets"}:  "ReplicaSet",
+	{Group: "apps", Version: "v1", Resource: "statefulsets"}: "StatefulSet",
+	{Group: "batch", Version: "v1", Resource: "cronjobs"}:    "CronJob",
+	{Group: "batch", Version: "v1", Resource: "jobs"}:        "Job",
+}
+
+func checkTyped(gvr schema.GroupVersionResource) error {
+	if _, ok := typedKinds[gvr]; ok {
+		return nil
+	}
+	names := make([]string, 0, len(typedKinds))
+	for typed := range typedKinds {
+		names = append(names, strings.TrimPrefix(typed.Group+"/"+typed.Version+"/"+typed.Resource, "/"))
+	}
+	sort.Strings(names)
+	return fmt.Errorf("typed informers are not available for %s, only for %s", gvr.String(), strings.Join(names, ", "))
+}
+
+// newTypedClient returns a clientset talking protobuf, which the API server
+// encodes and the informers decode much cheaper than JSON.
+func newTypedClient(restConfig *rest.Config) (kubernetes.Interface, error) {
+	restConfig = rest.CopyConfig(restConfig)
+	restConfig.ContentType = runtime.ContentTypeProtobuf
+	restConfig.AcceptContentTypes = runtime.ContentTypeProtobuf + "," + runtime.ContentTypeJSON
+	return kubernetes.NewForConfig(restConfig)
+}
+
+func newTypedInformer(client kubernetes.Interface, controller ResourceControllerInterface, listOptions func(*metav1.ListOptions)) (cache.SharedIndexInformer, error) {
+	factory := informers.NewSharedInformerFactoryWithOptions(client, controller.ResyncPeriod(), informers.WithTweakListOptions(listOptions))
+	informer, err := factory.ForResource(controller.GetGVR())
+	if err != nil {
+		return nil, err
+	}
+	return informer.Informer(), nil
+}
+
+// typedToUnstructured converts a typed object for the filters. Objects of
+// typed informers carry no apiVersion and kind, they are set from gvr.
+func typedToUnstructured(obj runtime.Object, gvr schema.GroupVersionResource) (map[string]interface{}, error) {
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	content["apiVersion"] = gvr.GroupVersion().String()
+	content["kind"] = typedKinds[gvr]
+	return content, nil
+}
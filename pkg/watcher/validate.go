@@ -0,0 +1,228 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"golang.org/x/exp/slog"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/rest"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/logging"
+	"github.com/fl64/k8s-resource-watcher/pkg/suppress"
+)
+
+// ValidateConfig checks the parts of cfg that do not need a cluster: paths,
+// transforms, scripts, enum values and suppression windows.
+func ValidateConfig(cfg *config.Config) []error {
+	var errs []error
+	for i, resConfig := range cfg.Resources {
+		if resConfig.Resource == "" && resConfig.Kind == "" {
+			errs = append(errs, fmt.Errorf("resources[%d]: resource or kind is required", i))
+			continue
+		}
+		if err := validateResourceConfig(cfg, resConfig); err != nil {
+			errs = append(errs, fmt.Errorf("resources[%d] (%s): %w", i, entryName(resConfig), err))
+		}
+	}
+	errs = append(errs, validateGroups(cfg)...)
+	if cfg.CRDAutoWatch.Enabled {
+		if err := validateResourceConfig(cfg, cfg.CRDAutoWatch.Resource); err != nil {
+			errs = append(errs, fmt.Errorf("crdAutoWatch.resource: %w", err))
+		}
+	}
+	switch cfg.Queue.OverflowPolicy {
+	case "", config.OverflowPolicyBlock, config.OverflowPolicyDropOldest, config.OverflowPolicyDropNewest:
+	default:
+		errs = append(errs, fmt.Errorf("queue.overflowPolicy: unknown policy %q, expected block, drop-oldest or drop-newest", cfg.Queue.OverflowPolicy))
+	}
+	switch cfg.Client.AuthMode {
+	case "", config.AuthModeAuto, config.AuthModeKubeconfig, config.AuthModeInCluster:
+	default:
+		errs = append(errs, fmt.Errorf("client.authMode: unknown mode %q, expected auto, kubeconfig or in-cluster", cfg.Client.AuthMode))
+	}
+	if cfg.Concurrency.Workers < 0 || cfg.Concurrency.PerResource < 0 {
+		errs = append(errs, fmt.Errorf("concurrency: workers and perResource must not be negative"))
+	}
+	if cfg.Client.ListPageSize < 0 {
+		errs = append(errs, fmt.Errorf("client.listPageSize must not be negative"))
+	}
+	switch stateObject := cfg.Dedup.StateObject; stateObject.Kind {
+	case "":
+	case config.StateObjectConfigMap, config.StateObjectLease:
+		if stateObject.Name == "" {
+			errs = append(errs, fmt.Errorf("dedup.stateObject.name is required"))
+		}
+		if cfg.Dedup.StateFile != "" {
+			errs = append(errs, fmt.Errorf("dedup: stateFile and stateObject are mutually exclusive"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("dedup.stateObject.kind: unknown kind %q, expected configmap or lease", stateObject.Kind))
+	}
+	if cfg.LogLevel != "" {
+		if _, err := logging.ParseLevel(cfg.LogLevel); err != nil {
+			errs = append(errs, fmt.Errorf("logLevel: %w", err))
+		}
+	}
+	if err := logging.Validate(cfg.Logging); err != nil {
+		errs = append(errs, fmt.Errorf("logging: %w", err))
+	}
+	if _, err := suppress.NewPauser(cfg.Pause, nil); err != nil {
+		errs = append(errs, fmt.Errorf("pause: %w", err))
+	}
+	if _, err := suppress.New(cfg.Suppression, nil); err != nil {
+		errs = append(errs, fmt.Errorf("suppression: %w", err))
+	}
+	return errs
+}
+
+func validateGroups(cfg *config.Config) []error {
+	var errs []error
+	names := map[string]bool{}
+	for i, group := range cfg.Groups {
+		switch {
+		case group.Name == "":
+			errs = append(errs, fmt.Errorf("groups[%d]: name is required", i))
+		case names[group.Name]:
+			errs = append(errs, fmt.Errorf("groups[%d]: duplicate name %q", i, group.Name))
+		}
+		names[group.Name] = true
+		if (group.Label != "") == group.Owner {
+			errs = append(errs, fmt.Errorf("groups[%d] (%s): exactly one of label or owner is required", i, group.Name))
+		}
+	}
+	for i, sinkConfig := range cfg.Sinks {
+		for _, name := range sinkConfig.Groups {
+			if !names[name] {
+				errs = append(errs, fmt.Errorf("sinks[%d] (%s): unknown group %q", i, sinkConfig.Name, name))
+			}
+		}
+	}
+	return errs
+}
+
+func validateResourceConfig(cfg *config.Config, resConfig config.ResourceConfig) error {
+	for _, changes := range []string{cfg.Common.Changes, resConfig.Changes} {
+		switch changes {
+		case "", config.ChangesAll, config.ChangesSpec, config.ChangesStatus:
+		default:
+			return fmt.Errorf("unknown changes mode %q, expected all, spec or status", changes)
+		}
+	}
+	ignoreManagers := len(cfg.Common.IgnoreManagers) > 0 || len(resConfig.IgnoreManagers) > 0
+	if ignoreManagers && (cfg.Common.StripManagedFields || resConfig.StripManagedFields) {
+		return fmt.Errorf("ignoreManagers needs metadata.managedFields, which stripManagedFields removes")
+	}
+	if resConfig.Typed && resConfig.MetadataOnly {
+		return fmt.Errorf("typed and metadataOnly can not be combined")
+	}
+	if resConfig.KubernetesEvents.Dedup || resConfig.KubernetesEvents.Correlate {
+		if resConfig.Resource != "events" && resConfig.Kind != "Event" {
+			return fmt.Errorf("kubernetesEvents only applies to events")
+		}
+		if resConfig.MetadataOnly {
+			return fmt.Errorf("kubernetesEvents needs the full Event objects, which metadataOnly does not fetch")
+		}
+	}
+	for _, group := range cfg.Groups {
+		if group.Owner && !cfg.Common.ResolveOwners && !resConfig.ResolveOwners {
+			return fmt.Errorf("group %q correlates by owner, which needs resolveOwners", group.Name)
+		}
+	}
+	for _, scope := range []string{cfg.Common.Scope, resConfig.Scope} {
+		switch scope {
+		case "", config.ScopeAll, config.ScopeCluster, config.ScopeNamespaced:
+		default:
+			return fmt.Errorf("unknown scope %q, expected all, cluster or namespaced", scope)
+		}
+	}
+	for _, oversize := range []string{cfg.Common.Oversize, resConfig.Oversize} {
+		switch oversize {
+		case "", config.OversizeTruncate, config.OversizeSummarize:
+		default:
+			return fmt.Errorf("unknown oversize mode %q, expected truncate or summarize", oversize)
+		}
+	}
+	for _, initialSync := range []string{cfg.Common.InitialSync, resConfig.InitialSync} {
+		switch initialSync {
+		case "", config.InitialSyncAdds, config.InitialSyncSummary:
+		default:
+			return fmt.Errorf("unknown initialSync mode %q, expected adds or summary", initialSync)
+		}
+	}
+	for _, resync := range []*time.Duration{cfg.Common.Resync, resConfig.Resync} {
+		if resync != nil && *resync < 0 {
+			return fmt.Errorf("resync must not be negative")
+		}
+	}
+	for _, policy := range []string{cfg.Common.RateLimit.Policy, resConfig.RateLimit.Policy} {
+		switch policy {
+		case "", config.RateLimitPolicyDrop, config.RateLimitPolicyQueue:
+		default:
+			return fmt.Errorf("unknown rate limit policy %q, expected drop or queue", policy)
+		}
+	}
+	if resConfig.Workers < 0 {
+		return fmt.Errorf("workers must not be negative")
+	}
+	_, err := newControllerFromConfig(context.Background(), cfg, resConfig, schema.GroupVersionResource{}, slog.Default(), nil, nil, nil, nil, nil)
+	return err
+}
+
+// ValidateCluster resolves the configured resources against the cluster and
+// checks that they exist and can be listed and watched.
+func ValidateCluster(cfg *config.Config, restConfig *rest.Config) ([]error, error) {
+	restConfig, err := applyClientConfig(restConfig, cfg.Client)
+	if err != nil {
+		return nil, err
+	}
+	baseDiscoveryClient, err := discovery.NewDiscoveryClientForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create discovery client: %w", err)
+	}
+	discoveryClient := memory.NewMemCacheClient(baseDiscoveryClient)
+	mapper := newRESTMapper(discoveryClient, slog.Default())
+
+	var errs []error
+	var gvrs []schema.GroupVersionResource
+	for i, resConfig := range cfg.Resources {
+		if isWildcard(resConfig) {
+			if _, err := expandWildcard(discoveryClient, resConfig, scopeOf(cfg.Common, resConfig)); err != nil {
+				errs = append(errs, fmt.Errorf("resources[%d] (%s): %w", i, entryName(resConfig), err))
+			}
+			continue
+		}
+		gvr, err := resolveGVR(mapper, resConfig)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("resources[%d] (%s): %w", i, entryName(resConfig), err))
+			continue
+		}
+		// A common scope skips resources, the scope of the entry itself
+		// excluding it is a mistake.
+		if namespaced, ok := isNamespaced(mapper, gvr); ok && !inScope(resConfig.Scope, namespaced) {
+			errs = append(errs, fmt.Errorf("resources[%d] (%s): scope %s excludes the resource", i, entryName(resConfig), resConfig.Scope))
+			continue
+		}
+		gvrs = append(gvrs, gvr)
+	}
+	gvrErrs, err := validateGVRs(discoveryClient, gvrs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover server resources: %w", err)
+	}
+	return append(errs, gvrErrs...), nil
+}
+
+func entryName(resConfig config.ResourceConfig) string {
+	name := resConfig.Resource
+	if name == "" {
+		name = resConfig.Kind
+	}
+	if resConfig.Group != "" {
+		name += "." + resConfig.Group
+	}
+	return name
+}
@@ -0,0 +1,473 @@
+// Package watcher watches Kubernetes resources and turns their changes into events.
+package watcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	"golang.org/x/exp/slog"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/metadata"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+	"github.com/fl64/k8s-resource-watcher/pkg/metrics"
+	"github.com/fl64/k8s-resource-watcher/pkg/suppress"
+)
+
+// Options configure a Watcher.
+type Options struct {
+	Config *config.Config
+	// RestConfig is used to talk to the cluster, LoadRestConfig with the
+	// configured auth mode is used when nil.
+	RestConfig *rest.Config
+	// Logger defaults to slog.Default().
+	Logger *slog.Logger
+	// Recorder, when set, receives every object the informers deliver.
+	Recorder Recorder
+	// Clients replaces the clients created from RestConfig, e.g. with fakes
+	// in tests, see package watchertest.
+	Clients *Clients
+}
+
+// Clients are the API clients of a Watcher.
+type Clients struct {
+	Dynamic   dynamic.Interface
+	Metadata  metadata.Interface
+	Typed     kubernetes.Interface
+	Discovery discovery.DiscoveryInterface
+}
+
+func newClients(restConfig *rest.Config) (*Clients, error) {
+	var clients Clients
+	var err error
+	if clients.Dynamic, err = dynamic.NewForConfig(restConfig); err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+	if clients.Metadata, err = metadata.NewForConfig(restConfig); err != nil {
+		return nil, fmt.Errorf("failed to create metadata client: %w", err)
+	}
+	if clients.Typed, err = newTypedClient(restConfig); err != nil {
+		return nil, fmt.Errorf("failed to create typed client: %w", err)
+	}
+	if clients.Discovery, err = discovery.NewDiscoveryClientForConfig(restConfig); err != nil {
+		return nil, fmt.Errorf("failed to create discovery client: %w", err)
+	}
+	return &clients, nil
+}
+
+// Watcher runs informers for the configured resources and publishes their events on Events.
+type Watcher struct {
+	cfg            *config.Config
+	logger         *slog.Logger
+	client         dynamic.Interface
+	metadataClient metadata.Interface
+	typedClient    kubernetes.Interface
+	queue          *EventQueue
+	controllers    []ResourceControllerInterface
+	gvrs           []schema.GroupVersionResource
+	informers      []cache.SharedIndexInformer
+	handlersSynced []cache.InformerSynced
+	informersWG    sync.WaitGroup
+	crdWatcher     *CRDWatcher
+	owners         *ownerResolver
+	impact         *impactResolver
+	namespaces     *namespaceLabels
+	suppressor     *suppress.Suppressor
+	pauser         *suppress.Pauser
+	dedup          *deduplicator
+	scheduler      *scheduler
+	listOptions    func(*metav1.ListOptions)
+	recorder       Recorder
+	// clusterMetadata is shared by all events and must not be modified.
+	clusterMetadata map[string]string
+	// serverVersion is the git version of the API server, empty when it
+	// could not be detected.
+	serverVersion string
+	events        chan event.Event
+
+	ctx      context.Context
+	cancel   context.CancelFunc
+	mu       sync.Mutex
+	err      error
+	stopOnce sync.Once
+}
+
+// New resolves and validates the configured resources and prepares their informers.
+// Nothing is watched until Start is called.
+func New(opts Options) (*Watcher, error) {
+	if opts.Config == nil {
+		return nil, errors.New("config is required")
+	}
+	// Unknown modes would otherwise fall back to a default silently.
+	if errs := ValidateConfig(opts.Config); len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	var err error
+	restConfig := opts.RestConfig
+	clients := opts.Clients
+	if clients == nil {
+		if restConfig == nil {
+			if restConfig, err = LoadRestConfig(opts.Config.Client.AuthMode); err != nil {
+				return nil, fmt.Errorf("failed to create client config: %w", err)
+			}
+		}
+		if restConfig, err = applyClientConfig(restConfig, opts.Config.Client); err != nil {
+			return nil, err
+		}
+		if clients, err = newClients(restConfig); err != nil {
+			return nil, err
+		}
+	} else if restConfig == nil {
+		restConfig = &rest.Config{}
+	}
+
+	if opts.Config.Client.WatchList {
+		if err := enableWatchList(); err != nil {
+			return nil, fmt.Errorf("failed to enable watch list: %w", err)
+		}
+	}
+
+	w := &Watcher{
+		cfg:         opts.Config,
+		logger:      logger,
+		queue:       NewEventQueue(opts.Config.Queue),
+		listOptions: pagedListOptions(opts.Config.Client.ListPageSize),
+		scheduler:   newScheduler(opts.Config.Concurrency.Workers),
+		recorder:    opts.Recorder,
+		events:      make(chan event.Event),
+	}
+	w.ctx, w.cancel = context.WithCancel(context.Background())
+	// Buffered events go through the queue again once their window ended.
+	// Like paused events they are checked before dedup.
+	if w.suppressor, err = suppress.New(opts.Config.Suppression, w.queue.Push); err != nil {
+		return nil, err
+	}
+	// Paused events are checked before dedup, so released ones are not
+	// taken for duplicates of themselves.
+	if w.pauser, err = suppress.NewPauser(opts.Config.Pause, w.queue.Push); err != nil {
+		return nil, err
+	}
+	w.client, w.metadataClient, w.typedClient = clients.Dynamic, clients.Metadata, clients.Typed
+	state, err := newStateStore(opts.Config.Dedup, w.typedClient)
+	if err != nil {
+		return nil, err
+	}
+	if w.dedup, err = newDeduplicator(opts.Config.Dedup, state); err != nil {
+		return nil, fmt.Errorf("failed to load dedup state: %w", err)
+	}
+	discoveryClient := memory.NewMemCacheClient(clients.Discovery)
+	if version, err := discoveryClient.ServerVersion(); err == nil {
+		w.serverVersion = version.GitVersion
+	}
+	if w.clusterMetadata, err = clusterMetadata(w.cfg.ClusterMetadata, restConfig, discoveryClient); err != nil {
+		return nil, fmt.Errorf("failed to detect cluster metadata: %w", err)
+	}
+	mapper := newRESTMapper(discoveryClient, logger)
+	w.owners = newOwnerResolver(w.metadataClient, mapper)
+	w.impact = newImpactResolver()
+	if usesNamespaceSelector(w.cfg) {
+		if w.namespaces, err = newNamespaceLabels(w.typedClient); err != nil {
+			return nil, fmt.Errorf("failed to setup namespace informer: %w", err)
+		}
+	}
+
+	// Expand wildcard entries into one entry per discovered resource
+	var resConfigs []config.ResourceConfig
+	for _, resConfig := range w.cfg.Resources {
+		if !isWildcard(resConfig) {
+			resConfigs = append(resConfigs, resConfig)
+			continue
+		}
+		expanded, err := expandWildcard(discoveryClient, resConfig, scopeOf(w.cfg.Common, resConfig))
+		if err != nil {
+			return nil, fmt.Errorf("failed to discover resources for wildcard group %q: %w", resConfig.Group, err)
+		}
+		logger.Info("Expanded wildcard entry", "group", resConfig.Group, "resources", len(expanded))
+		resConfigs = append(resConfigs, expanded...)
+	}
+
+	for _, resConfig := range resConfigs {
+		gvr, err := resolveGVR(mapper, resConfig)
+		if err != nil {
+			// Leave the resource as configured, validation below reports it.
+			logger.Warn("Failed to resolve resource", "kind", resConfig.Kind, "resource", resConfig.Resource, "error", err)
+		} else if namespaced, ok := isNamespaced(mapper, gvr); ok && !inScope(scopeOf(w.cfg.Common, resConfig), namespaced) {
+			logger.Warn("Skipping resource outside of the configured scope", "group", gvr.Group, "version", gvr.Version, "kind", gvr.Resource, "scope", scopeOf(w.cfg.Common, resConfig))
+			continue
+		}
+		controller, err := newControllerFromConfig(w.ctx, w.cfg, resConfig, gvr, logger, w.queue, w.owners, w.impact, w.namespaces, w.scheduler)
+		if err != nil {
+			return nil, fmt.Errorf("invalid resource config for %s: %w", gvr.String(), err)
+		}
+		w.controllers = append(w.controllers, controller)
+		w.gvrs = append(w.gvrs, gvr)
+	}
+
+	validationErrs, err := validateGVRs(discoveryClient, w.gvrs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover server resources: %w", err)
+	}
+	if len(validationErrs) > 0 {
+		return nil, errors.Join(validationErrs...)
+	}
+
+	// Informers retry forbidden lists forever with opaque errors, missing
+	// permissions are reported up front instead.
+	required := slices.Clone(w.gvrs)
+	if w.namespaces != nil {
+		required = append(required, schema.GroupVersionResource{Version: "v1", Resource: "namespaces"})
+	}
+	if w.cfg.CRDAutoWatch.Enabled {
+		required = append(required, crdGVR)
+	}
+	permissionErrs, err := checkPermissions(w.ctx, w.typedClient, required)
+	if err != nil {
+		logger.Warn("Skipping the permission check", "error", err)
+	}
+	if len(permissionErrs) > 0 {
+		return nil, errors.Join(permissionErrs...)
+	}
+
+	for _, controller := range w.controllers {
+		informer, synced, err := newInformer(w.client, w.metadataClient, w.typedClient, controller, w.listOptions, w.handleWatchError)
+		if err != nil {
+			return nil, fmt.Errorf("failed to setup informer: %w", err)
+		}
+		if err := w.addRecorder(informer, controller); err != nil {
+			return nil, fmt.Errorf("failed to setup recorder: %w", err)
+		}
+		w.impact.Add(controller, informer)
+		w.informers = append(w.informers, informer)
+		w.handlersSynced = append(w.handlersSynced, synced)
+	}
+
+	go func() {
+		defer close(w.events)
+		w.queue.Run(func(ev event.Event) {
+			if !w.pauser.Allow(ev) || !w.suppressor.Allow(ev) || !w.dedup.Allow(ev) {
+				return
+			}
+			ev.Cluster = w.cfg.Cluster
+			ev.ClusterMetadata = w.clusterMetadata
+			w.events <- ev
+		})
+		if err := w.dedup.Save(context.Background()); err != nil {
+			w.logger.Error("Failed to save dedup state", "error", err)
+		}
+	}()
+	return w, nil
+}
+
+// Events returns the channel events are published on. It is closed once Stop
+// has drained the pending events, so it must be read until then.
+func (w *Watcher) Events() <-chan event.Event {
+	return w.events
+}
+
+// Start runs the informers and blocks until their caches are synced and an Add
+// event was queued for every existing object, or a SyncSummary event for the
+// resources with initialSync summary. Calling Stop right after Start
+// therefore emits a snapshot of the watched resources; with concurrency
+// workers the Add events may still be in progress, Stop waits for them. Watching stops when ctx is done, Stop is called or a watch fails with FailFast set.
+func (w *Watcher) Start(ctx context.Context) error {
+	go func() {
+		select {
+		case <-ctx.Done():
+			w.cancel()
+		case <-w.ctx.Done():
+		}
+	}()
+	go w.dedup.saveLoop(w.ctx, w.logger)
+
+	// Objects are only matched against namespaceSelectors once the labels
+	// of all namespaces are known.
+	if w.namespaces != nil {
+		w.informersWG.Add(1)
+		go func() {
+			defer w.informersWG.Done()
+			w.namespaces.informer.Run(w.ctx.Done())
+		}()
+		if !cache.WaitForCacheSync(w.ctx.Done(), w.namespaces.informer.HasSynced) {
+			return errors.New("failed to sync namespaces")
+		}
+	}
+
+	informers := w.informers
+	if w.cfg.CRDAutoWatch.Enabled {
+		crdConfig := w.cfg.CRDAutoWatch
+		crdConfig.Resource.Scope = scopeOf(w.cfg.Common, crdConfig.Resource)
+		w.crdWatcher = NewCRDWatcher(w.ctx, crdConfig, w.logger, &w.informersWG, w.gvrs,
+			func(gvr schema.GroupVersionResource) (ResourceControllerInterface, error) {
+				return newControllerFromConfig(w.ctx, w.cfg, w.cfg.CRDAutoWatch.Resource, gvr, w.logger, w.queue, w.owners, w.impact, w.namespaces, w.scheduler)
+			},
+			func(controller ResourceControllerInterface) (cache.SharedIndexInformer, error) {
+				informer, _, err := newInformer(w.client, w.metadataClient, w.typedClient, controller, w.listOptions, w.handleWatchError)
+				if err != nil {
+					return nil, err
+				}
+				return informer, w.addRecorder(informer, controller)
+			},
+		)
+		crdInformer := dynamicinformer.NewDynamicSharedInformerFactory(w.client, 0).ForResource(crdGVR).Informer()
+		crdInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    w.crdWatcher.AddFunc,
+			UpdateFunc: w.crdWatcher.UpdateFunc,
+			DeleteFunc: w.crdWatcher.DeleteFunc,
+		})
+		informers = append(informers, crdInformer)
+	}
+	for _, informer := range informers {
+		w.informersWG.Add(1)
+		go func(informer cache.SharedIndexInformer) {
+			defer w.informersWG.Done()
+			informer.Run(w.ctx.Done())
+		}(informer)
+	}
+
+	w.logger.Info("Waiting for cache sync...")
+	synced := append([]cache.InformerSynced{informersSyncedCallback(informers)}, w.handlersSynced...)
+	if !cache.WaitForCacheSync(w.ctx.Done(), synced...) {
+		if err := w.Err(); err != nil {
+			return err
+		}
+		return errors.New("failed to sync cache")
+	}
+	w.logger.Info("Cache synced successfully")
+	for i, controller := range w.controllers {
+		controller.Synced(w.informers[i].GetStore().List())
+	}
+	return nil
+}
+
+// ServerVersion returns the version of the API server, e.g. "v1.30.1".
+func (w *Watcher) ServerVersion() string {
+	return w.serverVersion
+}
+
+// Resources returns the configured resources with wildcards expanded, not
+// including those added by CRD auto-watch.
+func (w *Watcher) Resources() []schema.GroupVersionResource {
+	return w.gvrs
+}
+
+// Done is closed when the watcher stops watching.
+func (w *Watcher) Done() <-chan struct{} {
+	return w.ctx.Done()
+}
+
+// Err returns the watch error that stopped the watcher, if any.
+func (w *Watcher) Err() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
+
+// Stop stops the informers, flushes debounced updates and closes the event
+// queue. Events already queued are still delivered on Events.
+func (w *Watcher) Stop() {
+	w.stopOnce.Do(func() {
+		w.cancel()
+		w.informersWG.Wait()
+		w.scheduler.Close()
+		for _, controller := range w.controllers {
+			controller.Flush()
+		}
+		if w.crdWatcher != nil {
+			w.crdWatcher.Flush()
+		}
+		// Paused and suppressed events are delivered rather than lost on shutdown.
+		w.pauser.Close()
+		w.suppressor.Close()
+		w.queue.Close()
+	})
+}
+
+// Pause holds back the events of resource, e.g. "pods" or "deployments.apps",
+// or of all resources when it is empty. Events are dropped or buffered until
+// Resume as configured by config.PauseConfig.
+func (w *Watcher) Pause(resource string) {
+	w.pauser.Pause(resource)
+	w.logger.Info("Paused event emission", "resource", resource)
+}
+
+// Resume ends a pause of resource, or all pauses when it is empty.
+func (w *Watcher) Resume(resource string) {
+	w.pauser.Resume(resource)
+	w.logger.Info("Resumed event emission", "resource", resource)
+}
+
+// PauseStatus reports the paused resources.
+func (w *Watcher) PauseStatus() suppress.PauseStatus {
+	return w.pauser.Status()
+}
+
+// Pending returns the number of queued events not yet published on Events.
+func (w *Watcher) Pending() int {
+	return w.queue.Len()
+}
+
+// clusterMetadata merges the configured labels with the detected cluster details.
+func clusterMetadata(cfg config.ClusterMetadataConfig, restConfig *rest.Config, client discovery.DiscoveryInterface) (map[string]string, error) {
+	if len(cfg.Labels) == 0 && !cfg.Detect {
+		return nil, nil
+	}
+	metadata := make(map[string]string, len(cfg.Labels)+2)
+	if cfg.Detect {
+		version, err := client.ServerVersion()
+		if err != nil {
+			return nil, err
+		}
+		metadata["apiServer"] = restConfig.Host
+		metadata["kubeVersion"] = version.GitVersion
+	}
+	for key, value := range cfg.Labels {
+		metadata[key] = value
+	}
+	return metadata, nil
+}
+
+func (w *Watcher) handleWatchError(gvr schema.GroupVersionResource, err error) {
+	// The reflector lists the resource again after every failed watch.
+	metrics.LastRelistTimestamp.WithLabelValues(gvr.Group, gvr.Version, gvr.Resource).SetToCurrentTime()
+	if isExpired(err) {
+		// Bookmarks keep the resource version of quiet watches current, so
+		// expiry means the watcher fell behind the history of the API server,
+		// e.g. after a long disconnect or when the watch cache is too small.
+		metrics.RelistsTotal.WithLabelValues(gvr.Group, gvr.Version, gvr.Resource, "expired").Inc()
+		w.logger.Warn("Watch resource version too old, relisting", "group", gvr.Group, "version", gvr.Version, "kind", gvr.Resource, "error", err)
+		return
+	}
+	metrics.RelistsTotal.WithLabelValues(gvr.Group, gvr.Version, gvr.Resource, "error").Inc()
+	reason := string(apierrors.ReasonForError(err))
+	if reason == "" {
+		reason = "Unknown"
+	}
+	metrics.WatchErrorsTotal.WithLabelValues(gvr.Group, gvr.Version, gvr.Resource, reason).Inc()
+	w.logger.Error("Watch failed", "group", gvr.Group, "version", gvr.Version, "kind", gvr.Resource, "reason", reason, "error", err)
+	if w.cfg.FailFast && isPermanentWatchError(err) {
+		w.logger.Error("Resource can not be watched, stopping", "group", gvr.Group, "version", gvr.Version, "kind", gvr.Resource)
+		w.mu.Lock()
+		if w.err == nil {
+			w.err = fmt.Errorf("watch %s failed: %w", gvr.String(), err)
+		}
+		w.mu.Unlock()
+		w.cancel()
+	}
+}
@@ -0,0 +1,214 @@
+package watcher_test
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+	"github.com/fl64/k8s-resource-watcher/pkg/watchertest"
+)
+
+var configMaps = watchertest.Resource{
+	GVR:        schema.GroupVersionResource{Version: "v1", Resource: "configmaps"},
+	Kind:       "ConfigMap",
+	Namespaced: true,
+}
+
+func configMap(name, uid, value string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata":   map[string]interface{}{"name": name, "namespace": "default", "uid": uid},
+		"data":       map[string]interface{}{"value": value},
+	}}
+	return obj
+}
+
+func testConfig(resource config.ResourceConfig) *config.Config {
+	resource.Version, resource.Resource = "v1", "configmaps"
+	return &config.Config{Resources: []config.ResourceConfig{resource}}
+}
+
+// start runs a harness until the test ends, objects are added before the
+// watcher starts and form the initial list.
+func start(t *testing.T, cfg *config.Config, objects ...*unstructured.Unstructured) *watchertest.Harness {
+	t.Helper()
+	h, err := watchertest.New(cfg, nil, configMaps)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	for _, obj := range objects {
+		if err := h.AddObject(obj); err != nil {
+			t.Fatalf("AddObject: %v", err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	if err := h.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	t.Cleanup(func() { stop(h) })
+	return h
+}
+
+// stop stops h and drains its events.
+func stop(h *watchertest.Harness) {
+	h.Stop()
+	for range h.Events() {
+	}
+}
+
+func next(t *testing.T, h *watchertest.Harness, eventType, name string) event.Event {
+	t.Helper()
+	ev, ok := h.Next(5 * time.Second)
+	if !ok {
+		t.Fatalf("no %s event of %s", eventType, name)
+	}
+	if ev.Type != eventType || ev.Name != name {
+		t.Fatalf("got %s event of %s, want %s of %s", ev.Type, ev.Name, eventType, name)
+	}
+	return ev
+}
+
+func none(t *testing.T, h *watchertest.Harness, timeout time.Duration) {
+	t.Helper()
+	if ev, ok := h.Next(timeout); ok {
+		t.Fatalf("unexpected %s event of %s", ev.Type, ev.Name)
+	}
+}
+
+func TestAddUpdateDelete(t *testing.T) {
+	h := start(t, testConfig(config.ResourceConfig{}), configMap("initial", "uid-0", "a"))
+	ev := next(t, h, "Add", "initial")
+	if ev.Namespace != "default" || ev.UID != "uid-0" || ev.GVR.Resource != "configmaps" {
+		t.Errorf("unexpected event %+v", ev)
+	}
+
+	if err := h.AddObject(configMap("web", "uid-1", "a")); err != nil {
+		t.Fatal(err)
+	}
+	next(t, h, "Add", "web")
+	if err := h.UpdateObject(configMap("web", "uid-1", "b")); err != nil {
+		t.Fatal(err)
+	}
+	ev = next(t, h, "Update", "web")
+	if got := ev.Object["data"].(map[string]interface{})["value"]; got != "b" {
+		t.Errorf("updated value = %v, want b", got)
+	}
+	if err := h.DeleteObject(configMap("web", "", "")); err != nil {
+		t.Fatal(err)
+	}
+	next(t, h, "Delete", "web")
+}
+
+func TestFilteredUpdatesAreDropped(t *testing.T) {
+	cfg := testConfig(config.ResourceConfig{FilterConfig: config.FilterConfig{IncludePaths: []string{"metadata.name"}}})
+	h := start(t, cfg, configMap("web", "uid-1", "a"))
+	next(t, h, "Add", "web")
+	if err := h.UpdateObject(configMap("web", "uid-1", "b")); err != nil {
+		t.Fatal(err)
+	}
+	none(t, h, 200*time.Millisecond)
+}
+
+func TestDebounce(t *testing.T) {
+	h := start(t, testConfig(config.ResourceConfig{Debounce: 200 * time.Millisecond}), configMap("web", "uid-1", "a"))
+	next(t, h, "Add", "web")
+	for _, value := range []string{"b", "c", "d"} {
+		if err := h.UpdateObject(configMap("web", "uid-1", value)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ev := next(t, h, "Update", "web")
+	if got := ev.Object["data"].(map[string]interface{})["value"]; got != "d" {
+		t.Errorf("debounced value = %v, want the last one", got)
+	}
+	none(t, h, 400*time.Millisecond)
+
+	// A deletion emits the pending update first.
+	if err := h.UpdateObject(configMap("web", "uid-1", "e")); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.DeleteObject(configMap("web", "", "")); err != nil {
+		t.Fatal(err)
+	}
+	next(t, h, "Update", "web")
+	next(t, h, "Delete", "web")
+}
+
+func TestRecreated(t *testing.T) {
+	h := start(t, testConfig(config.ResourceConfig{RecreateWindow: time.Second}), configMap("web", "uid-1", "a"))
+	next(t, h, "Add", "web")
+	if err := h.DeleteObject(configMap("web", "", "")); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.AddObject(configMap("web", "uid-2", "b")); err != nil {
+		t.Fatal(err)
+	}
+	ev := next(t, h, event.TypeRecreated, "web")
+	if ev.UID != "uid-2" || ev.PreviousUID != "uid-1" {
+		t.Errorf("recreated uid %q from %q, want uid-2 from uid-1", ev.UID, ev.PreviousUID)
+	}
+
+	// Deletions without an Add within the window are emitted late.
+	if err := h.DeleteObject(configMap("web", "", "")); err != nil {
+		t.Fatal(err)
+	}
+	none(t, h, 500*time.Millisecond)
+	next(t, h, "Delete", "web")
+}
+
+func TestRecreatedByUID(t *testing.T) {
+	h := start(t, testConfig(config.ResourceConfig{}), configMap("web", "uid-1", "a"))
+	next(t, h, "Add", "web")
+	// The informer sees the replacement as an update when it missed the deletion.
+	if err := h.UpdateObject(configMap("web", "uid-2", "a")); err != nil {
+		t.Fatal(err)
+	}
+	ev := next(t, h, event.TypeRecreated, "web")
+	if ev.PreviousUID != "uid-1" {
+		t.Errorf("previous uid %q, want uid-1", ev.PreviousUID)
+	}
+}
+
+func TestInitialSyncSummary(t *testing.T) {
+	cfg := testConfig(config.ResourceConfig{InitialSync: config.InitialSyncSummary})
+	h := start(t, cfg, configMap("a", "uid-1", "a"), configMap("b", "uid-2", "b"))
+	ev, ok := h.Next(5 * time.Second)
+	if !ok || ev.Type != event.TypeSyncSummary {
+		t.Fatalf("got %s event, want %s", ev.Type, event.TypeSyncSummary)
+	}
+	if ev.Summary == nil || ev.Summary.Objects != 2 || len(ev.Summary.Namespaces) != 1 || ev.Summary.Checksum == "" {
+		t.Errorf("summary %+v, want 2 objects in 1 namespace", ev.Summary)
+	}
+	// Objects added later are reported one by one.
+	if err := h.AddObject(configMap("c", "uid-3", "c")); err != nil {
+		t.Fatal(err)
+	}
+	next(t, h, "Add", "c")
+}
+
+func TestDedupAcrossRestarts(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "dedup.json")
+	// The harness assigns new resource versions to the objects of the restart.
+	cfg := testConfig(config.ResourceConfig{FilterConfig: config.FilterConfig{ExcludePaths: []string{"metadata.resourceVersion"}}})
+	cfg.Dedup = config.DedupConfig{Window: time.Hour, StateFile: stateFile}
+
+	h := start(t, cfg, configMap("web", "uid-1", "a"))
+	next(t, h, "Add", "web")
+	if err := h.UpdateObject(configMap("web", "uid-1", "b")); err != nil {
+		t.Fatal(err)
+	}
+	next(t, h, "Update", "web")
+	stop(h)
+
+	h = start(t, cfg, configMap("web", "uid-1", "b"), configMap("other", "uid-2", "a"))
+	next(t, h, "Add", "other")
+	none(t, h, 200*time.Millisecond)
+}
@@ -0,0 +1,259 @@
+// Package watchertest runs a Watcher against fake API clients, so that
+// embedders can test their resource, filter and sink configurations without
+// a cluster.
+package watchertest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slog"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/watch"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	metadatafake "k8s.io/client-go/metadata/fake"
+	clienttesting "k8s.io/client-go/testing"
+
+	"github.com/fl64/k8s-resource-watcher/pkg/config"
+	"github.com/fl64/k8s-resource-watcher/pkg/event"
+	"github.com/fl64/k8s-resource-watcher/pkg/watcher"
+)
+
+// Resource is a resource served by the fake API server.
+type Resource struct {
+	GVR        schema.GroupVersionResource
+	Kind       string
+	Namespaced bool
+}
+
+// Harness is a Watcher whose informers are driven by AddObject, UpdateObject
+// and DeleteObject. Objects are stored in the fake dynamic, metadata and
+// typed clients alike, so resources work with metadataOnly and typed set.
+type Harness struct {
+	Watcher *watcher.Watcher
+
+	dynamic   *dynamicfake.FakeDynamicClient
+	metadata  *metadatafake.FakeMetadataClient
+	typed     *kubefake.Clientset
+	resources map[schema.GroupVersionKind]Resource
+
+	mu              sync.Mutex
+	resourceVersion int
+	watches         map[schema.GroupVersionResource]bool
+	watchStarted    chan struct{}
+}
+
+// New creates a Watcher for cfg that only knows the given resources. Wildcard
+// entries expand to nothing. logger may be nil.
+func New(cfg *config.Config, logger *slog.Logger, resources ...Resource) (*Harness, error) {
+	h := &Harness{
+		resources:    make(map[schema.GroupVersionKind]Resource, len(resources)),
+		watches:      make(map[schema.GroupVersionResource]bool),
+		watchStarted: make(chan struct{}, 1),
+	}
+	listKinds := make(map[schema.GroupVersionResource]string, len(resources))
+	lists := map[string]*metav1.APIResourceList{}
+	for _, resource := range resources {
+		h.resources[resource.GVR.GroupVersion().WithKind(resource.Kind)] = resource
+		listKinds[resource.GVR] = resource.Kind + "List"
+		groupVersion := resource.GVR.GroupVersion().String()
+		if lists[groupVersion] == nil {
+			lists[groupVersion] = &metav1.APIResourceList{GroupVersion: groupVersion}
+		}
+		lists[groupVersion].APIResources = append(lists[groupVersion].APIResources, metav1.APIResource{
+			Name:       resource.GVR.Resource,
+			Namespaced: resource.Namespaced,
+			Group:      resource.GVR.Group,
+			Version:    resource.GVR.Version,
+			Kind:       resource.Kind,
+			Verbs:      metav1.Verbs{"get", "list", "watch"},
+		})
+	}
+	metadataScheme := metadatafake.NewTestScheme()
+	if err := metav1.AddMetaToScheme(metadataScheme); err != nil {
+		return nil, err
+	}
+	h.dynamic = dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(), listKinds)
+	h.metadata = metadatafake.NewSimpleMetadataClient(metadataScheme)
+	h.typed = kubefake.NewSimpleClientset()
+	h.dynamic.PrependWatchReactor("*", h.watchReactor(h.dynamic.Tracker()))
+	h.metadata.PrependWatchReactor("*", h.watchReactor(h.metadata.Tracker()))
+	h.typed.PrependWatchReactor("*", h.watchReactor(h.typed.Tracker()))
+	// The fake API server authorizes everything.
+	h.typed.PrependReactor("create", "selfsubjectaccessreviews", func(action clienttesting.Action) (bool, runtime.Object, error) {
+		review := action.(clienttesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectAccessReview)
+		review.Status.Allowed = true
+		return true, review, nil
+	})
+
+	discovery := &fakediscovery.FakeDiscovery{Fake: &clienttesting.Fake{}}
+	for _, list := range lists {
+		discovery.Resources = append(discovery.Resources, list)
+	}
+	w, err := watcher.New(watcher.Options{
+		Config: cfg,
+		Logger: logger,
+		Clients: &watcher.Clients{
+			Dynamic:   h.dynamic,
+			Metadata:  h.metadata,
+			Typed:     h.typed,
+			Discovery: discovery,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	h.Watcher = w
+	return h, nil
+}
+
+// watchReactor starts watches on tracker and records them, the fake clients
+// do not deliver changes made before their watch started.
+func (h *Harness) watchReactor(tracker clienttesting.ObjectTracker) clienttesting.WatchReactionFunc {
+	return func(action clienttesting.Action) (bool, watch.Interface, error) {
+		w, err := tracker.Watch(action.GetResource(), action.GetNamespace())
+		if err != nil {
+			return true, nil, err
+		}
+		h.mu.Lock()
+		h.watches[action.GetResource()] = true
+		h.mu.Unlock()
+		select {
+		case h.watchStarted <- struct{}{}:
+		default:
+		}
+		return true, w, nil
+	}
+}
+
+// Start starts the Watcher and waits until the informers of all configured
+// resources are watching, objects added before are reported as Add events of
+// the initial list.
+func (h *Harness) Start(ctx context.Context) error {
+	if err := h.Watcher.Start(ctx); err != nil {
+		return err
+	}
+	for !h.watching() {
+		select {
+		case <-h.watchStarted:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-h.Watcher.Done():
+			return errors.New("watcher stopped")
+		}
+	}
+	return nil
+}
+
+func (h *Harness) watching() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, gvr := range h.Watcher.Resources() {
+		if !h.watches[gvr] {
+			return false
+		}
+	}
+	return true
+}
+
+// Stop stops the Watcher, pending events are still delivered on Events.
+func (h *Harness) Stop() {
+	h.Watcher.Stop()
+}
+
+// Events returns the events of the Watcher.
+func (h *Harness) Events() <-chan event.Event {
+	return h.Watcher.Events()
+}
+
+// Next returns the next event, or false when none arrived within timeout.
+func (h *Harness) Next(timeout time.Duration) (event.Event, bool) {
+	select {
+	case ev, ok := <-h.Events():
+		return ev, ok
+	case <-time.After(timeout):
+		return event.Event{}, false
+	}
+}
+
+// AddObject creates obj. Its resource is looked up by apiVersion and kind, a
+// resourceVersion is assigned.
+func (h *Harness) AddObject(obj *unstructured.Unstructured) error {
+	return h.apply(obj, clienttesting.ObjectTracker.Create)
+}
+
+// UpdateObject replaces obj, which must exist.
+func (h *Harness) UpdateObject(obj *unstructured.Unstructured) error {
+	return h.apply(obj, clienttesting.ObjectTracker.Update)
+}
+
+// DeleteObject deletes obj, only its apiVersion, kind, namespace and name are used.
+func (h *Harness) DeleteObject(obj *unstructured.Unstructured) error {
+	resource, err := h.resource(obj)
+	if err != nil {
+		return err
+	}
+	namespace, name := obj.GetNamespace(), obj.GetName()
+	if err := h.dynamic.Tracker().Delete(resource.GVR, namespace, name); err != nil {
+		return err
+	}
+	if err := h.metadata.Tracker().Delete(resource.GVR, namespace, name); err != nil {
+		return err
+	}
+	if _, err := scheme.Scheme.New(obj.GroupVersionKind()); err == nil {
+		return h.typed.Tracker().Delete(resource.GVR, namespace, name)
+	}
+	return nil
+}
+
+type trackerFunc func(tracker clienttesting.ObjectTracker, gvr schema.GroupVersionResource, obj runtime.Object, ns string) error
+
+func (h *Harness) apply(obj *unstructured.Unstructured, fn trackerFunc) error {
+	resource, err := h.resource(obj)
+	if err != nil {
+		return err
+	}
+	obj = obj.DeepCopy()
+	h.mu.Lock()
+	h.resourceVersion++
+	obj.SetResourceVersion(strconv.Itoa(h.resourceVersion))
+	h.mu.Unlock()
+	namespace := obj.GetNamespace()
+	if err := fn(h.dynamic.Tracker(), resource.GVR, obj, namespace); err != nil {
+		return err
+	}
+	partial := &metav1.PartialObjectMetadata{}
+	content := map[string]interface{}{"apiVersion": obj.GetAPIVersion(), "kind": obj.GetKind(), "metadata": obj.Object["metadata"]}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, partial); err != nil {
+		return err
+	}
+	if err := fn(h.metadata.Tracker(), resource.GVR, partial, namespace); err != nil {
+		return err
+	}
+	if typed, err := scheme.Scheme.New(obj.GroupVersionKind()); err == nil {
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(obj.Object, typed); err != nil {
+			return err
+		}
+		return fn(h.typed.Tracker(), resource.GVR, typed, namespace)
+	}
+	return nil
+}
+
+func (h *Harness) resource(obj *unstructured.Unstructured) (Resource, error) {
+	resource, ok := h.resources[obj.GroupVersionKind()]
+	if !ok {
+		return Resource{}, fmt.Errorf("unknown resource for %s", obj.GroupVersionKind())
+	}
+	return resource, nil
+}